@@ -1,17 +1,56 @@
 package agent
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"go-ollama/logger"
+	"go-ollama/mcp"
 	"go-ollama/ollama"
 	"go-ollama/rag"
 	"go-ollama/rule"
+	"go-ollama/store"
+	"os"
+	"path/filepath"
+	"sort"
 	"sync"
 )
 
 // AgentManager Agent 管理器接口
 // 创建/管理所有agent生命周期
 type AgentManager interface {
-	Chat(chat string) string
+	Chat(sessionId string, chat string) string
+	Ask(sessionId string, chat string) (string, error)
+	ChatStream(ctx context.Context, sessionId string, chat string) chan ChatEvent
+	ListConversations() ([]store.ConversationInfo, error)
+	GetConversation(id string) (*store.Conversation, error)
+	DeleteConversation(id string) error
+	RegisterTool(name string, description string, parameters json.RawMessage, fn ToolFunc) error
+	Specialists() []SpecialistInfo
+	AskSpecialist(sessionId string, name string, chat string) (string, error)
+	SearchKnowledge(name string, query string) (string, error)
+	KnowledgeStatus() []KnowledgeStatus
+	ListKnowledgeDocuments(name string) ([]rag.DocumentInfo, error)
+	UploadKnowledgeDocument(name string, docId string, data []byte) error
+	DeleteKnowledgeDocument(name string, docId string) error
+	ReindexKnowledge(name string) error
+	Close() error
+}
+
+// SpecialistInfo 专家信息
+type SpecialistInfo struct {
+	Name         string // 专家名称
+	Introduction string // 专家介绍
+	HasKnowledge bool   // 是否有 RAG 知识库
+}
+
+// ChatEvent 流式对话事件，通过 channel 实时返回
+type ChatEvent struct {
+	Token string // 增量 token
+	Reset bool   // 为 true 时表示之前输出的内容作废，之后输出的是重写后的回答
+	Done  bool   // 是否结束，为 true 时 Token 为完整回答
+	Err   error  // 错误信息
 }
 
 // agentManager Agent 管理器实现（包私有）
@@ -23,6 +62,10 @@ type agentManager struct {
 	generalAgent  *Specialist
 	specialistMap map[string]*Specialist
 	reviewerMap   map[string]*Reviewer
+	sessions      *SessionManager
+	tools         *ToolRegistry
+	mcpClients    []*mcp.Client
+	uploadDir     string
 	logger        logger.ErrorLogger
 }
 
@@ -35,11 +78,14 @@ var (
 )
 
 // newAgentManager 创建并初始化 Agent 管理器实例
-// 初始化顺序：规则管理器 -> RAG 管理器 -> 协调者 -> 专家和评审者
+// 初始化顺序：规则管理器 -> RAG 管理器 -> 工具 -> 协调者 -> 专家和评审者 -> MCP 服务 -> 知识库索引
 // 参数 ollama: Ollama 管理器
+// 参数 convStore: 会话存储，为 nil 时不持久化
+// 参数 vectorDir: 知识库向量的持久化目录，为空时只保存在内存中
+// 参数 uploadDir: 上传到知识库的文档的保存目录
 // 参数 logger: 日志记录器
 // 返回: agentManager 实例、error
-func newAgentManager(ollama ollama.OllamaManager, logger logger.ErrorLogger) (*agentManager, error) {
+func newAgentManager(ollama ollama.OllamaManager, convStore store.ConversationStore, vectorDir string, uploadDir string, logger logger.ErrorLogger) (*agentManager, error) {
 	// 1 rule
 	// 规则管理器需要先起
 	ruleManager, err := rule.StartRuleManager()
@@ -47,27 +93,96 @@ func newAgentManager(ollama ollama.OllamaManager, logger logger.ErrorLogger) (*a
 		return nil, err
 	}
 
+	// 全局默认的模型生成参数
+	ollama.SetDefaultOptions(optionsFromConfig(ruleManager.Options()))
+
+	// 各角色使用的模型，启动时校验是否可用
+	models := newModelSelector(ollama, ruleManager.Models(), logger)
+
 	// 2 rag
-	reranker := newReranker(ollama, ruleManager)
-	ragMgr := rag.StartRagManager(reranker)
+	rerankerModel, err := models.reranker()
+	if err != nil {
+		return nil, err
+	}
+	reranker := newReranker(ollama, rerankerModel, ruleManager)
+	rewriterModel, err := models.rewriter()
+	if err != nil {
+		return nil, err
+	}
+	transformer := newQueryTransformer(ollama, rewriterModel, ruleManager)
+	embedModel, err := models.embed()
+	if err != nil {
+		// 只有需要知识库的专家依赖嵌入模型
+		if needRag(ruleManager) {
+			return nil, err
+		}
+		logger.LogError(err, "model", "embed")
+	}
+	embedder := newEmbedder(ollama, embedModel)
+	rerankEmbedder := newEmbedder(ollama, models.rerankEmbed(embedModel))
+	ragMgr, err := rag.StartRagManager(reranker, transformer, embedder, rerankEmbedder, vectorDir)
+	if err != nil {
+		return nil, err
+	}
 
-	// 3 coordinator
-	coordinator := newCoordinator(ollama, ruleManager)
+	// 3 tools
+	tools := newToolRegistry()
+	if err := registerBuiltinTools(tools); err != nil {
+		return nil, err
+	}
 
-	// 4 specialist + reviewer
-	general := newSpecialist(ollama, ragMgr, ruleManager.GetGeneralRule(), logger)
+	// 4 coordinator
+	coordinatorModel, err := models.coordinator()
+	if err != nil {
+		return nil, err
+	}
+	coordinator := newCoordinator(ollama, coordinatorModel, ruleManager)
+
+	// 5 specialist + reviewer
+	generalRule := ruleManager.GetGeneralRule()
+	generalModel, err := models.specialist(generalRule)
+	if err != nil {
+		return nil, err
+	}
+	general := newSpecialist(ollama, ragMgr, generalModel, generalRule, ruleManager.Messages(generalRule.Language()), tools, logger)
 	specialistMap := make(map[string]*Specialist)
 	reviewerMap := make(map[string]*Reviewer)
 	for _, rule := range ruleManager.GetAllRules() {
-		specialist := newSpecialist(ollama, ragMgr, rule, logger)
+		specialistModel, err := models.specialist(rule)
+		if err != nil {
+			return nil, err
+		}
+		specialist := newSpecialist(ollama, ragMgr, specialistModel, rule, ruleManager.Messages(rule.Language()), tools, logger)
+		if rule.NeedRag() {
+			if err := specialist.openKnowledge(); err != nil {
+				return nil, err
+			}
+		}
 		specialistMap[rule.Name()] = specialist
 		coordinator.addSpecialist(rule.Name(), rule.Introduction())
 		if rule.NeedReviewer() {
-			reviewer := newReviewer(ollama, rule, logger)
+			reviewerModel, err := models.reviewer(rule)
+			if err != nil {
+				return nil, err
+			}
+			reviewer := newReviewer(ollama, reviewerModel, rule, ruleManager.Messages(rule.Language()), logger)
 			reviewerMap[rule.Name()] = reviewer
 		}
 	}
 
+	// 6 mcp
+	// 最后连接 MCP 服务，避免前面的步骤出错时遗留已启动的服务进程
+	mcpClients, err := connectMcpServers(ruleManager.McpServers(), tools, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	// 7 knowledge
+	// 在后台为需要知识库的专家建立索引，索引完成前这些专家回复索引中的提示
+	for _, specialist := range specialistMap {
+		specialist.startIndexing()
+	}
+
 	return &agentManager{
 		ollama:        ollama,
 		rag:           ragMgr,
@@ -76,20 +191,33 @@ func newAgentManager(ollama ollama.OllamaManager, logger logger.ErrorLogger) (*a
 		generalAgent:  general,
 		specialistMap: specialistMap,
 		reviewerMap:   reviewerMap,
+		sessions:      newSessionManager(sessionIdleTimeout, convStore, logger),
+		tools:         tools,
+		mcpClients:    mcpClients,
+		uploadDir:     uploadDir,
 		logger:        logger,
 	}, nil
 }
 
+// needRag 是否有专家需要知识库
+func needRag(ruleManager rule.RuleManager) bool {
+	for _, rule := range ruleManager.GetAllRules() {
+		if rule.NeedRag() {
+			return true
+		}
+	}
+	return false
+}
+
 // StartAgentManager 获取 Agent 管理器单例
-// 初始化顺序：规则管理器 -> RAG 管理器 -> 协调者 -> 专家和评审者
+// 初始化顺序：规则管理器 -> RAG 管理器 -> 工具 -> 协调者 -> 专家和评审者 -> MCP 服务
 // 返回初始化完成的 AgentManager 实例
 // todo
 // rag工程化
-// function call
-func StartAgentManager(ollama ollama.OllamaManager, logger logger.ErrorLogger) (AgentManager, error) {
+func StartAgentManager(ollama ollama.OllamaManager, convStore store.ConversationStore, vectorDir string, uploadDir string, logger logger.ErrorLogger) (AgentManager, error) {
 	var err error
 	agentOnce.Do(func() {
-		agentInstance, err = newAgentManager(ollama, logger)
+		agentInstance, err = newAgentManager(ollama, convStore, vectorDir, uploadDir, logger)
 	})
 
 	if err != nil {
@@ -100,9 +228,27 @@ func StartAgentManager(ollama ollama.OllamaManager, logger logger.ErrorLogger) (
 
 // Chat 处理用户输入的聊天请求，实现完整的 Agent 协作流程
 // 流程：1. 协调者选择专家 2. 专家回答问题 3. 评审者评估 4. 低分重写
+// 同一会话内的请求串行执行，不同会话的对话历史互相隔离
+// 参数 sessionId: 会话 ID
 // 参数 chat: 用户输入的问题
-// 返回: Agent 生成的回答
-func (a *agentManager) Chat(chat string) string {
+// 返回: Agent 生成的回答，失败时为提示文本
+func (a *agentManager) Chat(sessionId string, chat string) string {
+	answer, err := a.Ask(sessionId, chat)
+	if err != nil {
+		return errorMessage(err)
+	}
+	return answer
+}
+
+// Ask 与 Chat 流程相同，失败时返回错误而不是提示文本
+// 参数 sessionId: 会话 ID
+// 参数 chat: 用户输入的问题
+// 返回: Agent 生成的回答、error
+func (a *agentManager) Ask(sessionId string, chat string) (string, error) {
+	session := a.sessions.acquire(sessionId)
+	defer a.sessions.release(session)
+	session.setTitle(chat)
+
 	// 1. 调用协调者选择最适合的专家
 	name, err := a.coordinator.askForSpecialistName(chat)
 	if err != nil {
@@ -110,6 +256,44 @@ func (a *agentManager) Chat(chat string) string {
 		// 如果协调者失败，使用通用专家
 		name = ""
 	}
+
+	return a.answer(session, name, chat)
+}
+
+// errorMessage 回答失败时回复给用户的提示
+// 知识库尚未就绪时提示索引进度，其他错误返回通用提示
+// 参数 err: 回答失败的错误
+// 返回: 提示文本
+func errorMessage(err error) string {
+	var indexing *IndexingError
+	if errors.As(err, &indexing) {
+		return indexing.Error()
+	}
+	return "抱歉，处理问题时出现错误，请稍后重试。"
+}
+
+// AskSpecialist 跳过协调者，直接向指定专家提问
+// 流程：1. 专家回答问题 2. 评审者评估 3. 低分重写
+// 参数 sessionId: 会话 ID
+// 参数 name: 专家名称
+// 参数 chat: 用户输入的问题
+// 返回: 专家生成的回答、error
+func (a *agentManager) AskSpecialist(sessionId string, name string, chat string) (string, error) {
+	if _, ok := a.specialistMap[name]; !ok {
+		return "", fmt.Errorf("specialist not found: %s", name)
+	}
+	session := a.sessions.acquire(sessionId)
+	defer a.sessions.release(session)
+	session.setTitle(chat)
+	return a.answer(session, name, chat)
+}
+
+// answer 由指定专家回答问题，有评审者时进行评审，低分时重写
+// 参数 session: 已加锁的会话
+// 参数 name: 专家名称，没有匹配的专家时使用通用专家
+// 参数 chat: 用户输入的问题
+// 返回: 回答、error
+func (a *agentManager) answer(session *Session, name string, chat string) (string, error) {
 	specialist, ok := a.specialistMap[name]
 	// 如果没有匹配的专家，使用通用专家
 	if !ok {
@@ -117,29 +301,289 @@ func (a *agentManager) Chat(chat string) string {
 	}
 
 	// 2. 调用专家生成回答
-	answer, err := specialist.chat(chat)
+	chatCtx := session.specialistChat(specialist)
+	answer, err := specialist.chat(chatCtx, chat)
 	if err != nil {
 		a.logger.LogError(err, "specialist chat")
-		return "抱歉，处理问题时出现错误，请稍后重试。"
+		return "", err
 	}
-	
+
 	// 3. 如果有评审者，进行质量评估
 	reviewer, ok := a.reviewerMap[name]
 	if ok {
-		review := reviewer.review(chat, answer)
+		review := reviewer.review(session.reviewerChat(reviewer), chat, answer)
 		// 4. 如果分数低于阈值，触发重写流程
 		if review.Score < rewriteScore {
 			// 获取 specialist 对应的规则并构建重写消息
 			rule := specialist.getRule()
 			message := rule.RewriteMessage(review.Review)
-			rewrittenAnswer, err := specialist.chat(message)
+			rewrittenAnswer, err := specialist.chat(chatCtx, message)
 			if err != nil {
 				a.logger.LogError(err, "specialist rewrite")
 				// 如果重写失败，返回原始答案
-				return answer
+				return answer, nil
 			}
 			answer = rewrittenAnswer
 		}
 	}
-	return answer
+	return answer, nil
+}
+
+// Specialists 获取所有已配置专家的信息
+func (a *agentManager) Specialists() []SpecialistInfo {
+	var infos []SpecialistInfo
+	for _, rule := range a.rule.GetAllRules() {
+		infos = append(infos, SpecialistInfo{
+			Name:         rule.Name(),
+			Introduction: rule.Introduction(),
+			HasKnowledge: rule.NeedRag(),
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+	return infos
+}
+
+// SearchKnowledge 检索指定专家的知识库，返回与问题相关的文档
+// 参数 name: 专家名称
+// 参数 query: 检索问题
+// 返回: 检索到的文档、error
+func (a *agentManager) SearchKnowledge(name string, query string) (string, error) {
+	specialist, err := a.knowledgeSpecialist(name)
+	if err != nil {
+		return "", err
+	}
+	// 直接检索知识库时没有对话历史
+	return specialist.search(query, "")
+}
+
+// knowledgeSpecialist 获取拥有指定知识库的专家
+// 参数 name: 专家名称
+// 返回: 专家、error，专家不存在或没有知识库时返回 ErrKnowledgeNotFound
+func (a *agentManager) knowledgeSpecialist(name string) (*Specialist, error) {
+	specialist, ok := a.specialistMap[name]
+	if !ok || !specialist.getRule().NeedRag() {
+		return nil, fmt.Errorf("%w: %s", ErrKnowledgeNotFound, name)
+	}
+	return specialist, nil
+}
+
+// ListKnowledgeDocuments 获取指定专家知识库中的所有文档
+// 参数 name: 专家名称
+// 返回: 按 ID 排序的文档信息、error
+func (a *agentManager) ListKnowledgeDocuments(name string) ([]rag.DocumentInfo, error) {
+	specialist, err := a.knowledgeSpecialist(name)
+	if err != nil {
+		return nil, err
+	}
+	return a.rag.ListDocuments(specialist.ragCtx), nil
+}
+
+// UploadKnowledgeDocument 上传文档到指定专家的知识库
+// 文档保存到上传目录后在后台建立索引，文档 ID 已存在时替换原有的上传文档
+// 参数 name: 专家名称
+// 参数 docId: 文档 ID，同时用作保存的文件名
+// 参数 data: 文档内容
+// 返回: error，文档 ID 与配置的源文件冲突时返回 ErrDocumentConflict
+func (a *agentManager) UploadKnowledgeDocument(name string, docId string, data []byte) error {
+	specialist, err := a.knowledgeSpecialist(name)
+	if err != nil {
+		return err
+	}
+	if err := rag.CheckDocumentId(docId); err != nil {
+		return err
+	}
+	configured, err := specialist.isConfiguredDocument(docId)
+	if err != nil {
+		return err
+	}
+	if configured {
+		return fmt.Errorf("%w: %s", ErrDocumentConflict, docId)
+	}
+	dir := filepath.Join(a.uploadDir, name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	path := filepath.Join(dir, docId)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return err
+	}
+	return specialist.addDocument(rag.DocumentSource{Id: docId, Source: path, Origin: rag.OriginUpload})
+}
+
+// DeleteKnowledgeDocument 删除指定专家知识库中的文档
+// 通过上传添加的文档同时删除保存的文件
+// 参数 name: 专家名称
+// 参数 docId: 文档 ID
+// 返回: error，文档不存在时返回 rag.ErrDocumentNotFound
+func (a *agentManager) DeleteKnowledgeDocument(name string, docId string) error {
+	specialist, err := a.knowledgeSpecialist(name)
+	if err != nil {
+		return err
+	}
+	doc, err := specialist.deleteDocument(docId)
+	if err != nil {
+		return err
+	}
+	if doc.Source == filepath.Join(a.uploadDir, name, docId) {
+		if err := os.Remove(doc.Source); err != nil && !os.IsNotExist(err) {
+			a.logger.LogError(err, "remove uploaded document", doc.Source)
+		}
+	}
+	return nil
+}
+
+// ReindexKnowledge 在后台重新索引指定专家知识库中的所有文档
+// 同时重新展开规则配置的源文件通配符，加入新增的文件
+// 参数 name: 专家名称
+// 返回: error
+func (a *agentManager) ReindexKnowledge(name string) error {
+	specialist, err := a.knowledgeSpecialist(name)
+	if err != nil {
+		return err
+	}
+	specialist.reindex()
+	return nil
+}
+
+// KnowledgeStatus 获取所有需要知识库的专家的索引进度
+func (a *agentManager) KnowledgeStatus() []KnowledgeStatus {
+	var statuses []KnowledgeStatus
+	for _, specialist := range a.specialistMap {
+		if specialist.getRule().NeedRag() {
+			statuses = append(statuses, specialist.knowledgeStatus())
+		}
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses
+}
+
+// ChatStream 以流式方式处理用户输入的聊天请求
+// 流程与 Chat 相同，专家回答的 token 实时输出；评审低分触发重写时，先发送 Reset 事件，再输出重写后的回答
+// 参数 ctx: 请求的 context，调用者取消（如客户端断开）后停止生成
+// 参数 sessionId: 会话 ID
+// 参数 chat: 用户输入的问题
+// 返回: ChatEvent channel，最后一个事件 Done 为 true 或 Err 不为空
+// 注意：返回的 channel 需要调用者消费
+func (a *agentManager) ChatStream(ctx context.Context, sessionId string, chat string) chan ChatEvent {
+	chEvent := make(chan ChatEvent)
+	go func() {
+		defer close(chEvent)
+		session := a.sessions.acquire(sessionId)
+		defer a.sessions.release(session)
+		session.setTitle(chat)
+
+		// 1. 调用协调者选择最适合的专家
+		name, err := a.coordinator.askForSpecialistName(chat)
+		if err != nil {
+			a.logger.LogError(err, "coordinator askForSpecialistName")
+			name = ""
+		}
+		specialist, ok := a.specialistMap[name]
+		if !ok {
+			specialist = a.generalAgent
+		}
+
+		// 2. 调用专家流式生成回答
+		chatCtx := session.specialistChat(specialist)
+		answer, err := a.forwardStream(ctx, specialist, chatCtx, chat, chEvent)
+		if err != nil {
+			a.logger.LogError(err, "specialist chat stream")
+			if errors.Is(err, ErrKnowledgeIndexing) {
+				// 保留原始错误，便于调用者识别索引中的状态
+				chEvent <- ChatEvent{Err: err}
+				return
+			}
+			chEvent <- ChatEvent{Err: fmt.Errorf("%s", errorMessage(err))}
+			return
+		}
+
+		// 3. 如果有评审者，进行质量评估
+		reviewer, ok := a.reviewerMap[name]
+		if ok {
+			review := reviewer.review(session.reviewerChat(reviewer), chat, answer)
+			// 4. 如果分数低于阈值，触发重写流程
+			if review.Score < rewriteScore {
+				message := specialist.getRule().RewriteMessage(review.Review)
+				chEvent <- ChatEvent{Reset: true}
+				rewrittenAnswer, err := a.forwardStream(ctx, specialist, chatCtx, message, chEvent)
+				if err != nil {
+					a.logger.LogError(err, "specialist rewrite stream")
+					// 如果重写失败，返回原始答案（Done 事件携带完整回答）
+					chEvent <- ChatEvent{Token: answer, Done: true}
+					return
+				}
+				answer = rewrittenAnswer
+			}
+		}
+		chEvent <- ChatEvent{Token: answer, Done: true}
+	}()
+	return chEvent
+}
+
+// forwardStream 调用专家流式生成回答，并将增量 token 转发为 ChatEvent
+// 参数 ctx: 请求的 context
+// 参数 specialist: 专家
+// 参数 chatCtx: 会话中该专家的对话上下文
+// 参数 chat: 发送给专家的消息
+// 参数 chEvent: 事件输出的 channel
+// 返回: 完整回答、error
+func (a *agentManager) forwardStream(ctx context.Context, specialist *Specialist, chatCtx *ollama.ChatContext, chat string, chEvent chan ChatEvent) (string, error) {
+	chStream, err := specialist.chatStream(ctx, chatCtx, chat)
+	if err != nil {
+		return "", err
+	}
+	answer := ""
+	for chunk := range chStream {
+		if chunk.Err != nil {
+			err = chunk.Err
+			continue
+		}
+		if chunk.Done {
+			answer = chunk.Content
+			continue
+		}
+		chEvent <- ChatEvent{Token: chunk.Content}
+	}
+	return answer, err
+}
+
+// ListConversations 列出已保存的会话
+func (a *agentManager) ListConversations() ([]store.ConversationInfo, error) {
+	return a.sessions.list()
+}
+
+// GetConversation 获取已保存的会话内容
+func (a *agentManager) GetConversation(id string) (*store.Conversation, error) {
+	return a.sessions.get(id)
+}
+
+// DeleteConversation 删除会话
+func (a *agentManager) DeleteConversation(id string) error {
+	return a.sessions.remove(id)
+}
+
+// RegisterTool 注册可供专家调用的工具
+// 专家是否可以使用该工具由规则配置中的 tools 列表决定
+// 参数 name: 工具名称
+// 参数 description: 工具说明
+// 参数 parameters: 参数的 JSON Schema
+// 参数 fn: 工具实现
+// 返回: error
+func (a *agentManager) RegisterTool(name string, description string, parameters json.RawMessage, fn ToolFunc) error {
+	return a.tools.Register(name, description, parameters, fn)
+}
+
+// Close 关闭 Agent 管理器持有的外部连接（如 MCP 服务）
+func (a *agentManager) Close() error {
+	var firstErr error
+	for _, client := range a.mcpClients {
+		if err := client.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
 }
@@ -0,0 +1,165 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// registerBuiltinTools 注册内置工具
+func registerBuiltinTools(registry *ToolRegistry) error {
+	err := registry.Register("current_time", "获取当前的日期和时间",
+		json.RawMessage(`{"type":"object","properties":{}}`),
+		func(args json.RawMessage) (string, error) {
+			return time.Now().Format("2006-01-02 15:04:05 Monday"), nil
+		})
+	if err != nil {
+		return err
+	}
+
+	return registry.Register("calculate", "计算四则运算表达式的值，支持 + - * / ^ 和括号",
+		json.RawMessage(`{"type":"object","properties":{"expression":{"type":"string","description":"四则运算表达式，例如 (1+2)*3"}},"required":["expression"]}`),
+		func(args json.RawMessage) (string, error) {
+			var params struct {
+				Expression string `json:"expression"`
+			}
+			if err := json.Unmarshal(args, &params); err != nil {
+				return "", fmt.Errorf("invalid arguments: %v", err)
+			}
+			value, err := evaluateExpression(params.Expression)
+			if err != nil {
+				return "", err
+			}
+			return strconv.FormatFloat(value, 'g', -1, 64), nil
+		})
+}
+
+// expressionParser 四则运算表达式解析器（递归下降）
+type expressionParser struct {
+	text string // 去除空白后的表达式
+	pos  int    // 当前解析位置
+}
+
+// evaluateExpression 计算四则运算表达式的值
+// 参数 expression: 表达式文本
+// 返回: 计算结果、error
+func evaluateExpression(expression string) (float64, error) {
+	p := &expressionParser{text: strings.Join(strings.Fields(expression), "")}
+	value, err := p.parseSum()
+	if err != nil {
+		return 0, err
+	}
+	if p.pos != len(p.text) {
+		return 0, fmt.Errorf("unexpected %q at %d", p.text[p.pos:], p.pos)
+	}
+	return value, nil
+}
+
+// parseSum 解析加减法
+func (p *expressionParser) parseSum() (float64, error) {
+	value, err := p.parseProduct()
+	if err != nil {
+		return 0, err
+	}
+	for p.pos < len(p.text) && (p.text[p.pos] == '+' || p.text[p.pos] == '-') {
+		op := p.text[p.pos]
+		p.pos++
+		rhs, err := p.parseProduct()
+		if err != nil {
+			return 0, err
+		}
+		if op == '+' {
+			value += rhs
+		} else {
+			value -= rhs
+		}
+	}
+	return value, nil
+}
+
+// parseProduct 解析乘除法
+func (p *expressionParser) parseProduct() (float64, error) {
+	value, err := p.parseUnary()
+	if err != nil {
+		return 0, err
+	}
+	for p.pos < len(p.text) && (p.text[p.pos] == '*' || p.text[p.pos] == '/') {
+		op := p.text[p.pos]
+		p.pos++
+		rhs, err := p.parseUnary()
+		if err != nil {
+			return 0, err
+		}
+		if op == '*' {
+			value *= rhs
+		} else {
+			if rhs == 0 {
+				return 0, fmt.Errorf("division by zero")
+			}
+			value /= rhs
+		}
+	}
+	return value, nil
+}
+
+// parseUnary 解析正负号，正负号的优先级低于乘方
+func (p *expressionParser) parseUnary() (float64, error) {
+	if p.pos < len(p.text) && p.text[p.pos] == '-' {
+		p.pos++
+		value, err := p.parseUnary()
+		return -value, err
+	}
+	if p.pos < len(p.text) && p.text[p.pos] == '+' {
+		p.pos++
+		return p.parseUnary()
+	}
+	return p.parsePower()
+}
+
+// parsePower 解析乘方（右结合）
+func (p *expressionParser) parsePower() (float64, error) {
+	base, err := p.parsePrimary()
+	if err != nil {
+		return 0, err
+	}
+	if p.pos < len(p.text) && p.text[p.pos] == '^' {
+		p.pos++
+		exponent, err := p.parseUnary()
+		if err != nil {
+			return 0, err
+		}
+		return math.Pow(base, exponent), nil
+	}
+	return base, nil
+}
+
+// parsePrimary 解析括号和数字
+func (p *expressionParser) parsePrimary() (float64, error) {
+	if p.pos >= len(p.text) {
+		return 0, fmt.Errorf("unexpected end of expression")
+	}
+	if p.text[p.pos] == '(' {
+		p.pos++
+		value, err := p.parseSum()
+		if err != nil {
+			return 0, err
+		}
+		if p.pos >= len(p.text) || p.text[p.pos] != ')' {
+			return 0, fmt.Errorf("missing )")
+		}
+		p.pos++
+		return value, nil
+	}
+
+	start := p.pos
+	for p.pos < len(p.text) && (p.text[p.pos] >= '0' && p.text[p.pos] <= '9' || p.text[p.pos] == '.') {
+		p.pos++
+	}
+	if start == p.pos {
+		return 0, fmt.Errorf("unexpected %q at %d", p.text[p.pos:], p.pos)
+	}
+	return strconv.ParseFloat(p.text[start:p.pos], 64)
+}
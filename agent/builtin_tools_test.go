@@ -0,0 +1,25 @@
+package agent
+
+import "testing"
+
+func TestEvaluateExpression(t *testing.T) {
+	cases := map[string]float64{
+		"1+2*3":         7,
+		"(1+2)*3":       9,
+		"-2^2":          -4,
+		"2^3^2":         512,
+		" 10 / 4 ":      2.5,
+		"100*(100+1)/2": 5050,
+	}
+	for expression, expected := range cases {
+		value, err := evaluateExpression(expression)
+		if err != nil || value != expected {
+			t.Fatalf("expected %s = %v, got %v %v", expression, expected, value, err)
+		}
+	}
+	for _, expression := range []string{"1/0", "(1+2", "1+", "abc"} {
+		if _, err := evaluateExpression(expression); err == nil {
+			t.Fatalf("expected error for %s", expression)
+		}
+	}
+}
@@ -1,8 +1,10 @@
 package agent
 
 import (
+	"encoding/json"
 	"go-ollama/ollama"
 	"go-ollama/rule"
+	"strings"
 )
 
 // Coordinator 协调者，负责分析问题并选择最合适的专家 Agent
@@ -14,10 +16,11 @@ type Coordinator struct {
 }
 
 // newCoordinator 创建并初始化协调者实例
-func newCoordinator(ollama ollama.OllamaManager, rule rule.RuleManager) *Coordinator {
+// 参数 modelName: 使用的模型名称
+func newCoordinator(ollama ollama.OllamaManager, modelName string, rule rule.RuleManager) *Coordinator {
 	coordinator := Coordinator{
 		ollama:        ollama,
-		modelName:     ollama.GetAvailableModelName("deepseek"),
+		modelName:     modelName,
 		specialistMap: make(map[string]string),
 		rule:          rule,
 	}
@@ -31,18 +34,52 @@ func (c *Coordinator) addSpecialist(name string, introduction string) {
 	c.specialistMap[name] = introduction
 }
 
+// noSpecialistName 没有合适的专家时协调者回复的名称
+const noSpecialistName = "NA"
+
+// specialistChoice 协调者的结构化输出
+type specialistChoice struct {
+	Name string `json:"name"` // 选择的专家名称，没有合适的专家时为 NA
+}
+
 // askForSpecialistName 分析用户问题，选择最合适的专家来回答
 // 使用 LLM 根据专家介绍和问题内容进行匹配
+// 优先使用 JSON Schema 将回复约束为已注册的专家名称，失败时降级为文本回复
 // 参数 chat: 用户输入的问题
 // 返回: 匹配的专家名称、error
 func (c *Coordinator) askForSpecialistName(chat string) (string, error) {
 	message := c.rule.CoordinatorMessage(chat)
+	names := []string{noSpecialistName}
 	for name, introduction := range c.specialistMap {
 		message += c.rule.CoordinatorSpecialistMessage(name, introduction)
+		names = append(names, name)
 	}
-	result, err := c.ollama.ChatWithoutContext(c.modelName, message)
-	if err != nil {
-		return "", err
+
+	var choice specialistChoice
+	raw, err := c.ollama.ChatWithoutContextJSON(c.modelName, message, specialistSchema(names), &choice)
+	if err == nil {
+		return choice.Name, nil
 	}
-	return result, nil
+	if raw == "" {
+		// 请求失败（例如模型不支持 format），降级为普通文本回复
+		raw, err = c.ollama.ChatWithoutContext(c.modelName, message)
+		if err != nil {
+			return "", err
+		}
+	}
+	return strings.TrimSpace(raw), nil
+}
+
+// specialistSchema 构建协调者输出的 JSON Schema
+// 参数 names: 可选的专家名称
+// 返回: JSON Schema
+func specialistSchema(names []string) json.RawMessage {
+	schema, _ := json.Marshal(map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"name": map[string]interface{}{"type": "string", "enum": names},
+		},
+		"required": []string{"name"},
+	})
+	return schema
 }
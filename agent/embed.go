@@ -0,0 +1,49 @@
+package agent
+
+import (
+	"fmt"
+	"go-ollama/ollama"
+)
+
+// Embedder 向量化器，通过 Ollama 的 /api/embed 接口将文本转换为向量
+// 供 RAG 的向量数据库使用，向量化请求计入 Ollama 管理器的统计
+type Embedder struct {
+	ollama    ollama.OllamaManager // Ollama 管理器
+	modelName string               // 使用的嵌入模型名称
+}
+
+// newEmbedder 创建并初始化向量化器实例
+// 参数 modelName: 使用的嵌入模型名称
+func newEmbedder(ollama ollama.OllamaManager, modelName string) *Embedder {
+	embedder := Embedder{
+		ollama:    ollama,
+		modelName: modelName,
+	}
+	return &embedder
+}
+
+// EmbedText 将文本转换为向量
+// 参数 text: 需要向量化的文本
+// 返回: 向量、error
+func (e *Embedder) EmbedText(text string) ([]float32, error) {
+	embeddings, err := e.ollama.Embed(e.modelName, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	if len(embeddings) == 0 {
+		return nil, fmt.Errorf("empty embedding")
+	}
+	return embeddings[0], nil
+}
+
+// EmbedTexts 将多个文本转换为向量，一次请求完成
+// 参数 texts: 需要向量化的文本
+// 返回: 与文本一一对应的向量、error
+func (e *Embedder) EmbedTexts(texts []string) ([][]float32, error) {
+	return e.ollama.Embed(e.modelName, texts)
+}
+
+// EmbedModelName 获取使用的嵌入模型名称
+func (e *Embedder) EmbedModelName() string {
+	return e.modelName
+}
@@ -0,0 +1,64 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+)
+
+// KnowledgeState 知识库索引状态
+type KnowledgeState string
+
+const (
+	// KnowledgePending 等待建立索引
+	KnowledgePending KnowledgeState = "pending"
+	// KnowledgeIndexing 正在建立索引
+	KnowledgeIndexing KnowledgeState = "indexing"
+	// KnowledgeReady 索引完成，可以检索
+	KnowledgeReady KnowledgeState = "ready"
+	// KnowledgeFailed 建立索引失败
+	KnowledgeFailed KnowledgeState = "failed"
+)
+
+// ErrKnowledgeNotFound 没有指定名称的知识库
+var ErrKnowledgeNotFound = errors.New("knowledge base not found")
+
+// ErrDocumentConflict 上传的文档 ID 与规则配置的源文件 ID 冲突
+// 配置的源文件在重新索引时会覆盖同 ID 的文档，因此拒绝上传
+var ErrDocumentConflict = errors.New("document id is used by a configured source")
+
+// ErrKnowledgeIndexing 知识库尚未完成索引，专家暂时无法回答
+var ErrKnowledgeIndexing = errors.New("knowledge base is indexing")
+
+// KnowledgeStatus 专家知识库的索引进度
+type KnowledgeStatus struct {
+	Name       string         `json:"name"`            // 专家名称
+	Source     string         `json:"source"`          // 知识库源文件和通配符
+	Documents  int            `json:"documents"`       // 知识库中的文档数
+	State      KnowledgeState `json:"state"`           // 索引状态
+	Current    int            `json:"current"`         // 已处理的文本块数
+	Total      int            `json:"total"`           // 文本块总数
+	Percentage float32        `json:"percentage"`      // 完成百分比
+	Reused     int            `json:"reused"`          // 复用已有向量的文本块数
+	Errors     int            `json:"errors"`          // 处理失败的文本块数
+	Error      string         `json:"error,omitempty"` // 建立索引失败的原因
+}
+
+// IndexingError 知识库尚未就绪的错误，携带当前进度
+// Error 返回面向用户的提示，可以用 errors.Is(err, ErrKnowledgeIndexing) 识别
+type IndexingError struct {
+	Status KnowledgeStatus // 知识库索引进度
+}
+
+// Error 返回知识库尚未就绪时回复给用户的提示
+func (e *IndexingError) Error() string {
+	if e.Status.Total == 0 {
+		return "知识库正在建立索引，请稍后再试。"
+	}
+	return fmt.Sprintf("知识库正在建立索引（进度 %.1f%%，第%d项，共%d项），请稍后再试。",
+		e.Status.Percentage, e.Status.Current, e.Status.Total)
+}
+
+// Unwrap 使 errors.Is 可以匹配 ErrKnowledgeIndexing
+func (e *IndexingError) Unwrap() error {
+	return ErrKnowledgeIndexing
+}
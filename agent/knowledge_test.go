@@ -0,0 +1,141 @@
+package agent
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go-ollama/logger"
+	"go-ollama/rag"
+	"go-ollama/rule"
+)
+
+// fakeRag 测试用的 RAG 管理器，AddDocuments 依次返回预设的结果
+type fakeRag struct {
+	mu     sync.Mutex
+	starts []func() (chan rag.ProgressInfo, error) // 每次建立索引的结果
+	docs   []rag.DocumentInfo                      // 知识库中的文档
+}
+
+func (f *fakeRag) OpenCollection(name string, options rag.CollectionOptions) (*rag.RagContext, error) {
+	return nil, nil
+}
+
+func (f *fakeRag) AddDocuments(ragCtx *rag.RagContext, sources []rag.DocumentSource) (chan rag.ProgressInfo, error) {
+	f.mu.Lock()
+	start := f.starts[0]
+	f.starts = f.starts[1:]
+	f.mu.Unlock()
+	return start()
+}
+
+func (f *fakeRag) DeleteDocument(ragCtx *rag.RagContext, docId string) error {
+	return nil
+}
+
+func (f *fakeRag) ListDocuments(ragCtx *rag.RagContext) []rag.DocumentInfo {
+	return f.docs
+}
+
+func (f *fakeRag) Reindex(ragCtx *rag.RagContext) (chan rag.ProgressInfo, error) {
+	return f.AddDocuments(ragCtx, nil)
+}
+
+func (f *fakeRag) Query(ragCtx *rag.RagContext, text string, history string, rule *rule.Rule) (chan string, error) {
+	ch := make(chan string, 1)
+	ch <- "source of " + text
+	close(ch)
+	return ch, nil
+}
+
+// waitKnowledgeState 等待知识库进入指定状态
+func waitKnowledgeState(t *testing.T, specialist *Specialist, state KnowledgeState) KnowledgeStatus {
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if status := specialist.knowledgeStatus(); status.State == state {
+			return status
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("knowledge state %s, expected %s", specialist.knowledgeStatus().State, state)
+	return KnowledgeStatus{}
+}
+
+func TestSpecialistIndexing(t *testing.T) {
+	errorLogger, err := logger.NewErrorLogger(filepath.Join(t.TempDir(), "test.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	progress := make(chan rag.ProgressInfo)
+	fake := &fakeRag{starts: []func() (chan rag.ProgressInfo, error){
+		func() (chan rag.ProgressInfo, error) { return nil, errors.New("embed model unavailable") },
+		func() (chan rag.ProgressInfo, error) { return progress, nil },
+	}}
+	specialist := newSpecialist(nil, fake, "model", &rule.Rule{}, nil, nil, errorLogger)
+
+	{ // case failed first index
+		specialist.index(specialist.indexSources)
+		status := specialist.knowledgeStatus()
+		if status.State != KnowledgeFailed || status.Error != "embed model unavailable" {
+			t.Fatalf("unexpected status: %+v", status)
+		}
+		// 重试间隔内直接返回失败
+		if _, err := specialist.search("q", ""); err == nil || errors.Is(err, ErrKnowledgeIndexing) {
+			t.Fatal("expected knowledge unavailable", err)
+		}
+	}
+	{ // case retry on query after the retry delay
+		specialist.statusMu.Lock()
+		if specialist.retryDelay != indexRetryDelay {
+			t.Fatal("unexpected retry delay", specialist.retryDelay)
+		}
+		specialist.retryAt = time.Now()
+		specialist.statusMu.Unlock()
+
+		if _, err := specialist.search("q", ""); !errors.Is(err, ErrKnowledgeIndexing) {
+			t.Fatal("expected indexing", err)
+		}
+		progress <- rag.ProgressInfo{Current: 1, Total: 2, Percentage: 50}
+		status := waitKnowledgeState(t, specialist, KnowledgeIndexing)
+		for status.Current != 1 {
+			time.Sleep(time.Millisecond)
+			status = specialist.knowledgeStatus()
+		}
+		_, err := specialist.search("q", "")
+		var indexing *IndexingError
+		if !errors.As(err, &indexing) || indexing.Status.Total != 2 || !strings.Contains(err.Error(), "50.0%") {
+			t.Fatal("expected indexing progress", err)
+		}
+	}
+	{ // case ready
+		progress <- rag.ProgressInfo{Current: 2, Total: 2, Percentage: 100}
+		close(progress)
+		waitKnowledgeState(t, specialist, KnowledgeReady)
+		source, err := specialist.search("q", "")
+		if err != nil || source != "source of q" {
+			t.Fatal("expected search result", source, err)
+		}
+		specialist.statusMu.RLock()
+		defer specialist.statusMu.RUnlock()
+		if specialist.retryDelay != 0 {
+			t.Fatal("retry delay not reset", specialist.retryDelay)
+		}
+	}
+}
+
+func TestConfiguredDocument(t *testing.T) {
+	fake := &fakeRag{docs: []rag.DocumentInfo{
+		{Id: "hp.txt", Origin: rag.OriginConfig},
+		{Id: "notes.txt", Origin: rag.OriginUpload},
+	}}
+	specialist := newSpecialist(nil, fake, "model", &rule.Rule{}, nil, nil, nil)
+	for id, expected := range map[string]bool{"hp.txt": true, "notes.txt": false, "new.txt": false} {
+		configured, err := specialist.isConfiguredDocument(id)
+		if err != nil || configured != expected {
+			t.Fatal("unexpected configured", id, configured, err)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"go-ollama/mcp"
+	"net/url"
+	"strings"
+)
+
+// mcpServerVersion 对外提供的 MCP 服务版本
+const mcpServerVersion = "1.0.0"
+
+// mcpSessionPrefix MCP 调用未指定会话 ID 时，使用 MCP 连接的会话 ID 加此前缀作为对话会话
+// 不同的 MCP 客户端各自使用独立的对话历史
+const mcpSessionPrefix = "mcp-"
+
+// knowledgeUriPrefix 知识库资源 URI 前缀，知识库资源为 knowledge://专家名称
+const knowledgeUriPrefix = "knowledge://"
+
+// chatToolSchema 对话工具的参数 JSON Schema
+const chatToolSchema = `{"type":"object","properties":{` +
+	`"question":{"type":"string","description":"问题"},` +
+	`"session_id":{"type":"string","description":"会话 ID，相同会话 ID 的提问共享对话历史，可选"}},` +
+	`"required":["question"]}`
+
+// NewMcpServer 将 Agent 系统包装为 MCP 服务
+// 工具：chat 走完整的协调者流程，ask_专家名称 直接向对应专家提问
+// 资源：每个 RAG 知识库为一个资源，通过 knowledge://专家名称?query=问题 检索
+// MCP 会话结束时删除该会话的对话历史
+// 参数 agentMgr: Agent 管理器
+// 返回: MCP 服务端
+func NewMcpServer(agentMgr AgentManager) *mcp.Server {
+	server := mcp.NewServer("go-ollama", mcpServerVersion)
+	// MCP 会话结束时删除对应的对话，未提问过的会话没有对话，忽略不存在的错误
+	server.OnSessionClose(func(session string) {
+		agentMgr.DeleteConversation(mcpSessionPrefix + session)
+	})
+
+	server.AddTool(mcp.Tool{
+		Name:        "chat",
+		Description: "向本地多专家问答系统提问，由协调者选择最合适的专家回答",
+		InputSchema: json.RawMessage(chatToolSchema),
+	}, func(session string, args json.RawMessage) (string, error) {
+		question, sessionId, err := parseChatArgs(args, session)
+		if err != nil {
+			return "", err
+		}
+		return agentMgr.Ask(sessionId, question)
+	})
+
+	for _, info := range agentMgr.Specialists() {
+		name := info.Name
+		server.AddTool(mcp.Tool{
+			Name:        "ask_" + name,
+			Description: "直接向专家 " + name + " 提问。" + info.Introduction,
+			InputSchema: json.RawMessage(chatToolSchema),
+		}, func(session string, args json.RawMessage) (string, error) {
+			question, sessionId, err := parseChatArgs(args, session)
+			if err != nil {
+				return "", err
+			}
+			return agentMgr.AskSpecialist(sessionId, name, question)
+		})
+
+		if !info.HasKnowledge {
+			continue
+		}
+		uri := knowledgeUriPrefix + name
+		server.AddResource(mcp.Resource{
+			Uri:         uri,
+			Name:        name + " knowledge base",
+			Description: info.Introduction,
+			MimeType:    "text/plain",
+		}, func(string) (string, error) {
+			return "专家 " + name + " 的知识库。" + info.Introduction +
+				"\n使用 " + uri + "?query=问题 检索相关内容。", nil
+		})
+		server.AddResourceTemplate(mcp.ResourceTemplate{
+			UriTemplate: uri + "{?query}",
+			Name:        name + " knowledge search",
+			Description: "检索专家 " + name + " 的知识库，返回与问题相关的文档",
+			MimeType:    "text/plain",
+		}, func(resourceUri string) (string, error) {
+			query, err := parseKnowledgeQuery(resourceUri, uri)
+			if err != nil {
+				return "", err
+			}
+			return agentMgr.SearchKnowledge(name, query)
+		})
+	}
+	return server
+}
+
+// parseChatArgs 解析对话工具的参数
+// 参数 args: 工具参数
+// 参数 session: MCP 连接的会话 ID，参数未指定会话 ID 时使用
+// 返回: 问题、会话 ID、error
+func parseChatArgs(args json.RawMessage, session string) (string, string, error) {
+	var params struct {
+		Question  string `json:"question"`
+		SessionId string `json:"session_id"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return "", "", fmt.Errorf("invalid arguments: %v", err)
+	}
+	if params.Question == "" {
+		return "", "", fmt.Errorf("question is required")
+	}
+	if params.SessionId == "" {
+		params.SessionId = mcpSessionPrefix + session
+	}
+	return params.Question, params.SessionId, nil
+}
+
+// parseKnowledgeQuery 从知识库检索 URI 中解析问题
+// 参数 resourceUri: 请求的 URI，如 knowledge://hp?query=斯内普
+// 参数 uri: 知识库 URI，如 knowledge://hp
+// 返回: 问题、error
+func parseKnowledgeQuery(resourceUri string, uri string) (string, error) {
+	rawQuery, ok := strings.CutPrefix(resourceUri, uri+"?")
+	if !ok {
+		return "", fmt.Errorf("resource not found: %s", resourceUri)
+	}
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return "", fmt.Errorf("invalid query: %v", err)
+	}
+	query := values.Get("query")
+	if query == "" {
+		return "", fmt.Errorf("query is required")
+	}
+	return query, nil
+}
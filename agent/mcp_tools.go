@@ -0,0 +1,160 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"go-ollama/logger"
+	"go-ollama/mcp"
+	"go-ollama/rule"
+	"strings"
+)
+
+// mcpToolSeparator MCP 工具名称中服务名和工具名的分隔符
+// 注册到工具表的名称为 服务名__工具名，避免不同服务的同名工具冲突
+const mcpToolSeparator = "__"
+
+// connectMcpServers 连接配置的所有 MCP 服务，并将其工具、资源和提示词注册到工具表
+// 连接失败的服务只记录错误，不影响其他服务；注册失败（如工具名冲突）属于配置错误，关闭所有服务并返回错误
+// 参数 configs: MCP 服务配置
+// 参数 registry: 工具注册表
+// 参数 logger: 日志记录器
+// 返回: 已连接的客户端、error
+func connectMcpServers(configs map[string]rule.McpServerConfig, registry *ToolRegistry, logger logger.ErrorLogger) ([]*mcp.Client, error) {
+	var clients []*mcp.Client
+	for name, cfg := range configs {
+		var client *mcp.Client
+		var err error
+		if cfg.Command != "" {
+			client, err = mcp.NewStdioClient(name, cfg.Command, cfg.Args, cfg.Env)
+		} else if cfg.Url != "" {
+			client, err = mcp.NewHttpClient(name, cfg.Url, cfg.Headers)
+		} else {
+			err = fmt.Errorf("mcp server %s needs command or url", name)
+		}
+		if err != nil {
+			logger.LogError(err, "mcp connect", name)
+			continue
+		}
+		clients = append(clients, client)
+		if err := registerMcpClient(client, registry); err != nil {
+			for _, c := range clients {
+				c.Close()
+			}
+			return nil, fmt.Errorf("mcp server %s: %w", name, err)
+		}
+		logger.LogInfo("mcp: connected " + name + " (" + client.ServerInfo().Name + " " + client.ServerInfo().Version + ")")
+	}
+	return clients, nil
+}
+
+// registerMcpClient 将 MCP 服务的能力注册为工具组，组名为服务名
+// 工具：每个工具注册为 服务名__工具名
+// 资源：注册 服务名__read_resource，参数为资源 URI
+// 提示词：注册 服务名__get_prompt，参数为提示词名称和模板参数
+// 参数 client: MCP 客户端
+// 参数 registry: 工具注册表
+// 返回: error
+func registerMcpClient(client *mcp.Client, registry *ToolRegistry) error {
+	group := client.Name()
+	prefix := group + mcpToolSeparator
+
+	if client.HasTools() {
+		tools, err := client.ListTools()
+		if err != nil {
+			return fmt.Errorf("list tools: %w", err)
+		}
+		for _, tool := range tools {
+			toolName := tool.Name
+			schema := tool.InputSchema
+			if len(schema) == 0 {
+				schema = json.RawMessage(`{"type":"object","properties":{}}`)
+			}
+			err := registry.registerGroup(group, prefix+toolName, tool.Description, schema,
+				func(args json.RawMessage) (string, error) {
+					return client.CallTool(toolName, args)
+				})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if client.HasResources() {
+		resources, err := client.ListResources()
+		if err != nil {
+			return fmt.Errorf("list resources: %w", err)
+		}
+		if len(resources) > 0 {
+			uris := make([]string, 0, len(resources))
+			lines := make([]string, 0, len(resources))
+			for _, resource := range resources {
+				uris = append(uris, resource.Uri)
+				lines = append(lines, resource.Uri+" "+resource.Name+" "+resource.Description)
+			}
+			schema, _ := json.Marshal(map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"uri": map[string]interface{}{"type": "string", "enum": uris, "description": "资源 URI"},
+				},
+				"required": []string{"uri"},
+			})
+			err := registry.registerGroup(group, prefix+"read_resource",
+				"读取 "+group+" 提供的资源，可用资源：\n"+strings.Join(lines, "\n"), schema,
+				func(args json.RawMessage) (string, error) {
+					var params struct {
+						Uri string `json:"uri"`
+					}
+					if err := json.Unmarshal(args, &params); err != nil {
+						return "", fmt.Errorf("invalid arguments: %v", err)
+					}
+					return client.ReadResource(params.Uri)
+				})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if client.HasPrompts() {
+		prompts, err := client.ListPrompts()
+		if err != nil {
+			return fmt.Errorf("list prompts: %w", err)
+		}
+		if len(prompts) > 0 {
+			names := make([]string, 0, len(prompts))
+			lines := make([]string, 0, len(prompts))
+			for _, prompt := range prompts {
+				names = append(names, prompt.Name)
+				var args []string
+				for _, arg := range prompt.Arguments {
+					args = append(args, arg.Name)
+				}
+				lines = append(lines, prompt.Name+"("+strings.Join(args, ", ")+") "+prompt.Description)
+			}
+			schema, _ := json.Marshal(map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"name":      map[string]interface{}{"type": "string", "enum": names, "description": "提示词名称"},
+					"arguments": map[string]interface{}{"type": "object", "description": "提示词模板参数", "additionalProperties": map[string]string{"type": "string"}},
+				},
+				"required": []string{"name"},
+			})
+			err := registry.registerGroup(group, prefix+"get_prompt",
+				"获取 "+group+" 提供的提示词模板，可用提示词：\n"+strings.Join(lines, "\n"), schema,
+				func(args json.RawMessage) (string, error) {
+					var params struct {
+						Name      string            `json:"name"`
+						Arguments map[string]string `json:"arguments"`
+					}
+					if err := json.Unmarshal(args, &params); err != nil {
+						return "", fmt.Errorf("invalid arguments: %v", err)
+					}
+					return client.GetPrompt(params.Name, params.Arguments)
+				})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package agent
+
+import (
+	"fmt"
+	"go-ollama/logger"
+	"go-ollama/ollama"
+	"go-ollama/rule"
+	"strings"
+)
+
+// modelSelector 模型选择器，按配置的回退顺序为各角色选择可用的模型
+// 启动时根据 Ollama 的可用模型列表校验，配置的模型不可用时记录日志并尝试下一个
+type modelSelector struct {
+	ollama ollama.OllamaManager // Ollama 管理器
+	models rule.ModelsConfig    // 全局的模型配置
+	logger logger.ErrorLogger   // 日志记录器
+}
+
+// newModelSelector 创建模型选择器
+func newModelSelector(ollama ollama.OllamaManager, models rule.ModelsConfig, logger logger.ErrorLogger) *modelSelector {
+	return &modelSelector{ollama: ollama, models: models, logger: logger}
+}
+
+// specialist 选择专家使用的模型
+// 回退顺序：规则 model -> models.specialist -> models.default -> 默认 LLM
+func (m *modelSelector) specialist(rule *rule.Rule) (string, error) {
+	name := rule.Name()
+	if name == "" {
+		name = "general"
+	}
+	return m.resolve("specialist "+name, rule.Model(), m.models.Specialist)
+}
+
+// reviewer 选择评审者使用的模型
+// 回退顺序：规则 reviewer_model -> models.reviewer -> models.default -> 默认 LLM
+func (m *modelSelector) reviewer(rule *rule.Rule) (string, error) {
+	return m.resolve("reviewer "+rule.Name(), rule.ReviewerModel(), m.models.Reviewer)
+}
+
+// coordinator 选择协调者使用的模型
+// 回退顺序：models.coordinator -> models.default -> 默认 LLM
+func (m *modelSelector) coordinator() (string, error) {
+	return m.resolve("coordinator", m.models.Coordinator)
+}
+
+// reranker 选择重排器使用的模型
+// 回退顺序：models.reranker -> models.default -> 默认 LLM
+func (m *modelSelector) reranker() (string, error) {
+	return m.resolve("reranker", m.models.Reranker)
+}
+
+// rewriter 选择查询改写使用的模型
+// 回退顺序：models.rewriter -> models.reranker -> models.default -> 默认 LLM
+func (m *modelSelector) rewriter() (string, error) {
+	return m.resolve("rewriter", m.models.Rewriter, m.models.Reranker)
+}
+
+// embed 选择向量化使用的嵌入模型
+// 回退顺序：models.embed -> 名称包含 embed 的可用模型
+// 嵌入模型不能回退到 LLM，所以不使用 models.default
+func (m *modelSelector) embed() (string, error) {
+	if m.models.Embed != "" {
+		if model := m.ollama.ResolveModelName(m.models.Embed); model != "" {
+			m.logger.LogInfo("model embed: " + model)
+			return model, nil
+		}
+		m.logger.LogError(fmt.Errorf("model not available: %s", m.models.Embed), "model", "embed")
+	}
+	if model := m.ollama.GetDefaultEmbedModelName(); model != "" {
+		m.logger.LogInfo("model embed: " + model + " (default)")
+		return model, nil
+	}
+	return "", fmt.Errorf("no available embed model")
+}
+
+// rerankEmbed 选择嵌入重排和 MMR 使用的嵌入模型
+// 回退顺序：models.rerank_embed -> 向量化使用的嵌入模型
+// 嵌入重排不能使用向量化的模型，回退后打开知识库时报错
+// 参数 embedModel: 向量化使用的嵌入模型
+func (m *modelSelector) rerankEmbed(embedModel string) string {
+	if m.models.RerankEmbed != "" {
+		if model := m.ollama.ResolveModelName(m.models.RerankEmbed); model != "" {
+			m.logger.LogInfo("model rerank embed: " + model)
+			return model
+		}
+		m.logger.LogError(fmt.Errorf("model not available: %s", m.models.RerankEmbed), "model", "rerank embed")
+	}
+	return embedModel
+}
+
+// resolve 按顺序尝试候选模型，最后依次回退到 models.default 和默认 LLM
+// 参数 role: 角色名称，用于日志
+// 参数 names: 按优先级排列的候选模型名称，空字符串会被跳过
+// 返回: 可用的完整模型名称、error（所有候选都不可用时）
+func (m *modelSelector) resolve(role string, names ...string) (string, error) {
+	names = append(names, m.models.Default)
+	var tried []string
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+		if model := m.ollama.ResolveModelName(name); model != "" {
+			m.logger.LogInfo("model " + role + ": " + model)
+			return model, nil
+		}
+		m.logger.LogError(fmt.Errorf("model not available: %s", name), "model", role)
+		tried = append(tried, name)
+	}
+	if model := m.ollama.GetDefaultLlmModelName(); model != "" {
+		m.logger.LogInfo("model " + role + ": " + model + " (default)")
+		return model, nil
+	}
+	return "", fmt.Errorf("no available model for %s, tried: %s", role, strings.Join(tried, ", "))
+}
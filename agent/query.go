@@ -0,0 +1,144 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"go-ollama/ollama"
+	"go-ollama/rule"
+	"strings"
+)
+
+// QueryTransformer 查询改写器，在 RAG 检索前改写和扩展问题
+// 使用 LLM 将追问改写为独立的问题、生成不同表述的问题和假设性回答
+type QueryTransformer struct {
+	ollama    ollama.OllamaManager // Ollama 管理器
+	modelName string               // 使用的模型名称
+	rule      rule.RuleManager     // 规则管理器，包含查询改写提示词模板
+}
+
+// newQueryTransformer 创建并初始化查询改写器实例
+// 参数 modelName: 使用的模型名称
+func newQueryTransformer(ollama ollama.OllamaManager, modelName string, rule rule.RuleManager) *QueryTransformer {
+	transformer := QueryTransformer{
+		ollama:    ollama,
+		modelName: modelName,
+		rule:      rule,
+	}
+	return &transformer
+}
+
+// RewriteQuery 结合对话历史将追问改写为独立的问题
+// 参数 text: 用户问题
+// 参数 history: 最近的对话历史
+// 参数 language: 问题的语言，使用该语言的提示词模板
+// 返回: 改写后的问题、error
+func (q *QueryTransformer) RewriteQuery(text string, history string, language string) (string, error) {
+	message := q.rule.Messages(language).QueryRewriteMessage(history, text)
+	rewritten, err := q.ollama.ChatWithoutContext(q.modelName, message)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(rewritten), nil
+}
+
+// ExpandQuery 生成不同表述的问题，用于多查询检索
+// 优先使用 JSON Schema 约束模型按数组返回，失败时降级为文本回复，每行一个问题
+// 回复是不完整的 JSON 时，恢复其中完整的问题，无法恢复时返回错误
+// 参数 text: 用户问题
+// 参数 num: 生成的问题数量
+// 参数 language: 问题的语言，使用该语言的提示词模板
+// 返回: 问题数组、error
+func (q *QueryTransformer) ExpandQuery(text string, num int, language string) ([]string, error) {
+	message := q.rule.Messages(language).QueryExpandMessage(text, num)
+
+	var expanded expandedQueries
+	raw, err := q.ollama.ChatWithoutContextJSON(q.modelName, message, expandSchema(num), &expanded)
+	if err == nil {
+		return expanded.Queries, nil
+	}
+	if raw == "" {
+		// 请求失败（例如模型不支持 format），降级为普通文本回复
+		if raw, err = q.ollama.ChatWithoutContext(q.modelName, message); err != nil {
+			return nil, err
+		}
+	}
+	if trimmed := strings.TrimSpace(raw); strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+		return recoverQueries(trimmed)
+	}
+	var queries []string
+	for _, line := range strings.Split(raw, "\n") {
+		// 去掉列表序号和符号
+		line = strings.TrimLeft(strings.TrimSpace(line), "-*•0123456789.、) ")
+		if line != "" {
+			queries = append(queries, line)
+		}
+	}
+	return queries, nil
+}
+
+// HypotheticalAnswer 生成问题的假设性回答（HyDE），用回答的向量检索与答案措辞相近的文档
+// 参数 text: 用户问题
+// 参数 language: 问题的语言，使用该语言的提示词模板
+// 返回: 假设性回答、error
+func (q *QueryTransformer) HypotheticalAnswer(text string, language string) (string, error) {
+	message := q.rule.Messages(language).HydeMessage(text)
+	return q.ollama.ChatWithoutContext(q.modelName, message)
+}
+
+// recoverQueries 从不完整的 JSON 回复中恢复问题数组
+// 读取 queries 数组（或顶层数组）中的字符串，直到数组结束或 JSON 出错
+// 参数 raw: 模型回复
+// 返回: 问题数组、error，没有恢复出问题时返回错误
+func recoverQueries(raw string) ([]string, error) {
+	array := raw
+	if index := strings.Index(raw, `"queries"`); index >= 0 {
+		array = raw[index+len(`"queries"`):]
+	}
+	start := strings.IndexByte(array, '[')
+	if start < 0 {
+		return nil, fmt.Errorf("malformed expand reply: %s", raw)
+	}
+	decoder := json.NewDecoder(strings.NewReader(array[start:]))
+	decoder.Token() // 数组开始的 [
+	var queries []string
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		query, ok := token.(string)
+		if !ok {
+			break
+		}
+		if query = strings.TrimSpace(query); query != "" {
+			queries = append(queries, query)
+		}
+	}
+	if len(queries) == 0 {
+		return nil, fmt.Errorf("malformed expand reply: %s", raw)
+	}
+	return queries, nil
+}
+
+// expandedQueries 多查询扩展的结构化输出
+type expandedQueries struct {
+	Queries []string `json:"queries"` // 不同表述的问题
+}
+
+// expandSchema 构建多查询扩展输出的 JSON Schema
+// 参数 num: 问题数量上限
+// 返回: JSON Schema
+func expandSchema(num int) json.RawMessage {
+	schema, _ := json.Marshal(map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"queries": map[string]interface{}{
+				"type":     "array",
+				"items":    map[string]interface{}{"type": "string"},
+				"maxItems": num,
+			},
+		},
+		"required": []string{"queries"},
+	})
+	return schema
+}
@@ -0,0 +1,70 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"go-ollama/ollama"
+	"go-ollama/rule"
+)
+
+// fakeOllama 测试用的 Ollama 管理器，只实现用到的方法
+// JSON 请求返回 jsonReply 并按 JSON 解析，jsonReply 为空时模拟请求失败；普通请求返回 reply
+type fakeOllama struct {
+	ollama.OllamaManager
+	jsonReply string
+	reply     string
+}
+
+func (f *fakeOllama) ChatWithoutContextJSON(modelName string, message string, schema json.RawMessage, result interface{}) (string, error) {
+	if f.jsonReply == "" {
+		return "", fmt.Errorf("format not supported")
+	}
+	if err := json.Unmarshal([]byte(f.jsonReply), result); err != nil {
+		return f.jsonReply, err
+	}
+	return f.jsonReply, nil
+}
+
+func (f *fakeOllama) ChatWithoutContext(modelName string, message string) (string, error) {
+	return f.reply, nil
+}
+
+// fakeRuleManager 测试用的规则管理器，提示词模板为空
+type fakeRuleManager struct {
+	rule.RuleManager
+}
+
+func (fakeRuleManager) Messages(language string) rule.Messages {
+	return rule.Messages{}
+}
+
+func TestExpandQuery(t *testing.T) {
+	expand := func(fake *fakeOllama) ([]string, error) {
+		return newQueryTransformer(fake, "model", fakeRuleManager{}).ExpandQuery("q", 3, "")
+	}
+	{ // case json reply
+		queries, err := expand(&fakeOllama{jsonReply: `{"queries": ["a", "b"]}`})
+		if err != nil || len(queries) != 2 || queries[1] != "b" {
+			t.Fatal("unexpected queries", queries, err)
+		}
+	}
+	{ // case truncated json recovers complete queries
+		queries, err := expand(&fakeOllama{jsonReply: "{\"queries\": [\n  \"a\",\n  \"b\",\n  \"c"})
+		if err != nil || len(queries) != 2 || queries[0] != "a" || queries[1] != "b" {
+			t.Fatal("unexpected queries", queries, err)
+		}
+	}
+	{ // case malformed json without queries
+		if queries, err := expand(&fakeOllama{jsonReply: `{"queries": `}); err == nil {
+			t.Fatal("expected error", queries)
+		}
+	}
+	{ // case text fallback
+		queries, err := expand(&fakeOllama{reply: "1. a\n- b\n"})
+		if err != nil || len(queries) != 2 || queries[0] != "a" || queries[1] != "b" {
+			t.Fatal("unexpected queries", queries, err)
+		}
+	}
+}
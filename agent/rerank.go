@@ -1,12 +1,19 @@
 package agent
 
 import (
+	"encoding/json"
+	"fmt"
 	"go-ollama/ollama"
+	"go-ollama/rag"
 	"go-ollama/rule"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 )
 
 // Reranker 重排器，用于对 RAG 检索结果进行重排
-// 使用 LLM 评估检索到的候选文档与问题的相关性，选择最相关的文档
+// 使用 LLM 为检索到的候选文档与问题的相关性打分，返回最相关的文档序号
 type Reranker struct {
 	ollama    ollama.OllamaManager // Ollama 管理器
 	modelName string               // 使用的模型名称
@@ -14,26 +21,111 @@ type Reranker struct {
 }
 
 // newReranker 创建并初始化重排序器实例
-func newReranker(ollama ollama.OllamaManager, rule rule.RuleManager) *Reranker {
+// 参数 modelName: 使用的模型名称
+func newReranker(ollama ollama.OllamaManager, modelName string, rule rule.RuleManager) *Reranker {
 	reranker := Reranker{
 		ollama:    ollama,
-		modelName: ollama.GetAvailableModelName("gemma"),
+		modelName: modelName,
 		rule:      rule,
 	}
 	return &reranker
 }
 
-// RankCandidate 对候选文档进行重排序
-// 使用 LLM 评估每个候选文档与问题的相关性，返回最相关的文档
-// 参数 candidates: 候选文档文本，多个文档用换行分隔
+// RankCandidates 对候选文档进行重排序
+// 使用 LLM 为每个候选文档与问题的相关性打分（0-10），按分数从高到低返回候选文档的序号
+// 候选文档按序号编号后发送，模型只需要回复序号和分数，不需要复述原文
+// 参数 candidates: 候选文档
 // 参数 text: 用户问题
-// 参数 num: 返回的文档数量
-// 返回: 重排序后的文档文本、error
-func (r *Reranker) RankCandidate(candidates string, text string, num int) (string, error) {
-	message := r.rule.RerankMessage(candidates, text, num)
-	result, err := r.ollama.ChatWithoutContext(r.modelName, message)
+// 参数 num: 需要的文档数量，用于提示词
+// 参数 language: 问题的语言，使用该语言的重排提示词模板
+// 优先使用 JSON Schema 约束模型按数组返回序号和分数，失败时降级为文本回复
+// 返回: 按评分从高到低排列的所有序号和评分（换算为 0-1）、error
+// 模型回复的序号可能越界或重复，由调用者过滤并截取前 num 个
+func (r *Reranker) RankCandidates(candidates []string, text string, num int, language string) ([]rag.RankedCandidate, error) {
+	var numbered strings.Builder
+	for i, candidate := range candidates {
+		fmt.Fprintf(&numbered, "[%d] %s\n", i, strings.ReplaceAll(candidate, "\n", " "))
+	}
+	message := r.rule.Messages(language).RerankMessage(numbered.String(), text, num)
+
+	var scores candidateScores
+	raw, err := r.ollama.ChatWithoutContextJSON(r.modelName, message, rerankSchema(len(candidates)), &scores)
 	if err != nil {
-		return "", err
+		if raw == "" {
+			// 请求失败（例如模型不支持 format），降级为普通文本回复
+			if raw, err = r.ollama.ChatWithoutContext(r.modelName, message); err != nil {
+				return nil, err
+			}
+		}
+		// 模型输出不是合法 JSON，按文本解析序号和分数
+		scores = parseCandidateScores(raw)
+	}
+
+	ranked := make([]rag.RankedCandidate, 0, len(scores.Scores))
+	for _, score := range scores.Scores {
+		ranked = append(ranked, rag.RankedCandidate{Index: score.Index, Score: min(max(score.Score, 0), maxRerankScore) / maxRerankScore})
 	}
-	return result, nil
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].Score > ranked[j].Score
+	})
+	return ranked, nil
+}
+
+// maxRerankScore 重排评分的满分
+const maxRerankScore = 10
+
+// candidateScores 重排器的结构化输出
+type candidateScores struct {
+	Scores []candidateScore `json:"scores"` // 候选文档的评分
+}
+
+// candidateScore 单个候选文档的评分
+type candidateScore struct {
+	Index int     `json:"index"` // 候选文档的序号
+	Score float64 `json:"score"` // 相关性评分（0-10）
+}
+
+// candidateScoreRegexp 匹配文本回复中的 "序号: 评分"，序号可以带方括号
+var candidateScoreRegexp = regexp.MustCompile(`\[?(\d+)\]?\s*[:：,，=\-]\s*(\d+(?:\.\d+)?)`)
+
+// parseCandidateScores 从文本回复中解析序号和评分，每行一个候选文档
+// 参数 text: 模型的文本回复
+// 返回: 解析出的评分
+func parseCandidateScores(text string) candidateScores {
+	var scores candidateScores
+	for _, line := range strings.Split(text, "\n") {
+		match := candidateScoreRegexp.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		index, _ := strconv.Atoi(match[1])
+		score, _ := strconv.ParseFloat(match[2], 64)
+		scores.Scores = append(scores.Scores, candidateScore{Index: index, Score: score})
+	}
+	return scores
+}
+
+// rerankSchema 构建重排器输出的 JSON Schema
+// 参数 count: 候选文档的数量，也是评分数量的上限
+// 返回: JSON Schema
+func rerankSchema(count int) json.RawMessage {
+	schema, _ := json.Marshal(map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"scores": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"index": map[string]interface{}{"type": "integer", "minimum": 0, "maximum": count - 1},
+						"score": map[string]interface{}{"type": "number", "minimum": 0, "maximum": maxRerankScore},
+					},
+					"required": []string{"index", "score"},
+				},
+				"maxItems": count,
+			},
+		},
+		"required": []string{"scores"},
+	})
+	return schema
 }
@@ -0,0 +1,48 @@
+package agent
+
+import (
+	"testing"
+
+	"go-ollama/rag"
+)
+
+func TestParseCandidateScores(t *testing.T) {
+	{ // case text reply
+		scores := parseCandidateScores("[2]: 9\n0：3.5\n以上是评分\n1 - 0")
+		if len(scores.Scores) != 3 {
+			t.Fatalf("unexpected scores: %+v", scores)
+		}
+		if scores.Scores[0] != (candidateScore{Index: 2, Score: 9}) || scores.Scores[1] != (candidateScore{Index: 0, Score: 3.5}) || scores.Scores[2] != (candidateScore{Index: 1, Score: 0}) {
+			t.Fatalf("unexpected scores: %+v", scores)
+		}
+	}
+}
+
+func TestRankCandidates(t *testing.T) {
+	candidates := []string{"a", "b", "c"}
+	rank := func(fake *fakeOllama) []rag.RankedCandidate {
+		ranked, err := newReranker(fake, "model", fakeRuleManager{}).RankCandidates(candidates, "q", 1, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ranked
+	}
+	{ // case json reply returns all scores
+		ranked := rank(&fakeOllama{jsonReply: `{"scores": [{"index": 0, "score": 2}, {"index": 2, "score": 8}, {"index": 1, "score": 5}]}`})
+		if len(ranked) != 3 || ranked[0] != (rag.RankedCandidate{Index: 2, Score: 0.8}) || ranked[2].Index != 0 {
+			t.Fatalf("unexpected ranked: %+v", ranked)
+		}
+	}
+	{ // case text fallback keeps invalid indexes for the caller to filter
+		ranked := rank(&fakeOllama{reply: "[99]: 10\n[1]: 9\n[1]: 8\n[0]: 7"})
+		if len(ranked) != 4 || ranked[0].Index != 99 || ranked[3] != (rag.RankedCandidate{Index: 0, Score: 0.7}) {
+			t.Fatalf("unexpected ranked: %+v", ranked)
+		}
+	}
+	{ // case malformed json falls back to text parsing
+		ranked := rank(&fakeOllama{jsonReply: `[2]: 10`})
+		if len(ranked) != 1 || ranked[0] != (rag.RankedCandidate{Index: 2, Score: 1}) {
+			t.Fatalf("unexpected ranked: %+v", ranked)
+		}
+	}
+}
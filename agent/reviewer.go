@@ -1,6 +1,7 @@
 package agent
 
 import (
+	"encoding/json"
 	"go-ollama/logger"
 	"go-ollama/ollama"
 	"go-ollama/rule"
@@ -9,43 +10,66 @@ import (
 // Reviewer 评审者 Agent，负责评估专家生成答案的质量
 // 通过评分和评价来指导答案的改进
 type Reviewer struct {
-	ollama    ollama.OllamaManager // Ollama 管理器
-	modelName string               // 使用的模型名称
-	rule      *rule.Rule          // 规则配置，包含评审相关的提示词
-	chatCtx   *ollama.ChatContext // 对话上下文
-	logger    logger.ErrorLogger  // 日志记录器
+	ollama     ollama.OllamaManager // Ollama 管理器
+	modelName  string               // 使用的模型名称
+	rule       *rule.Rule           // 规则配置，包含评审相关的提示词
+	summarizer ollama.Summarizer    // 历史摘要提示词构建器
+	logger     logger.ErrorLogger   // 日志记录器
 }
 
+// reviewSchema 评审结果的 JSON Schema，约束模型输出分数和评价
+var reviewSchema = json.RawMessage(`{
+	"type": "object",
+	"properties": {
+		"score": {"type": "integer", "minimum": 0, "maximum": 100},
+		"review": {"type": "string"}
+	},
+	"required": ["score", "review"]
+}`)
+
 // newReviewer 创建并初始化评审者实例
-func newReviewer(ollama ollama.OllamaManager, rule *rule.Rule, logger logger.ErrorLogger) *Reviewer {
+// 参数 modelName: 使用的模型名称
+func newReviewer(ollama ollama.OllamaManager, modelName string, rule *rule.Rule, summarizer ollama.Summarizer, logger logger.ErrorLogger) *Reviewer {
 	reviewer := Reviewer{
-		ollama:    ollama,
-		modelName: ollama.GetAvailableModelName("gemma"),
-		rule:      rule,
-		logger:    logger,
+		ollama:     ollama,
+		modelName:  modelName,
+		rule:       rule,
+		summarizer: summarizer,
+		logger:     logger,
 	}
 	return &reviewer
 }
 
-// prepareChat 初始化评审者的对话上下文
-// 设置评审者的系统提示词
-func (r *Reviewer) prepareChat() {
-	r.chatCtx = r.ollama.NewChat(r.modelName, r.rule.ReviewerSystemMessage())
+// newChatContext 创建评审者的对话上下文
+// 设置评审者的系统提示词，每个会话拥有独立的对话上下文
+func (r *Reviewer) newChatContext() *ollama.ChatContext {
+	chatCtx := r.ollama.NewChat(r.modelName, r.rule.ReviewerSystemMessage())
+	chatCtx.SetContextWindow(contextWindowFromRule(r.rule, r.summarizer))
+	return chatCtx
 }
 
 // review 评审专家生成的答案
+// 参数 chatCtx: 会话中该评审者的对话上下文
 // 参数 question: 原始问题
 // 参数 answer: 专家生成的答案
+// 优先使用 JSON Schema 约束输出，失败时降级为文本解析
 // 返回: ReviewResult，包含评分和评价文本
-func (r *Reviewer) review(question string, answer string) rule.ReviewResult {
-	// 延迟初始化
-	if r.chatCtx == nil {
-		r.prepareChat()
-	}
+func (r *Reviewer) review(chatCtx *ollama.ChatContext, question string, answer string) rule.ReviewResult {
 	// 构建评审提示词
 	message := r.rule.ReviewMessage(question, answer)
-	// 调用 LLM 进行评审
-	review, err := r.ollama.NextChat(r.chatCtx, message)
+	// 调用 LLM 进行结构化评审
+	var result rule.ReviewResult
+	raw, err := r.ollama.NextChatJSON(chatCtx, message, reviewSchema, &result)
+	if err == nil {
+		return result
+	}
+	r.logger.LogError(err, "review json")
+	if raw != "" {
+		// 模型输出不是合法 JSON，按文本格式解析
+		return r.rule.ParseReview(raw)
+	}
+	// 请求失败（例如模型不支持 format），降级为普通文本评审
+	review, err := r.ollama.NextChat(chatCtx, message)
 	if err != nil {
 		// 如果评审失败，返回空结果
 		r.logger.LogError(err, "review")
@@ -0,0 +1,265 @@
+package agent
+
+import (
+	"errors"
+	"go-ollama/logger"
+	"go-ollama/ollama"
+	"go-ollama/store"
+	"sync"
+	"time"
+)
+
+// sessionIdleTimeout 会话空闲超时时间，超过此时间未活动的会话将被清理
+const sessionIdleTimeout = 30 * time.Minute
+
+// Session 会话，维护单个用户（浏览器）与各专家/评审者之间独立的对话上下文
+type Session struct {
+	id            string                         // 会话 ID
+	title         string                         // 标题，取会话的第一个问题
+	createdAt     time.Time                      // 创建时间
+	mu            sync.Mutex                     // 保证同一会话内的请求串行执行
+	lastActive    time.Time                      // 最后活动时间，用于空闲过期
+	specialistCtx map[string]*ollama.ChatContext // 专家名称到对话上下文的映射
+	reviewerCtx   map[string]*ollama.ChatContext // 评审者名称到对话上下文的映射
+	saved         *store.Conversation            // 从存储中恢复的会话，对话上下文在首次使用时恢复
+	deleted       bool                           // 会话已删除，持有会话锁时读写，release 时不再保存
+}
+
+// newSession 创建新的会话
+func newSession(id string) *Session {
+	now := time.Now()
+	return &Session{
+		id:            id,
+		createdAt:     now,
+		lastActive:    now,
+		specialistCtx: make(map[string]*ollama.ChatContext),
+		reviewerCtx:   make(map[string]*ollama.ChatContext),
+	}
+}
+
+// restoreSession 从存储的会话恢复会话
+func restoreSession(conv *store.Conversation) *Session {
+	session := newSession(conv.Id)
+	session.title = conv.Title
+	session.createdAt = conv.CreatedAt
+	session.saved = conv
+	return session
+}
+
+// maxTitleLength 会话标题的最大字符数
+const maxTitleLength = 50
+
+// setTitle 设置会话标题，只在第一个问题时设置
+func (s *Session) setTitle(chat string) {
+	if s.title == "" {
+		runes := []rune(chat)
+		s.title = string(runes[:min(len(runes), maxTitleLength)])
+	}
+}
+
+// conversation 导出会话，用于持久化
+// 尚未使用过的已存储对话上下文原样保留
+func (s *Session) conversation() *store.Conversation {
+	conv := &store.Conversation{
+		Id:          s.id,
+		Title:       s.title,
+		CreatedAt:   s.createdAt,
+		UpdatedAt:   time.Now(),
+		Specialists: make(map[string]ollama.ChatSnapshot),
+		Reviewers:   make(map[string]ollama.ChatSnapshot),
+	}
+	if s.saved != nil {
+		for name, snapshot := range s.saved.Specialists {
+			conv.Specialists[name] = snapshot
+		}
+		for name, snapshot := range s.saved.Reviewers {
+			conv.Reviewers[name] = snapshot
+		}
+	}
+	for name, chatCtx := range s.specialistCtx {
+		conv.Specialists[name] = chatCtx.Snapshot()
+	}
+	for name, chatCtx := range s.reviewerCtx {
+		conv.Reviewers[name] = chatCtx.Snapshot()
+	}
+	return conv
+}
+
+// specialistChat 获取专家在此会话中的对话上下文，不存在时创建
+// 调用者需要持有会话锁
+func (s *Session) specialistChat(specialist *Specialist) *ollama.ChatContext {
+	name := specialist.getRule().Name()
+	chatCtx, ok := s.specialistCtx[name]
+	if !ok {
+		chatCtx = specialist.newChatContext()
+		if s.saved != nil {
+			if snapshot, ok := s.saved.Specialists[name]; ok {
+				chatCtx.Restore(snapshot)
+			}
+		}
+		s.specialistCtx[name] = chatCtx
+	}
+	return chatCtx
+}
+
+// reviewerChat 获取评审者在此会话中的对话上下文，不存在时创建
+// 调用者需要持有会话锁
+func (s *Session) reviewerChat(reviewer *Reviewer) *ollama.ChatContext {
+	name := reviewer.rule.Name()
+	chatCtx, ok := s.reviewerCtx[name]
+	if !ok {
+		chatCtx = reviewer.newChatContext()
+		if s.saved != nil {
+			if snapshot, ok := s.saved.Reviewers[name]; ok {
+				chatCtx.Restore(snapshot)
+			}
+		}
+		s.reviewerCtx[name] = chatCtx
+	}
+	return chatCtx
+}
+
+// SessionManager 会话管理器，按会话 ID 管理会话并清理空闲会话
+// 配置了存储时，每次请求结束后保存会话，内存中不存在的会话从存储中恢复
+type SessionManager struct {
+	mu          sync.Mutex              // 保护会话表
+	sessions    map[string]*Session     // 会话 ID 到会话的映射
+	idleTimeout time.Duration           // 空闲超时时间
+	store       store.ConversationStore // 会话存储，为 nil 时不持久化
+	logger      logger.ErrorLogger      // 日志记录器
+}
+
+// newSessionManager 创建会话管理器
+// 参数 idleTimeout: 空闲超时时间
+// 参数 convStore: 会话存储，为 nil 时不持久化
+// 参数 logger: 日志记录器
+func newSessionManager(idleTimeout time.Duration, convStore store.ConversationStore, logger logger.ErrorLogger) *SessionManager {
+	return &SessionManager{
+		sessions:    make(map[string]*Session),
+		idleTimeout: idleTimeout,
+		store:       convStore,
+		logger:      logger,
+	}
+}
+
+// acquire 获取指定 ID 的会话并加锁，不存在时从存储恢复或创建
+// 每次获取时顺带清理已过期的空闲会话（已持久化的会话仍可从存储恢复）
+// 参数 id: 会话 ID
+// 等待会话锁期间会话被删除时，重新获取（此时会创建新会话）
+// 返回: 已加锁的会话，调用者使用完毕后需调用 release
+func (m *SessionManager) acquire(id string) *Session {
+	for {
+		m.mu.Lock()
+		now := time.Now()
+		for sid, session := range m.sessions {
+			if sid != id && now.Sub(session.lastActive) > m.idleTimeout {
+				delete(m.sessions, sid)
+			}
+		}
+		session, ok := m.sessions[id]
+		if !ok {
+			session = m.load(id)
+			m.sessions[id] = session
+		}
+		session.lastActive = now
+		m.mu.Unlock()
+
+		session.mu.Lock()
+		if !session.deleted {
+			return session
+		}
+		session.mu.Unlock()
+	}
+}
+
+// load 从存储恢复会话，不存在时创建新会话
+func (m *SessionManager) load(id string) *Session {
+	if m.store == nil {
+		return newSession(id)
+	}
+	conv, err := m.store.Load(id)
+	if err != nil {
+		if err != store.ErrNotFound {
+			m.logger.LogError(err, "load conversation", id)
+		}
+		return newSession(id)
+	}
+	return restoreSession(conv)
+}
+
+// release 保存会话并释放会话锁，刷新最后活动时间
+// 已删除的会话不再保存，避免恢复已删除的会话
+func (m *SessionManager) release(session *Session) {
+	if m.store != nil && !session.deleted {
+		if err := m.store.Save(session.conversation()); err != nil {
+			m.logger.LogError(err, "save conversation", session.id)
+		}
+	}
+
+	m.mu.Lock()
+	session.lastActive = time.Now()
+	m.mu.Unlock()
+	session.mu.Unlock()
+}
+
+// list 列出已保存的会话
+func (m *SessionManager) list() ([]store.ConversationInfo, error) {
+	if m.store == nil {
+		return []store.ConversationInfo{}, nil
+	}
+	return m.store.List()
+}
+
+// get 获取会话内容
+// 内存中的会话可能正在处理请求，因此只读取存储中的内容
+func (m *SessionManager) get(id string) (*store.Conversation, error) {
+	if m.store == nil {
+		return nil, store.ErrNotFound
+	}
+	return m.store.Load(id)
+}
+
+// remove 删除会话，同时从内存和存储中移除
+// 会话正在处理请求时，等待请求结束（release 保存之后）再删除
+func (m *SessionManager) remove(id string) error {
+	for {
+		m.mu.Lock()
+		session, ok := m.sessions[id]
+		if !ok {
+			// 不在内存中：持有会话表锁删除存储，避免并发的 acquire 恢复即将删除的会话
+			defer m.mu.Unlock()
+			return m.deleteStored(id, false)
+		}
+		m.mu.Unlock()
+
+		session.mu.Lock()
+		m.mu.Lock()
+		if m.sessions[id] == session {
+			session.deleted = true
+			delete(m.sessions, id)
+			err := m.deleteStored(id, true)
+			m.mu.Unlock()
+			session.mu.Unlock()
+			return err
+		}
+		// 等待期间会话已被替换（如空闲过期后重新创建），重新查找
+		m.mu.Unlock()
+		session.mu.Unlock()
+	}
+}
+
+// deleteStored 从存储中删除会话
+// 参数 inMemory: 会话是否已从内存中删除，是时存储中没有该会话不算错误
+// 返回: error，内存和存储中都没有该会话时返回 store.ErrNotFound
+func (m *SessionManager) deleteStored(id string, inMemory bool) error {
+	if m.store == nil {
+		if inMemory {
+			return nil
+		}
+		return store.ErrNotFound
+	}
+	if err := m.store.Delete(id); err != nil && !(inMemory && errors.Is(err, store.ErrNotFound)) {
+		return err
+	}
+	return nil
+}
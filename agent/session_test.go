@@ -0,0 +1,86 @@
+package agent
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go-ollama/logger"
+	"go-ollama/store"
+)
+
+func TestSessionRemove(t *testing.T) {
+	dir := t.TempDir()
+	errorLogger, err := logger.NewErrorLogger(filepath.Join(dir, "test.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	convStore, err := store.NewJsonStore(filepath.Join(dir, "conversations"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager := newSessionManager(sessionIdleTimeout, convStore, errorLogger)
+
+	{ // case remove while a request is running
+		session := manager.acquire("abc")
+		session.setTitle("问题")
+
+		done := make(chan error)
+		go func() { done <- manager.remove("abc") }()
+		select {
+		case <-done:
+			t.Fatal("remove returned before the request finished")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		manager.release(session)
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+		if _, err := convStore.Load("abc"); err != store.ErrNotFound {
+			t.Fatal("deleted conversation was saved again:", err)
+		}
+
+		// 删除后的新请求获取新会话
+		fresh := manager.acquire("abc")
+		if fresh == session || fresh.title != "" {
+			t.Fatal("acquired the deleted session")
+		}
+
+		// 已删除的会话不再保存
+		manager.release(fresh)
+		if _, err := convStore.Load("abc"); err != nil {
+			t.Fatal(err)
+		}
+		session.mu.Lock()
+		manager.release(session)
+		if conv, err := convStore.Load("abc"); err != nil || conv.Title != "" {
+			t.Fatal("deleted session overwrote the new conversation")
+		}
+	}
+	{ // case remove a stored session that is not in memory
+		session := manager.acquire("def")
+		session.setTitle("问题")
+		manager.release(session)
+		manager.mu.Lock()
+		delete(manager.sessions, "def")
+		manager.mu.Unlock()
+
+		if err := manager.remove("def"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := convStore.Load("def"); err != store.ErrNotFound {
+			t.Fatal("conversation not deleted:", err)
+		}
+	}
+	{ // case remove without a store
+		memory := newSessionManager(sessionIdleTimeout, nil, errorLogger)
+		memory.release(memory.acquire("ghi"))
+		if err := memory.remove("ghi"); err != nil {
+			t.Fatal(err)
+		}
+		if err := memory.remove("ghi"); err != store.ErrNotFound {
+			t.Fatal("expected not found:", err)
+		}
+	}
+}
@@ -1,101 +1,497 @@
 package agent
 
 import (
+	"context"
 	"fmt"
 	"go-ollama/logger"
 	"go-ollama/ollama"
 	"go-ollama/rag"
 	"go-ollama/rule"
-	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
+// defaultRewriteTurns 查询改写默认参考的对话轮数
+const defaultRewriteTurns = 3
+
+// rewriteMessageRunes 查询改写时每条历史消息保留的最大字符数
+const rewriteMessageRunes = 300
+
+// indexRetryDelay 首次索引失败后，收到检索请求时重试的最短间隔，每次失败后加倍
+const indexRetryDelay = 30 * time.Second
+
+// maxIndexRetryDelay 首次索引失败后重试的最长间隔
+const maxIndexRetryDelay = 10 * time.Minute
+
 // Specialist 专家 Agent，负责处理特定领域的问题
 // 支持 RAG（检索增强生成）来提升回答的准确性
 type Specialist struct {
-	ollama    ollama.OllamaManager // Ollama 管理器
-	rag       rag.RagManager       // RAG 管理器，用于检索外部知识
-	modelName string               // 使用的 LLM 模型名称
-	rule      *rule.Rule           // 规则配置
-	chatCtx   *ollama.ChatContext  // 对话上下文，维护多轮对话历史
-	ragCtx    *rag.RagContext     // RAG 上下文，存储知识库信息
-	logger    logger.ErrorLogger  // 日志记录器
+	ollama     ollama.OllamaManager // Ollama 管理器
+	rag        rag.RagManager       // RAG 管理器，用于检索外部知识
+	modelName  string               // 使用的 LLM 模型名称
+	rule       *rule.Rule           // 规则配置
+	ragOnce    sync.Once            // 保证知识库只在启动时建立一次索引
+	indexMu    sync.Mutex           // 串行化建立索引的任务
+	statusMu   sync.RWMutex         // 保护索引进度
+	status     KnowledgeStatus      // 知识库索引进度
+	indexed    bool                 // 是否已完成首次索引，完成后重建索引期间仍可检索
+	retryDelay time.Duration        // 首次索引失败后的重试间隔，受 statusMu 保护
+	retryAt    time.Time            // 首次索引失败后，此时间之后的检索请求在后台重试，受 statusMu 保护
+	ragCtx     *rag.RagContext      // RAG 上下文，存储知识库信息，所有会话共享
+	summarizer ollama.Summarizer    // 历史摘要提示词构建器
+	tools      *ToolRegistry        // 工具注册表
+	logger     logger.ErrorLogger   // 日志记录器
 }
 
 // newSpecialist 创建并初始化专家实例
 // 参数 rag: RAG 管理器
+// 参数 modelName: 使用的模型名称
 // 参数 rule: 专家规则配置
-func newSpecialist(ollama ollama.OllamaManager, rag rag.RagManager, rule *rule.Rule, logger logger.ErrorLogger) *Specialist {
+// 参数 summarizer: 历史摘要提示词构建器
+// 参数 tools: 工具注册表
+func newSpecialist(ollama ollama.OllamaManager, rag rag.RagManager, modelName string, rule *rule.Rule, summarizer ollama.Summarizer, tools *ToolRegistry, logger logger.ErrorLogger) *Specialist {
 	specialist := Specialist{
-		ollama:    ollama,
-		rag:       rag,
-		modelName: ollama.GetAvailableModelName("deepseek"),
-		rule:      rule,
-		logger:    logger,
+		ollama:     ollama,
+		rag:        rag,
+		modelName:  modelName,
+		rule:       rule,
+		status:     KnowledgeStatus{Name: rule.Name(), Source: strings.Join(rule.SourceFiles(), ", "), State: KnowledgePending},
+		summarizer: summarizer,
+		tools:      tools,
+		logger:     logger,
 	}
 	return &specialist
 }
 
-// prepareChat 初始化对话环境
-// 如果需要 RAG，会预处理知识库（文本分块、向量化、存储）
-// 创建对话上下文，设置系统提示词
-func (s *Specialist) prepareChat() {
+// newChatContext 创建专家的对话上下文，设置系统提示词
+// 每个会话拥有独立的对话上下文
+func (s *Specialist) newChatContext() *ollama.ChatContext {
+	chatCtx := s.ollama.NewChat(s.modelName, s.rule.SystemMessage())
+	chatCtx.SetContextWindow(contextWindowFromRule(s.rule, s.summarizer))
+	chatCtx.SetOptions(optionsFromConfig(s.rule.Options()))
+	return chatCtx
+}
+
+// openKnowledge 打开专家的知识库，加载已有的文档清单
+// 返回: error
+func (s *Specialist) openKnowledge() error {
+	loader, chunker, retrieval := s.rule.Loader(), s.rule.Chunker(), s.rule.Retrieval()
+	ragCtx, err := s.rag.OpenCollection(s.rule.Name(), rag.CollectionOptions{
+		Loader:     loader.Type,
+		TextFields: loader.TextFields,
+		Language:   s.rule.Language(),
+		Reranker:   retrieval.Reranker,
+		MmrLambda:  retrieval.MmrLambda,
+		Chunk: rag.ChunkOptions{
+			Strategy: chunker.Type,
+			MinSize:  chunker.MinSize,
+			MaxSize:  chunker.MaxSize,
+			Overlap:  chunker.Overlap,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("open knowledge base %s: %w", s.rule.Name(), err)
+	}
+	s.ragCtx = ragCtx
+	s.setStatus(func(status *KnowledgeStatus) {
+		status.Documents = len(s.rag.ListDocuments(ragCtx))
+	})
+	return nil
+}
+
+// startIndexing 在后台建立知识库索引
+// 只会执行一次，首次索引完成前的检索请求返回 ErrKnowledgeIndexing
+func (s *Specialist) startIndexing() {
 	if s.rule.NeedRag() {
-		// 导入外部知识库，进行预处理
-		ragCtx, chProg, err := s.rag.PreprocessFromFile(s.rule.SourceFile())
-		if err != nil {
-			s.logger.LogError(err, "rag preprocess")
-		} else {
-			s.ragCtx = ragCtx
-			fmt.Println("需要导入外部知识库，请稍等...")
-			errCount := 0
-			for p := range chProg {
-				if p.Err != nil {
-					s.logger.LogError(p.Err, "rag preprocess", p.Text)
-					errCount++
-				}
-				fmt.Printf("\r进度：%.1f%% 第%d项，共%d项", p.Percentage, p.Current, p.Total)
+		go s.ragOnce.Do(s.prepareRag)
+	}
+}
+
+// prepareRag 初始化知识库
+// 重新索引规则配置的源文件和已上传的文档，内容未变化的文本块复用已有向量
+func (s *Specialist) prepareRag() {
+	s.index(s.indexSources)
+}
+
+// indexSources 同步知识库的文档并启动建立索引
+// 规则配置的源文件在每次建立索引时重新展开通配符，配置中已不存在的文件从知识库中删除
+// 返回: ProgressInfo channel、error
+func (s *Specialist) indexSources() (chan rag.ProgressInfo, error) {
+	sources, err := rag.SourceDocuments(s.rule.SourceFiles(), s.rag.ListDocuments(s.ragCtx))
+	if err != nil {
+		return nil, err
+	}
+	configured := make(map[string]bool)
+	for _, source := range sources {
+		configured[source.Id] = true
+	}
+	for _, doc := range s.rag.ListDocuments(s.ragCtx) {
+		switch {
+		case configured[doc.Id]:
+		case doc.Origin == rag.OriginConfig:
+			if err := s.rag.DeleteDocument(s.ragCtx, doc.Id); err != nil {
+				s.logger.LogError(err, "rag remove source", doc.Id)
+			}
+		default:
+			sources = append(sources, rag.DocumentSource{Id: doc.Id, Source: doc.Source, Origin: doc.Origin})
+		}
+	}
+	return s.rag.AddDocuments(s.ragCtx, sources)
+}
+
+// isConfiguredDocument 文档 ID 是否属于规则配置的源文件
+// 包括已索引的配置文档和当前通配符展开后的源文件
+// 参数 docId: 文档 ID
+// 返回: 是否属于配置的源文件、error
+func (s *Specialist) isConfiguredDocument(docId string) (bool, error) {
+	for _, doc := range s.rag.ListDocuments(s.ragCtx) {
+		if doc.Id == docId && doc.Origin == rag.OriginConfig {
+			return true, nil
+		}
+	}
+	sources, err := rag.SourceDocuments(s.rule.SourceFiles(), s.rag.ListDocuments(s.ragCtx))
+	if err != nil {
+		return false, err
+	}
+	for _, source := range sources {
+		if source.Id == docId {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// index 执行建立索引的任务，并记录索引进度
+// 参数 start: 启动索引任务，返回进度 channel
+func (s *Specialist) index(start func() (chan rag.ProgressInfo, error)) {
+	s.indexMu.Lock()
+	defer s.indexMu.Unlock()
+	s.setStatus(func(status *KnowledgeStatus) {
+		*status = KnowledgeStatus{Name: status.Name, Source: status.Source, State: KnowledgeIndexing, Documents: status.Documents}
+	})
+
+	// 预处理知识库（文本分块、向量化、存储）
+	chProg, err := start()
+	if err != nil {
+		s.logger.LogError(err, "rag preprocess", s.rule.Name())
+		s.setStatus(func(status *KnowledgeStatus) {
+			status.State = KnowledgeFailed
+			status.Error = err.Error()
+			s.scheduleRetry()
+		})
+		return
+	}
+
+	for p := range chProg {
+		if p.Err != nil {
+			s.logger.LogError(p.Err, "rag preprocess", p.DocumentId, p.Text)
+		}
+		s.setStatus(func(status *KnowledgeStatus) {
+			status.Current = p.Current
+			status.Total = p.Total
+			status.Percentage = p.Percentage
+			if p.Err != nil {
+				status.Errors++
+				status.Error = p.Err.Error()
+			}
+			if p.Reused {
+				status.Reused++
 			}
-			if errCount > 0 {
-				fmt.Println(" 预处理错误" + strconv.Itoa(errCount) + "项")
-			} else {
-				fmt.Println()
+		})
+	}
+
+	documents := len(s.rag.ListDocuments(s.ragCtx))
+	s.setStatus(func(status *KnowledgeStatus) {
+		status.Documents = documents
+		if !s.indexed && status.Errors > 0 && status.Errors >= status.Total {
+			status.State = KnowledgeFailed
+			if status.Error == "" {
+				status.Error = "all chunks failed to embed"
 			}
+			s.scheduleRetry()
+			return
 		}
+		status.State = KnowledgeReady
+		s.indexed = true
+		s.retryDelay = 0
+	})
+	status := s.knowledgeStatus()
+	s.logger.LogInfo(fmt.Sprintf("rag %s %s: %d documents, %d chunks, %d reused, %d errors",
+		s.rule.Name(), status.State, status.Documents, status.Total, status.Reused, status.Errors))
+}
+
+// scheduleRetry 首次索引失败后，安排下一次重试的时间，重试间隔按失败次数加倍
+// 调用者需要持有 statusMu
+func (s *Specialist) scheduleRetry() {
+	if s.indexed {
+		return
 	}
-	// 创建对话上下文，设置系统提示词
-	s.chatCtx = s.ollama.NewChat(s.modelName, s.rule.SystemMessage())
+	s.retryDelay = min(max(s.retryDelay*2, indexRetryDelay), maxIndexRetryDelay)
+	s.retryAt = time.Now().Add(s.retryDelay)
+}
+
+// addDocument 在后台添加或更新知识库中的文档
+// 参数 source: 待添加的文档
+// 返回: error，文档 ID 不合法时返回 rag.ErrInvalidDocumentId
+func (s *Specialist) addDocument(source rag.DocumentSource) error {
+	if err := rag.CheckDocumentId(source.Id); err != nil {
+		return err
+	}
+	go s.index(func() (chan rag.ProgressInfo, error) {
+		return s.rag.AddDocuments(s.ragCtx, []rag.DocumentSource{source})
+	})
+	return nil
+}
+
+// deleteDocument 删除知识库中的文档
+// 参数 docId: 文档 ID
+// 返回: 被删除文档的信息、error
+func (s *Specialist) deleteDocument(docId string) (rag.DocumentInfo, error) {
+	var deleted rag.DocumentInfo
+	for _, doc := range s.rag.ListDocuments(s.ragCtx) {
+		if doc.Id == docId {
+			deleted = doc
+		}
+	}
+	if err := s.rag.DeleteDocument(s.ragCtx, docId); err != nil {
+		return deleted, err
+	}
+	documents := len(s.rag.ListDocuments(s.ragCtx))
+	s.setStatus(func(status *KnowledgeStatus) {
+		status.Documents = documents
+	})
+	return deleted, nil
+}
+
+// reindex 在后台重新索引知识库中的所有文档
+func (s *Specialist) reindex() {
+	go s.index(s.indexSources)
+}
+
+// setStatus 在锁保护下更新知识库索引进度
+// 参数 update: 更新函数
+func (s *Specialist) setStatus(update func(status *KnowledgeStatus)) {
+	s.statusMu.Lock()
+	defer s.statusMu.Unlock()
+	update(&s.status)
+}
+
+// knowledgeStatus 获取知识库索引进度
+func (s *Specialist) knowledgeStatus() KnowledgeStatus {
+	s.statusMu.RLock()
+	defer s.statusMu.RUnlock()
+	return s.status
 }
 
 // chat 处理用户问题并生成回答
 // 如果配置了 RAG，会先检索相关文档，然后将检索结果和问题一起发送给 LLM
+// 参数 chatCtx: 会话中该专家的对话上下文
 // 参数 chat: 用户输入的问题
 // 返回: 专家生成的回答、error
-func (s *Specialist) chat(chat string) (string, error) {
-	// 延迟初始化，首次调用时准备对话环境
-	if s.chatCtx == nil {
-		s.prepareChat()
+func (s *Specialist) chat(chatCtx *ollama.ChatContext, chat string) (string, error) {
+	message, err := s.buildMessage(chatCtx, chat)
+	if err != nil {
+		return "", err
+	}
+	// 配置了工具时，允许模型调用工具
+	if len(s.allowedTools()) > 0 {
+		return s.chatWithTools(chatCtx, message)
+	}
+	// 调用 LLM 生成回答，维护对话上下文
+	return s.ollama.NextChat(chatCtx, message)
+}
+
+// allowedTools 获取专家可以调用的工具名称
+// 包括规则中配置的工具，以及规则引用的 MCP 服务提供的所有工具
+func (s *Specialist) allowedTools() []string {
+	allowed := append([]string(nil), s.rule.Tools()...)
+	for _, server := range s.rule.McpServers() {
+		allowed = append(allowed, s.tools.groupTools(server)...)
+	}
+	return allowed
+}
+
+// chatWithTools 允许模型调用工具的对话
+// 循环执行模型请求的工具，并将结果以 role: tool 消息反馈给模型，直到模型给出最终回答
+// 出错时对话上下文恢复到调用前的状态
+// 参数 chatCtx: 会话中该专家的对话上下文
+// 参数 message: 发送给 LLM 的消息
+// 返回: 专家生成的回答、error
+func (s *Specialist) chatWithTools(chatCtx *ollama.ChatContext, message string) (string, error) {
+	allowed := s.allowedTools()
+	tools := s.tools.definitions(allowed)
+	if len(tools) == 0 {
+		return s.ollama.NextChat(chatCtx, message)
+	}
+
+	// 出错时撤销本次新增的问题、工具调用和工具结果，避免历史中留下没有结果的工具调用
+	snapshot := chatCtx.Snapshot()
+	respMessage, err := s.ollama.NextChatWithTools(chatCtx, []ollama.ChatMessage{{Role: "user", Content: message}}, tools)
+	if err != nil {
+		// 模型可能不支持工具调用，降级为普通对话
+		s.logger.LogError(err, "chat with tools", s.rule.Name())
+		return s.ollama.NextChat(chatCtx, message)
+	}
+
+	for round := 0; len(respMessage.ToolCalls) > 0; round++ {
+		// 超过最大轮数时不再提供工具，要求模型直接回答
+		if round >= maxToolRounds {
+			tools = nil
+		}
+		var results []ollama.ChatMessage
+		for _, call := range respMessage.ToolCalls {
+			result, err := s.tools.call(call, allowed)
+			if err != nil {
+				s.logger.LogError(err, "tool call", call.Function.Name)
+				result = "error: " + err.Error()
+			}
+			results = append(results, ollama.ChatMessage{Role: "tool", ToolName: call.Function.Name, Content: result})
+		}
+		respMessage, err = s.ollama.NextChatWithTools(chatCtx, results, tools)
+		if err != nil {
+			chatCtx.Restore(snapshot)
+			return "", err
+		}
+		if tools == nil {
+			break
+		}
+	}
+	return respMessage.Content, nil
+}
+
+// chatStream 处理用户问题并以流式方式生成回答
+// 参数 ctx: 请求的 context，取消后停止流式生成
+// 参数 chatCtx: 会话中该专家的对话上下文
+// 参数 chat: 用户输入的问题
+// 返回: StreamChunk channel、error
+// 注意：返回的 channel 需要调用者消费
+func (s *Specialist) chatStream(ctx context.Context, chatCtx *ollama.ChatContext, chat string) (chan ollama.StreamChunk, error) {
+	message, err := s.buildMessage(chatCtx, chat)
+	if err != nil {
+		return nil, err
 	}
-	
+	if len(s.allowedTools()) == 0 {
+		return s.ollama.NextChatStream(ctx, chatCtx, message), nil
+	}
+
+	// 工具调用需要等待完整的模型回复，最终回答一次性输出
+	chStream := make(chan ollama.StreamChunk)
+	go func() {
+		defer close(chStream)
+		answer, err := s.chatWithTools(chatCtx, message)
+		if err != nil {
+			chStream <- ollama.StreamChunk{Err: err}
+			return
+		}
+		chStream <- ollama.StreamChunk{Content: answer}
+		chStream <- ollama.StreamChunk{Content: answer, Done: true}
+	}()
+	return chStream, nil
+}
+
+// buildMessage 构建发送给 LLM 的消息
+// 如果需要 RAG，检索相关文档并增强问题
+// 参数 chatCtx: 会话中该专家的对话上下文，规则开启查询改写时提供最近的对话历史
+// 参数 chat: 用户输入的问题
+// 返回: 发送给 LLM 的消息、error
+func (s *Specialist) buildMessage(chatCtx *ollama.ChatContext, chat string) (string, error) {
 	// 如果需要 RAG，检索相关文档并增强问题
 	if s.rule.NeedRag() {
-		if s.ragCtx == nil {
-			return "", fmt.Errorf("RAG context not initialized")
+		history := ""
+		if query := s.rule.Query(); query.Rewrite {
+			turns := query.HistoryTurns
+			if turns <= 0 {
+				turns = defaultRewriteTurns
+			}
+			history = chatCtx.RecentHistory(turns, rewriteMessageRunes)
 		}
-		chSource, err := s.rag.Query(s.ragCtx, chat, s.rule)
+		source, err := s.search(chat, history)
 		if err != nil {
-			s.logger.LogError(err, "rag query")
-			return "", fmt.Errorf("rag query failed: %w", err)
-		}
-		// 从 channel 中读取检索结果
-		source := ""
-		for str := range chSource {
-			source += str
+			return "", err
 		}
 		// 将检索到的文档和问题组合成新的提示词
 		chat = s.rule.SourceMessage(source, chat)
 	}
-	// 调用 LLM 生成回答，维护对话上下文
-	return s.ollama.NextChat(s.chatCtx, chat)
+	return chat, nil
+}
+
+// search 检索知识库中与问题相关的文档
+// 参数 query: 检索问题
+// 参数 history: 最近的对话历史，用于将追问改写为独立的问题，为空时不改写
+// 返回: 检索到的文档、error（知识库尚未就绪时返回 ErrKnowledgeIndexing）
+func (s *Specialist) search(query string, history string) (string, error) {
+	s.statusMu.Lock()
+	status, indexed := s.status, s.indexed
+	retry := !indexed && status.State == KnowledgeFailed && !time.Now().Before(s.retryAt)
+	if retry {
+		// 推迟下一次重试，避免并发的检索请求重复启动
+		s.retryAt = time.Now().Add(s.retryDelay)
+	}
+	s.statusMu.Unlock()
+	// 首次索引完成后，重建索引期间继续使用已有的向量检索
+	switch {
+	case indexed:
+	case status.State == KnowledgePending:
+		// 未启动索引时在后台启动
+		s.startIndexing()
+		return "", &IndexingError{Status: status}
+	case status.State == KnowledgeIndexing:
+		return "", &IndexingError{Status: status}
+	case retry:
+		// 首次索引失败且已过重试间隔时，在后台重新建立索引
+		s.reindex()
+		return "", &IndexingError{Status: KnowledgeStatus{Name: status.Name, Source: status.Source, State: KnowledgeIndexing, Documents: status.Documents}}
+	case status.State == KnowledgeFailed:
+		return "", fmt.Errorf("knowledge base unavailable: %s", status.Error)
+	}
+	chSource, err := s.rag.Query(s.ragCtx, query, history, s.rule)
+	if err != nil {
+		s.logger.LogError(err, "rag query")
+		return "", fmt.Errorf("rag query failed: %w", err)
+	}
+	// 从 channel 中读取检索结果
+	source := ""
+	for str := range chSource {
+		source += str
+	}
+	return source, nil
+}
+
+// contextWindowFromRule 将规则中的上下文窗口配置转换为 ollama 的配置
+// 未配置的项使用默认值，规则开启摘要时使用 summarizer 压缩被裁剪的历史
+func contextWindowFromRule(rule *rule.Rule, summarizer ollama.Summarizer) ollama.ContextWindow {
+	window := ollama.DefaultContextWindow()
+	cfg := rule.ContextWindow()
+	if cfg.MaxTokens > 0 {
+		window.MaxTokens = cfg.MaxTokens
+	} else if numCtx := rule.Options().NumCtx; cfg.MaxTokens == 0 && numCtx != nil && *numCtx > 0 {
+		// 未配置 token 预算时，与模型的上下文长度保持一致
+		window.MaxTokens = *numCtx
+	} else if cfg.MaxTokens < 0 {
+		window.MaxTokens = 0
+	}
+	if cfg.Strategy != "" {
+		window.Strategy = ollama.TrimStrategy(cfg.Strategy)
+	}
+	window.KeepTurns = cfg.KeepTurns
+	if cfg.Summarize {
+		window.Summarizer = summarizer
+	}
+	return window
+}
+
+// optionsFromConfig 将模型生成参数配置转换为 ollama 的参数
+// 返回: 模型生成参数、模型驻留时间
+func optionsFromConfig(cfg rule.OptionsConfig) (ollama.Options, string) {
+	options := ollama.Options{
+		Temperature: cfg.Temperature,
+		NumCtx:      cfg.NumCtx,
+		TopP:        cfg.TopP,
+		Seed:        cfg.Seed,
+		Stop:        cfg.Stop,
+	}
+	return options, cfg.KeepAlive
 }
 
 // getRule 获取规则配置（供内部使用）
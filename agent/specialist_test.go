@@ -0,0 +1,125 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go-ollama/logger"
+	"go-ollama/ollama"
+	"go-ollama/rule"
+)
+
+// fakeToolOllama 测试用的 Ollama 管理器，NextChatWithTools 依次返回预设的回复，用完后模拟请求失败
+// 与真实实现一样，成功时将新增的消息和回复保存到对话历史
+type fakeToolOllama struct {
+	ollama.OllamaManager
+	replies  []ollama.ChatMessage   // 预设的模型回复
+	requests [][]ollama.ChatMessage // 每次请求新增的消息
+	tools    [][]ollama.Tool        // 每次请求提供的工具
+}
+
+func (f *fakeToolOllama) NextChatWithTools(chatCtx *ollama.ChatContext, messages []ollama.ChatMessage, tools []ollama.Tool) (ollama.ChatMessage, error) {
+	f.requests = append(f.requests, messages)
+	f.tools = append(f.tools, tools)
+	if len(f.replies) == 0 {
+		return ollama.ChatMessage{}, errors.New("model unavailable")
+	}
+	reply := f.replies[0]
+	f.replies = f.replies[1:]
+	snapshot := chatCtx.Snapshot()
+	snapshot.History = append(append(snapshot.History, messages...), reply)
+	chatCtx.Restore(snapshot)
+	return reply, nil
+}
+
+// toolCallReply 调用指定工具的模型回复
+func toolCallReply(names ...string) ollama.ChatMessage {
+	reply := ollama.ChatMessage{Role: "assistant"}
+	for _, name := range names {
+		reply.ToolCalls = append(reply.ToolCalls, ollama.ToolCall{Function: ollama.ToolCallFunction{Name: name, Arguments: json.RawMessage(`{}`)}})
+	}
+	return reply
+}
+
+func TestChatWithTools(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yml")
+	if err := os.WriteFile(configPath, []byte("rules:\n  calc:\n    tools: [\"add\"]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("RULE_CONFIG_PATH", configPath)
+	ruleManager, err := rule.StartRuleManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+	errorLogger, err := logger.NewErrorLogger(filepath.Join(dir, "test.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer errorLogger.Close()
+
+	calls := map[string]int{}
+	tools := newToolRegistry()
+	for _, name := range []string{"add", "secret"} {
+		err := tools.Register(name, name, json.RawMessage(`{"type":"object"}`), func(args json.RawMessage) (string, error) {
+			calls[name]++
+			return name + " result", nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	newCalc := func(fake *fakeToolOllama) *Specialist {
+		return newSpecialist(fake, nil, "model", ruleManager.GetAllRules()[0], nil, tools, errorLogger)
+	}
+
+	{ // case tool results fed back as tool messages
+		fake := &fakeToolOllama{replies: []ollama.ChatMessage{toolCallReply("add", "secret"), {Role: "assistant", Content: "answer"}}}
+		chatCtx := &ollama.ChatContext{}
+		answer, err := newCalc(fake).chatWithTools(chatCtx, "q")
+		if err != nil || answer != "answer" {
+			t.Fatal("unexpected answer", answer, err)
+		}
+		if len(fake.tools[0]) != 1 || fake.tools[0][0].Function.Name != "add" {
+			t.Fatalf("unexpected tools offered: %+v", fake.tools[0])
+		}
+		results := fake.requests[1]
+		if len(results) != 2 || results[0].Role != "tool" || results[0].ToolName != "add" || results[0].Content != "add result" {
+			t.Fatalf("unexpected tool results: %+v", results)
+		}
+		if results[1].Role != "tool" || results[1].Content != "error: tool not allowed: secret" || calls["secret"] != 0 {
+			t.Fatalf("disallowed tool executed: %+v %v", results[1], calls)
+		}
+		if history := chatCtx.Snapshot().History; len(history) != 5 {
+			t.Fatalf("unexpected history: %+v", history)
+		}
+	}
+	{ // case stop offering tools after max rounds
+		fake := &fakeToolOllama{}
+		for i := 0; i <= maxToolRounds; i++ {
+			fake.replies = append(fake.replies, toolCallReply("add"))
+		}
+		fake.replies = append(fake.replies, ollama.ChatMessage{Role: "assistant", Content: "answer"})
+		answer, err := newCalc(fake).chatWithTools(&ollama.ChatContext{}, "q")
+		if err != nil || answer != "answer" || len(fake.requests) != maxToolRounds+2 {
+			t.Fatal("unexpected answer", answer, err, len(fake.requests))
+		}
+		if fake.tools[maxToolRounds] == nil || fake.tools[maxToolRounds+1] != nil {
+			t.Fatal("tools still offered after max rounds")
+		}
+	}
+	{ // case failure after a tool round rolls back the whole turn
+		fake := &fakeToolOllama{replies: []ollama.ChatMessage{toolCallReply("add")}}
+		chatCtx := &ollama.ChatContext{}
+		chatCtx.Restore(ollama.ChatSnapshot{History: []ollama.ChatMessage{{Role: "user", Content: "hi"}, {Role: "assistant", Content: "hello"}}})
+		if _, err := newCalc(fake).chatWithTools(chatCtx, "q"); err == nil {
+			t.Fatal("expected error")
+		}
+		if history := chatCtx.Snapshot().History; len(history) != 2 || history[1].Content != "hello" {
+			t.Fatalf("history not rolled back: %+v", history)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"go-ollama/ollama"
+	"sync"
+)
+
+// maxToolRounds 单次回答中工具调用的最大轮数，避免模型反复调用工具陷入循环
+const maxToolRounds = 5
+
+// ToolFunc 工具函数，接收模型给出的 JSON 参数，返回工具执行结果文本
+type ToolFunc func(args json.RawMessage) (string, error)
+
+// registeredTool 已注册的工具
+type registeredTool struct {
+	tool ollama.Tool // 提供给模型的工具定义
+	fn   ToolFunc    // 工具实现
+}
+
+// ToolRegistry 工具注册表，管理可供专家调用的 Go 函数
+// 专家可使用的工具由规则配置中的 tools 列表决定
+type ToolRegistry struct {
+	mu     sync.RWMutex              // 保护并发访问的读写锁
+	tools  map[string]registeredTool // 工具名称到工具的映射
+	groups map[string][]string       // 工具组（如 MCP 服务）名称到工具名称的映射
+}
+
+// newToolRegistry 创建工具注册表
+func newToolRegistry() *ToolRegistry {
+	return &ToolRegistry{
+		tools:  make(map[string]registeredTool),
+		groups: make(map[string][]string),
+	}
+}
+
+// Register 注册工具
+// 参数 name: 工具名称，规则配置中通过名称引用
+// 参数 description: 工具说明，供模型判断何时调用
+// 参数 parameters: 参数的 JSON Schema
+// 参数 fn: 工具实现
+// 返回: error
+func (r *ToolRegistry) Register(name string, description string, parameters json.RawMessage, fn ToolFunc) error {
+	if name == "" || fn == nil {
+		return fmt.Errorf("invalid tool: %q", name)
+	}
+	if !json.Valid(parameters) {
+		return fmt.Errorf("invalid parameters schema for tool: %s", name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.tools[name]; ok {
+		return fmt.Errorf("tool already registered: %s", name)
+	}
+	r.tools[name] = registeredTool{
+		tool: ollama.Tool{
+			Type: "function",
+			Function: ollama.ToolFunction{
+				Name:        name,
+				Description: description,
+				Parameters:  parameters,
+			},
+		},
+		fn: fn,
+	}
+	return nil
+}
+
+// registerGroup 注册工具并加入工具组
+// 参数 group: 工具组名称
+// 其他参数同 Register
+func (r *ToolRegistry) registerGroup(group string, name string, description string, parameters json.RawMessage, fn ToolFunc) error {
+	if err := r.Register(name, description, parameters, fn); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.groups[group] = append(r.groups[group], name)
+	return nil
+}
+
+// groupTools 获取工具组中的所有工具名称
+func (r *ToolRegistry) groupTools(group string) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]string(nil), r.groups[group]...)
+}
+
+// definitions 获取指定名称的工具定义，忽略未注册的工具
+// 参数 names: 工具名称列表
+// 返回: 工具定义数组
+func (r *ToolRegistry) definitions(names []string) []ollama.Tool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var tools []ollama.Tool
+	for _, name := range names {
+		if registered, ok := r.tools[name]; ok {
+			tools = append(tools, registered.tool)
+		}
+	}
+	return tools
+}
+
+// call 执行工具调用
+// 参数 call: 模型返回的工具调用
+// 参数 allowed: 允许调用的工具名称
+// 返回: 工具执行结果、error
+func (r *ToolRegistry) call(call ollama.ToolCall, allowed []string) (string, error) {
+	name := call.Function.Name
+	permitted := false
+	for _, allowedName := range allowed {
+		if allowedName == name {
+			permitted = true
+			break
+		}
+	}
+	if !permitted {
+		return "", fmt.Errorf("tool not allowed: %s", name)
+	}
+
+	r.mu.RLock()
+	registered, ok := r.tools[name]
+	r.mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("tool not found: %s", name)
+	}
+	return registered.fn(call.Function.Arguments)
+}
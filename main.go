@@ -1,13 +1,17 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"path/filepath"
 
 	"go-ollama/agent"
 	"go-ollama/logger"
 	"go-ollama/ollama"
+	"go-ollama/store"
 	"go-ollama/web"
 )
 
@@ -17,6 +21,18 @@ const ollamaDomain = "http://localhost:11434"
 // serverAddr Web服务器监听地址
 const serverAddr = ":8080"
 
+// conversationDir 会话存储目录
+const conversationDir = "./data/conversations"
+
+// conversationLog 追加日志方式的会话存储文件
+const conversationLog = "./data/conversations.log"
+
+// vectorDir 知识库向量的持久化目录，重启后复用已有的向量
+const vectorDir = "./data/vectors"
+
+// knowledgeDir 上传到知识库的文档的保存目录，每个知识库一个子目录
+const knowledgeDir = "./data/knowledge"
+
 // test
 // agent.Chat("1+2+3+...+100的值是多少？")
 // agent.Chat("请你以猫为主题，写一首诗。")
@@ -25,8 +41,17 @@ const serverAddr = ":8080"
 
 // main 程序入口函数
 // 初始化日志、Ollama 连接和 Agent 管理器，然后启动Web服务器
-// todo mcp func call
+// 使用 -mcp 参数时，改为以 stdio 方式提供 MCP 服务，供 IDE 等其他 Agent 调用
 func main() {
+	mcpStdio := flag.Bool("mcp", false, "以 stdio 方式提供 MCP 服务")
+	flag.Parse()
+
+	// stdio 模式下标准输出用于 MCP 协议通信，其他输出改写到标准错误
+	mcpOut := os.Stdout
+	if *mcpStdio {
+		os.Stdout = os.Stderr
+	}
+
 	fmt.Println("--> Ollama Local Service Demo")
 	fmt.Println("正在初始化...")
 
@@ -44,12 +69,29 @@ func main() {
 		return
 	}
 
+	// 打开会话存储
+	convStore, err := openConversationStore()
+	if err != nil {
+		errorLog.LogError(err, "launching")
+		return
+	}
+	defer convStore.Close()
+
 	// 启动agent
-	agentMgr, err := agent.StartAgentManager(ollamaMgr, errorLog)
+	agentMgr, err := agent.StartAgentManager(ollamaMgr, convStore, vectorDir, knowledgeDir, errorLog)
 	if err != nil {
 		errorLog.LogError(err, "launching")
 		return
 	}
+	defer agentMgr.Close()
+
+	if *mcpStdio {
+		fmt.Println("MCP 服务已启动（stdio）")
+		if err := agent.NewMcpServer(agentMgr).ServeStdio(os.Stdin, mcpOut); err != nil {
+			errorLog.LogError(err, "mcp server")
+		}
+		return
+	}
 
 	// 创建Web服务并注册路由
 	webService := web.NewWebService(agentMgr, ollamaMgr)
@@ -57,9 +99,24 @@ func main() {
 
 	// 启动Web服务器
 	fmt.Printf("Web服务已启动，请访问: http://localhost%s\n", serverAddr)
+	fmt.Printf("MCP服务地址: http://localhost%s/mcp\n", serverAddr)
 	fmt.Println("按 Ctrl+C 停止服务")
 	if err := http.ListenAndServe(serverAddr, nil); err != nil {
 		errorLog.LogError(err, "http server")
 		log.Fatal(err)
 	}
 }
+
+// openConversationStore 打开会话存储
+// 通过环境变量 CONVERSATION_STORE 选择存储方式：json（默认，每个会话一个文件）或 log（追加日志）
+func openConversationStore() (store.ConversationStore, error) {
+	switch os.Getenv("CONVERSATION_STORE") {
+	case "log":
+		if err := os.MkdirAll(filepath.Dir(conversationLog), 0755); err != nil {
+			return nil, err
+		}
+		return store.NewLogStore(conversationLog)
+	default:
+		return store.NewJsonStore(conversationDir)
+	}
+}
@@ -0,0 +1,379 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// protocolVersion 客户端支持的 MCP 协议版本
+const protocolVersion = "2025-06-18"
+
+// requestTimeout 单次请求的超时时间
+const requestTimeout = 60 * time.Second
+
+// transport MCP 传输层接口
+type transport interface {
+	call(ctx context.Context, request *jsonrpcMessage) (*jsonrpcMessage, error)
+	notify(ctx context.Context, notification *jsonrpcMessage) error
+	close() error
+}
+
+// Client MCP 客户端
+// 连接一个 MCP 服务，发现并调用其提供的工具、资源和提示词
+type Client struct {
+	name         string             // 服务名称（来自配置）
+	transport    transport          // 传输层
+	nextId       atomic.Int64       // 自增的请求 ID
+	serverInfo   ServerInfo         // 服务端信息
+	capabilities serverCapabilities // 服务端能力
+}
+
+// ServerInfo MCP 服务端信息
+type ServerInfo struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// serverCapabilities 服务端能力，字段不为 nil 表示支持
+type serverCapabilities struct {
+	Tools     *json.RawMessage `json:"tools,omitempty"`
+	Resources *json.RawMessage `json:"resources,omitempty"`
+	Prompts   *json.RawMessage `json:"prompts,omitempty"`
+}
+
+// Tool MCP 工具
+type Tool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	InputSchema json.RawMessage `json:"inputSchema"` // 参数的 JSON Schema
+}
+
+// Resource MCP 资源
+type Resource struct {
+	Uri         string `json:"uri"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+// Prompt MCP 提示词模板
+type Prompt struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Arguments   []PromptArgument `json:"arguments,omitempty"`
+}
+
+// PromptArgument 提示词模板参数
+type PromptArgument struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+// content MCP 返回的内容块
+type content struct {
+	Type     string `json:"type"`               // text/image/audio/resource/resource_link
+	Text     string `json:"text,omitempty"`     // 文本内容
+	MimeType string `json:"mimeType,omitempty"` // 二进制内容的类型
+	Uri      string `json:"uri,omitempty"`      // 资源链接
+	Resource *struct {
+		Uri  string `json:"uri"`
+		Text string `json:"text,omitempty"`
+	} `json:"resource,omitempty"` // 内嵌资源
+}
+
+// NewStdioClient 启动本地 MCP 服务进程并建立连接
+// 参数 name: 服务名称
+// 参数 command: 可执行文件
+// 参数 args: 命令行参数
+// 参数 env: 额外的环境变量（KEY=VALUE）
+// 返回: 完成初始化的 Client、error
+func NewStdioClient(name string, command string, args []string, env []string) (*Client, error) {
+	t, err := newStdioTransport(command, args, env)
+	if err != nil {
+		return nil, err
+	}
+	client := &Client{name: name, transport: t}
+	if err := client.initialize(); err != nil {
+		t.close()
+		return nil, err
+	}
+	return client, nil
+}
+
+// NewHttpClient 通过 Streamable HTTP 连接远程 MCP 服务
+// 参数 name: 服务名称
+// 参数 url: MCP 服务地址
+// 参数 headers: 额外的请求头（如认证信息）
+// 返回: 完成初始化的 Client、error
+func NewHttpClient(name string, url string, headers map[string]string) (*Client, error) {
+	t := newHttpTransport(url, headers)
+	client := &Client{name: name, transport: t}
+	if err := client.initialize(); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// Name 获取服务名称
+func (c *Client) Name() string {
+	return c.name
+}
+
+// ServerInfo 获取服务端信息
+func (c *Client) ServerInfo() ServerInfo {
+	return c.serverInfo
+}
+
+// HasTools 服务端是否提供工具
+func (c *Client) HasTools() bool {
+	return c.capabilities.Tools != nil
+}
+
+// HasResources 服务端是否提供资源
+func (c *Client) HasResources() bool {
+	return c.capabilities.Resources != nil
+}
+
+// HasPrompts 服务端是否提供提示词
+func (c *Client) HasPrompts() bool {
+	return c.capabilities.Prompts != nil
+}
+
+// initialize 握手：发送 initialize 请求，然后发送 initialized 通知
+func (c *Client) initialize() error {
+	params := map[string]interface{}{
+		"protocolVersion": protocolVersion,
+		"capabilities":    map[string]interface{}{},
+		"clientInfo":      map[string]string{"name": "go-ollama", "version": "1.0.0"},
+	}
+	var result struct {
+		ProtocolVersion string             `json:"protocolVersion"`
+		Capabilities    serverCapabilities `json:"capabilities"`
+		ServerInfo      ServerInfo         `json:"serverInfo"`
+	}
+	if err := c.request("initialize", params, &result); err != nil {
+		return fmt.Errorf("mcp initialize %s: %w", c.name, err)
+	}
+	c.serverInfo = result.ServerInfo
+	c.capabilities = result.Capabilities
+	// HTTP 传输需要在之后的请求中携带协商后的协议版本
+	if t, ok := c.transport.(*httpTransport); ok {
+		t.setProtocolVersion(result.ProtocolVersion)
+	}
+
+	notification, err := newNotification("notifications/initialized", nil)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	return c.transport.notify(ctx, notification)
+}
+
+// request 发送请求并解析结果
+// 参数 method: 方法名
+// 参数 params: 参数
+// 参数 result: 结果的解析目标
+// 返回: error
+func (c *Client) request(method string, params interface{}, result interface{}) error {
+	request, err := newRequest(c.nextId.Add(1), method, params)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	response, err := c.transport.call(ctx, request)
+	if err != nil {
+		return err
+	}
+	if response.Error != nil {
+		return response.Error
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.Unmarshal(response.Result, result); err != nil {
+		return fmt.Errorf("json error: %v", err)
+	}
+	return nil
+}
+
+// ListTools 列出服务端提供的所有工具
+func (c *Client) ListTools() ([]Tool, error) {
+	var tools []Tool
+	err := c.paginate("tools/list", func(raw json.RawMessage) (string, error) {
+		var page struct {
+			Tools      []Tool `json:"tools"`
+			NextCursor string `json:"nextCursor"`
+		}
+		err := json.Unmarshal(raw, &page)
+		tools = append(tools, page.Tools...)
+		return page.NextCursor, err
+	})
+	return tools, err
+}
+
+// ListResources 列出服务端提供的所有资源
+func (c *Client) ListResources() ([]Resource, error) {
+	var resources []Resource
+	err := c.paginate("resources/list", func(raw json.RawMessage) (string, error) {
+		var page struct {
+			Resources  []Resource `json:"resources"`
+			NextCursor string     `json:"nextCursor"`
+		}
+		err := json.Unmarshal(raw, &page)
+		resources = append(resources, page.Resources...)
+		return page.NextCursor, err
+	})
+	return resources, err
+}
+
+// ListPrompts 列出服务端提供的所有提示词模板
+func (c *Client) ListPrompts() ([]Prompt, error) {
+	var prompts []Prompt
+	err := c.paginate("prompts/list", func(raw json.RawMessage) (string, error) {
+		var page struct {
+			Prompts    []Prompt `json:"prompts"`
+			NextCursor string   `json:"nextCursor"`
+		}
+		err := json.Unmarshal(raw, &page)
+		prompts = append(prompts, page.Prompts...)
+		return page.NextCursor, err
+	})
+	return prompts, err
+}
+
+// paginate 按游标分页请求列表
+// 参数 method: 列表方法名
+// 参数 onPage: 处理一页结果，返回下一页游标
+func (c *Client) paginate(method string, onPage func(raw json.RawMessage) (string, error)) error {
+	cursor := ""
+	for {
+		params := map[string]string{}
+		if cursor != "" {
+			params["cursor"] = cursor
+		}
+		var raw json.RawMessage
+		if err := c.request(method, params, &raw); err != nil {
+			return err
+		}
+		next, err := onPage(raw)
+		if err != nil {
+			return fmt.Errorf("json error: %v", err)
+		}
+		if next == "" || next == cursor {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// CallTool 调用工具
+// 参数 name: 工具名称
+// 参数 arguments: 参数（JSON 对象）
+// 返回: 工具返回的文本内容、error（工具报告执行失败时也返回 error）
+func (c *Client) CallTool(name string, arguments json.RawMessage) (string, error) {
+	if len(arguments) == 0 || string(arguments) == "null" {
+		arguments = json.RawMessage("{}")
+	}
+	params := map[string]interface{}{"name": name, "arguments": arguments}
+	var result struct {
+		Content []content `json:"content"`
+		IsError bool      `json:"isError"`
+	}
+	if err := c.request("tools/call", params, &result); err != nil {
+		return "", err
+	}
+	text := contentText(result.Content)
+	if result.IsError {
+		return "", fmt.Errorf("tool %s failed: %s", name, text)
+	}
+	return text, nil
+}
+
+// ReadResource 读取资源内容
+// 参数 uri: 资源 URI
+// 返回: 资源的文本内容、error
+func (c *Client) ReadResource(uri string) (string, error) {
+	var result struct {
+		Contents []struct {
+			Uri      string `json:"uri"`
+			MimeType string `json:"mimeType"`
+			Text     string `json:"text"`
+			Blob     string `json:"blob"`
+		} `json:"contents"`
+	}
+	if err := c.request("resources/read", map[string]string{"uri": uri}, &result); err != nil {
+		return "", err
+	}
+	var texts []string
+	for _, item := range result.Contents {
+		if item.Blob != "" {
+			texts = append(texts, fmt.Sprintf("[binary %s: %s]", item.MimeType, item.Uri))
+		} else {
+			texts = append(texts, item.Text)
+		}
+	}
+	return strings.Join(texts, "\n"), nil
+}
+
+// GetPrompt 获取提示词模板展开后的内容
+// 参数 name: 提示词名称
+// 参数 arguments: 模板参数
+// 返回: 展开后的消息文本（每条消息一行 role: text）、error
+func (c *Client) GetPrompt(name string, arguments map[string]string) (string, error) {
+	params := map[string]interface{}{"name": name, "arguments": arguments}
+	var result struct {
+		Messages []struct {
+			Role    string  `json:"role"`
+			Content content `json:"content"`
+		} `json:"messages"`
+	}
+	if err := c.request("prompts/get", params, &result); err != nil {
+		return "", err
+	}
+	var lines []string
+	for _, message := range result.Messages {
+		lines = append(lines, message.Role+": "+contentText([]content{message.Content}))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// Close 关闭连接
+func (c *Client) Close() error {
+	return c.transport.close()
+}
+
+// contentText 将内容块转换为文本，非文本内容以占位说明表示
+func contentText(contents []content) string {
+	var texts []string
+	for _, item := range contents {
+		switch item.Type {
+		case "text":
+			texts = append(texts, item.Text)
+		case "resource":
+			if item.Resource != nil {
+				texts = append(texts, item.Resource.Text)
+			}
+		case "resource_link":
+			texts = append(texts, "[resource: "+item.Uri+"]")
+		default:
+			texts = append(texts, "["+item.Type+" "+item.MimeType+"]")
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// handleServerRequest 处理服务端发起的请求
+// 只支持 ping，其他方法返回方法不存在
+func handleServerRequest(request *jsonrpcMessage) *jsonrpcMessage {
+	if request.Method == "ping" {
+		return newResponse(request.Id, struct{}{}, nil)
+	}
+	return newResponse(request.Id, nil, &jsonrpcError{Code: methodNotFound, Message: "method not found: " + request.Method})
+}
@@ -0,0 +1,148 @@
+package mcp
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+// TestMain 设置环境变量 MCP_TEST_FIXTURE=1 时，测试程序作为 stdio MCP 服务运行
+func TestMain(m *testing.M) {
+	if os.Getenv("MCP_TEST_FIXTURE") == "1" {
+		runFixtureServer()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runFixtureServer 本地 stdio MCP 服务，逐行读取请求并返回响应
+func runFixtureServer() {
+	scanner := bufio.NewScanner(os.Stdin)
+	encoder := json.NewEncoder(os.Stdout)
+	for scanner.Scan() {
+		var request jsonrpcMessage
+		if err := json.Unmarshal(scanner.Bytes(), &request); err != nil {
+			continue
+		}
+		if response := fixtureHandle(&request); response != nil {
+			encoder.Encode(response)
+		}
+	}
+}
+
+// fixtureHandle 测试服务的请求处理，提供 echo 工具、一个资源和一个提示词
+func fixtureHandle(request *jsonrpcMessage) *jsonrpcMessage {
+	if !request.isRequest() {
+		return nil
+	}
+	var result interface{}
+	switch request.Method {
+	case "initialize":
+		result = map[string]interface{}{
+			"protocolVersion": protocolVersion,
+			"capabilities":    map[string]interface{}{"tools": map[string]interface{}{}, "resources": map[string]interface{}{}, "prompts": map[string]interface{}{}},
+			"serverInfo":      map[string]string{"name": "fixture", "version": "0.1"},
+		}
+	case "tools/list":
+		result = map[string]interface{}{"tools": []map[string]interface{}{{
+			"name":        "echo",
+			"description": "echo text",
+			"inputSchema": map[string]interface{}{"type": "object", "properties": map[string]interface{}{"text": map[string]string{"type": "string"}}},
+		}}}
+	case "tools/call":
+		var params struct {
+			Arguments struct {
+				Text string `json:"text"`
+			} `json:"arguments"`
+		}
+		json.Unmarshal(request.Params, &params)
+		result = map[string]interface{}{"content": []map[string]string{{"type": "text", "text": "echo: " + params.Arguments.Text}}}
+	case "resources/list":
+		result = map[string]interface{}{"resources": []map[string]string{{"uri": "file:///readme.txt", "name": "readme"}}}
+	case "resources/read":
+		result = map[string]interface{}{"contents": []map[string]string{{"uri": "file:///readme.txt", "text": "hello resource"}}}
+	case "prompts/list":
+		result = map[string]interface{}{"prompts": []map[string]string{{"name": "greet"}}}
+	case "prompts/get":
+		result = map[string]interface{}{"messages": []map[string]interface{}{{"role": "user", "content": map[string]string{"type": "text", "text": "hello prompt"}}}}
+	default:
+		return newResponse(request.Id, nil, &jsonrpcError{Code: methodNotFound, Message: "method not found"})
+	}
+	return newResponse(request.Id, result, nil)
+}
+
+// checkClient 验证客户端可以发现并调用工具、资源和提示词
+func checkClient(t *testing.T, client *Client) {
+	if client.ServerInfo().Name != "fixture" || !client.HasTools() || !client.HasResources() || !client.HasPrompts() {
+		t.Fatal("expected fixture server capabilities")
+	}
+	tools, err := client.ListTools()
+	if err != nil || len(tools) != 1 || tools[0].Name != "echo" {
+		t.Fatal("expected list echo tool", err)
+	}
+	text, err := client.CallTool("echo", json.RawMessage(`{"text":"hi"}`))
+	if err != nil || text != "echo: hi" {
+		t.Fatal("expected call echo tool", text, err)
+	}
+	resources, err := client.ListResources()
+	if err != nil || len(resources) != 1 {
+		t.Fatal("expected list resources", err)
+	}
+	text, err = client.ReadResource(resources[0].Uri)
+	if err != nil || text != "hello resource" {
+		t.Fatal("expected read resource", text, err)
+	}
+	text, err = client.GetPrompt("greet", nil)
+	if err != nil || text != "user: hello prompt" {
+		t.Fatal("expected get prompt", text, err)
+	}
+}
+
+func TestStdioClient(t *testing.T) {
+	client, err := NewStdioClient("fixture", os.Args[0], []string{"-test.run=^$"}, []string{"MCP_TEST_FIXTURE=1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkClient(t, client)
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHttpClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request jsonrpcMessage
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		response := fixtureHandle(&request)
+		if response == nil {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		w.Header().Set("Mcp-Session-Id", "session-1")
+		// tools/call 使用 SSE 返回，其他请求直接返回 JSON
+		// 最后一个事件缺少结尾的空行，客户端在流结束时仍要处理
+		if request.Method == "tools/call" {
+			data, _ := json.Marshal(response)
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Write([]byte("event: message\ndata: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/progress\"}\n\n"))
+			w.Write([]byte("event: message\ndata: " + string(data)))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+
+	client, err := NewHttpClient("fixture", server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkClient(t, client)
+	client.Close()
+}
@@ -0,0 +1,170 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// httpTransport Streamable HTTP 传输
+// 每个 JSON-RPC 消息以 POST 发送，响应可以是 JSON，也可以是 SSE 事件流
+type httpTransport struct {
+	url     string            // MCP 服务地址
+	headers map[string]string // 额外的请求头（如认证信息）
+	client  *http.Client      // HTTP 客户端
+
+	mu              sync.Mutex // 保护会话信息
+	sessionId       string     // 服务端分配的会话 ID（Mcp-Session-Id）
+	protocolVersion string     // 协商后的协议版本
+}
+
+// newHttpTransport 创建 Streamable HTTP 传输
+// 参数 url: MCP 服务地址
+// 参数 headers: 额外的请求头
+func newHttpTransport(url string, headers map[string]string) *httpTransport {
+	return &httpTransport{
+		url:     url,
+		headers: headers,
+		client:  &http.Client{},
+	}
+}
+
+// setProtocolVersion 记录协商后的协议版本，之后的请求携带 MCP-Protocol-Version 头
+func (t *httpTransport) setProtocolVersion(version string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.protocolVersion = version
+}
+
+// post 发送一条消息
+func (t *httpTransport) post(ctx context.Context, message *jsonrpcMessage) (*http.Response, error) {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return nil, fmt.Errorf("json error: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	for key, value := range t.headers {
+		req.Header.Set(key, value)
+	}
+	t.mu.Lock()
+	if t.sessionId != "" {
+		req.Header.Set("Mcp-Session-Id", t.sessionId)
+	}
+	if t.protocolVersion != "" {
+		req.Header.Set("MCP-Protocol-Version", t.protocolVersion)
+	}
+	t.mu.Unlock()
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("http request error: %v", err)
+	}
+	if sessionId := resp.Header.Get("Mcp-Session-Id"); sessionId != "" {
+		t.mu.Lock()
+		t.sessionId = sessionId
+		t.mu.Unlock()
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("api error: %s - %s", resp.Status, string(body))
+	}
+	return resp, nil
+}
+
+// call 发送请求并等待响应
+func (t *httpTransport) call(ctx context.Context, request *jsonrpcMessage) (*jsonrpcMessage, error) {
+	resp, err := t.post(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		var response jsonrpcMessage
+		if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+			return nil, fmt.Errorf("json error: %v", err)
+		}
+		return &response, nil
+	}
+
+	// SSE 事件流：服务端可能在响应前发送通知或请求，直到收到对应 ID 的响应为止
+	reader := bufio.NewReader(resp.Body)
+	var data strings.Builder
+	for {
+		line, err := reader.ReadString('\n')
+		line = strings.TrimRight(line, "\r\n")
+		if strings.HasPrefix(line, "data:") {
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+		// 空行结束一个事件；流结束时最后一个事件可能缺少结尾的空行，同样处理
+		if (line == "" || err != nil) && data.Len() > 0 {
+			var message jsonrpcMessage
+			if jsonErr := json.Unmarshal([]byte(data.String()), &message); jsonErr == nil {
+				if message.isResponse() && string(message.Id) == string(request.Id) {
+					return &message, nil
+				}
+				if message.isRequest() {
+					t.reply(ctx, handleServerRequest(&message))
+				}
+			}
+			data.Reset()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("stream closed before response: %v", err)
+		}
+	}
+}
+
+// reply 回复服务端在事件流中发起的请求
+func (t *httpTransport) reply(ctx context.Context, response *jsonrpcMessage) {
+	resp, err := t.post(ctx, response)
+	if err == nil {
+		resp.Body.Close()
+	}
+}
+
+// notify 发送通知，服务端返回 202 Accepted
+func (t *httpTransport) notify(ctx context.Context, notification *jsonrpcMessage) error {
+	resp, err := t.post(ctx, notification)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// close 结束服务端会话
+func (t *httpTransport) close() error {
+	t.mu.Lock()
+	sessionId := t.sessionId
+	t.mu.Unlock()
+	if sessionId == "" {
+		return nil
+	}
+	req, err := http.NewRequest(http.MethodDelete, t.url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Mcp-Session-Id", sessionId)
+	for key, value := range t.headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
@@ -0,0 +1,80 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// jsonrpcVersion JSON-RPC 协议版本
+const jsonrpcVersion = "2.0"
+
+// jsonrpcMessage JSON-RPC 2.0 消息
+// 请求包含 Id 和 Method，通知只包含 Method，响应包含 Id 和 Result 或 Error
+type jsonrpcMessage struct {
+	Jsonrpc string          `json:"jsonrpc"`
+	Id      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *jsonrpcError   `json:"error,omitempty"`
+}
+
+// jsonrpcError JSON-RPC 错误
+type jsonrpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error 实现 error 接口
+func (e *jsonrpcError) Error() string {
+	return fmt.Sprintf("jsonrpc error %d: %s", e.Code, e.Message)
+}
+
+// methodNotFound JSON-RPC 方法不存在的错误码
+const methodNotFound = -32601
+
+// isRequest 是否为对方发起的请求
+func (m *jsonrpcMessage) isRequest() bool {
+	return m.Method != "" && len(m.Id) > 0
+}
+
+// isResponse 是否为响应
+func (m *jsonrpcMessage) isResponse() bool {
+	return m.Method == "" && len(m.Id) > 0
+}
+
+// newRequest 创建请求消息
+// 参数 id: 请求 ID
+// 参数 method: 方法名
+// 参数 params: 参数，为 nil 时不发送
+// 返回: 请求消息、error
+func newRequest(id int64, method string, params interface{}) (*jsonrpcMessage, error) {
+	message, err := newNotification(method, params)
+	if err != nil {
+		return nil, err
+	}
+	message.Id = json.RawMessage(fmt.Sprint(id))
+	return message, nil
+}
+
+// newNotification 创建通知消息
+func newNotification(method string, params interface{}) (*jsonrpcMessage, error) {
+	message := &jsonrpcMessage{Jsonrpc: jsonrpcVersion, Method: method}
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return nil, fmt.Errorf("json error: %v", err)
+		}
+		message.Params = data
+	}
+	return message, nil
+}
+
+// newResponse 创建对对方请求的响应
+func newResponse(id json.RawMessage, result interface{}, rpcErr *jsonrpcError) *jsonrpcMessage {
+	message := &jsonrpcMessage{Jsonrpc: jsonrpcVersion, Id: id, Error: rpcErr}
+	if rpcErr == nil {
+		message.Result, _ = json.Marshal(result)
+	}
+	return message
+}
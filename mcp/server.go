@@ -0,0 +1,359 @@
+package mcp
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// ToolHandler 工具实现，接收 JSON 参数，返回文本结果
+// session 为调用方的 MCP 会话 ID：HTTP 为 initialize 时分配的 Mcp-Session-Id，stdio 为每个连接生成的 ID
+type ToolHandler func(session string, args json.RawMessage) (string, error)
+
+// SessionHandler 会话结束的回调，接收结束的 MCP 会话 ID
+type SessionHandler func(session string)
+
+// ResourceHandler 资源读取实现，接收资源 URI，返回文本内容
+type ResourceHandler func(uri string) (string, error)
+
+// ResourceTemplate MCP 资源模板（RFC 6570 URI 模板）
+type ResourceTemplate struct {
+	UriTemplate string `json:"uriTemplate"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+// serverTool 服务端注册的工具
+type serverTool struct {
+	tool    Tool
+	handler ToolHandler
+}
+
+// serverResource 服务端注册的资源
+type serverResource struct {
+	resource Resource
+	handler  ResourceHandler
+}
+
+// serverTemplate 服务端注册的资源模板
+type serverTemplate struct {
+	template ResourceTemplate
+	prefix   string // 模板中第一个变量之前的部分，用于匹配 URI
+	handler  ResourceHandler
+}
+
+// Server MCP 服务端
+// 对外提供工具和资源，支持 stdio 和 Streamable HTTP 两种传输
+type Server struct {
+	info      ServerInfo       // 服务端信息
+	mu        sync.RWMutex     // 保护注册表
+	tools     []serverTool     // 已注册的工具
+	resources []serverResource // 已注册的资源
+	templates []serverTemplate // 已注册的资源模板
+	onClose   SessionHandler   // 会话结束的回调，为 nil 时不通知
+}
+
+// NewServer 创建 MCP 服务端
+// 参数 name: 服务名称
+// 参数 version: 服务版本
+func NewServer(name string, version string) *Server {
+	return &Server{info: ServerInfo{Name: name, Version: version}}
+}
+
+// AddTool 注册工具
+func (s *Server) AddTool(tool Tool, handler ToolHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tools = append(s.tools, serverTool{tool: tool, handler: handler})
+}
+
+// OnSessionClose 注册会话结束的回调
+// HTTP 客户端发送 DELETE 结束会话或 stdio 输入结束时调用，用于清理会话相关的状态
+func (s *Server) OnSessionClose(handler SessionHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onClose = handler
+}
+
+// closeSession 通知会话结束
+func (s *Server) closeSession(session string) {
+	s.mu.RLock()
+	handler := s.onClose
+	s.mu.RUnlock()
+	if handler != nil {
+		handler(session)
+	}
+}
+
+// AddResource 注册资源
+func (s *Server) AddResource(resource Resource, handler ResourceHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.resources = append(s.resources, serverResource{resource: resource, handler: handler})
+}
+
+// AddResourceTemplate 注册资源模板
+// 读取的 URI 以模板中第一个变量之前的部分开头时，由此模板处理
+func (s *Server) AddResourceTemplate(template ResourceTemplate, handler ResourceHandler) {
+	prefix := template.UriTemplate
+	if i := strings.Index(prefix, "{"); i >= 0 {
+		prefix = prefix[:i]
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.templates = append(s.templates, serverTemplate{template: template, prefix: prefix, handler: handler})
+}
+
+// ServeStdio 以 stdio 方式提供服务，直到输入结束，结束时通知会话结束
+// 请求并发处理，响应按行写入输出
+// 参数 in: 输入（通常是 os.Stdin）
+// 参数 out: 输出（通常是 os.Stdout）
+// 返回: error
+func (s *Server) ServeStdio(in io.Reader, out io.Writer) error {
+	session := newSessionId()
+	var writeMu sync.Mutex
+	var wg sync.WaitGroup
+	encoder := json.NewEncoder(out)
+
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var message jsonrpcMessage
+		if err := json.Unmarshal(scanner.Bytes(), &message); err != nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if response := s.handle(session, &message); response != nil {
+				writeMu.Lock()
+				encoder.Encode(response)
+				writeMu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	s.closeSession(session)
+	return scanner.Err()
+}
+
+// ServeHTTP 以 Streamable HTTP 方式提供服务
+// 请求直接以 JSON 响应，通知和响应返回 202，不支持服务端主动推送的 GET 事件流
+// initialize 分配会话 ID，之后的消息必须携带 Mcp-Session-Id 请求头，否则返回 400；DELETE 结束会话
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+	case http.MethodDelete:
+		session := r.Header.Get("Mcp-Session-Id")
+		if session == "" {
+			http.Error(w, "missing Mcp-Session-Id header", http.StatusBadRequest)
+			return
+		}
+		s.closeSession(session)
+		w.WriteHeader(http.StatusOK)
+		return
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var message jsonrpcMessage
+	if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
+		http.Error(w, "invalid json-rpc message", http.StatusBadRequest)
+		return
+	}
+	session := r.Header.Get("Mcp-Session-Id")
+	if message.Method == "initialize" {
+		session = newSessionId()
+	} else if session == "" {
+		http.Error(w, "missing Mcp-Session-Id header", http.StatusBadRequest)
+		return
+	}
+	response := s.handle(session, &message)
+	if response == nil {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	if message.Method == "initialize" {
+		w.Header().Set("Mcp-Session-Id", session)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// newSessionId 生成随机的会话 ID
+func newSessionId() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// handle 处理一条消息，通知和响应不需要回复，返回 nil
+// 参数 session: 调用方的 MCP 会话 ID
+// 参数 message: 收到的消息
+func (s *Server) handle(session string, message *jsonrpcMessage) *jsonrpcMessage {
+	if !message.isRequest() {
+		return nil
+	}
+
+	var result interface{}
+	var rpcErr *jsonrpcError
+	switch message.Method {
+	case "initialize":
+		result = s.initialize(message.Params)
+	case "ping":
+		result = struct{}{}
+	case "tools/list":
+		result = s.listTools()
+	case "tools/call":
+		result, rpcErr = s.callTool(session, message.Params)
+	case "resources/list":
+		result = s.listResources()
+	case "resources/templates/list":
+		result = s.listTemplates()
+	case "resources/read":
+		result, rpcErr = s.readResource(message.Params)
+	default:
+		rpcErr = &jsonrpcError{Code: methodNotFound, Message: "method not found: " + message.Method}
+	}
+	return newResponse(message.Id, result, rpcErr)
+}
+
+// invalidParams JSON-RPC 参数错误的错误码
+const invalidParams = -32602
+
+// initialize 处理握手，协议版本不一致时返回服务端支持的版本
+func (s *Server) initialize(params json.RawMessage) interface{} {
+	var request struct {
+		ProtocolVersion string `json:"protocolVersion"`
+	}
+	json.Unmarshal(params, &request)
+	version := protocolVersion
+	if request.ProtocolVersion == "2025-03-26" || request.ProtocolVersion == "2024-11-05" {
+		version = request.ProtocolVersion
+	}
+	return map[string]interface{}{
+		"protocolVersion": version,
+		"capabilities": map[string]interface{}{
+			"tools":     map[string]interface{}{},
+			"resources": map[string]interface{}{},
+		},
+		"serverInfo": s.info,
+	}
+}
+
+// listTools 列出所有工具
+func (s *Server) listTools() interface{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	tools := make([]Tool, 0, len(s.tools))
+	for _, t := range s.tools {
+		tools = append(tools, t.tool)
+	}
+	return map[string]interface{}{"tools": tools}
+}
+
+// callTool 调用工具，工具执行失败时以 isError 结果返回，而不是 JSON-RPC 错误
+func (s *Server) callTool(session string, params json.RawMessage) (interface{}, *jsonrpcError) {
+	var request struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	if err := json.Unmarshal(params, &request); err != nil {
+		return nil, &jsonrpcError{Code: invalidParams, Message: err.Error()}
+	}
+
+	s.mu.RLock()
+	var handler ToolHandler
+	for _, t := range s.tools {
+		if t.tool.Name == request.Name {
+			handler = t.handler
+			break
+		}
+	}
+	s.mu.RUnlock()
+	if handler == nil {
+		return nil, &jsonrpcError{Code: invalidParams, Message: "unknown tool: " + request.Name}
+	}
+
+	text, err := handler(session, request.Arguments)
+	if err != nil {
+		return map[string]interface{}{
+			"content": []content{{Type: "text", Text: err.Error()}},
+			"isError": true,
+		}, nil
+	}
+	return map[string]interface{}{"content": []content{{Type: "text", Text: text}}}, nil
+}
+
+// listResources 列出所有资源
+func (s *Server) listResources() interface{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	resources := make([]Resource, 0, len(s.resources))
+	for _, r := range s.resources {
+		resources = append(resources, r.resource)
+	}
+	return map[string]interface{}{"resources": resources}
+}
+
+// listTemplates 列出所有资源模板
+func (s *Server) listTemplates() interface{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	templates := make([]ResourceTemplate, 0, len(s.templates))
+	for _, t := range s.templates {
+		templates = append(templates, t.template)
+	}
+	return map[string]interface{}{"resourceTemplates": templates}
+}
+
+// readResource 读取资源，先精确匹配资源，再按前缀匹配资源模板（最长前缀优先）
+func (s *Server) readResource(params json.RawMessage) (interface{}, *jsonrpcError) {
+	var request struct {
+		Uri string `json:"uri"`
+	}
+	if err := json.Unmarshal(params, &request); err != nil {
+		return nil, &jsonrpcError{Code: invalidParams, Message: err.Error()}
+	}
+
+	s.mu.RLock()
+	var handler ResourceHandler
+	mimeType := "text/plain"
+	for _, r := range s.resources {
+		if r.resource.Uri == request.Uri {
+			handler = r.handler
+			if r.resource.MimeType != "" {
+				mimeType = r.resource.MimeType
+			}
+			break
+		}
+	}
+	if handler == nil {
+		matched := ""
+		for _, t := range s.templates {
+			if strings.HasPrefix(request.Uri, t.prefix) && len(t.prefix) > len(matched) {
+				matched = t.prefix
+				handler = t.handler
+			}
+		}
+	}
+	s.mu.RUnlock()
+	if handler == nil {
+		return nil, &jsonrpcError{Code: invalidParams, Message: "resource not found: " + request.Uri}
+	}
+
+	text, err := handler(request.Uri)
+	if err != nil {
+		return nil, &jsonrpcError{Code: invalidParams, Message: err.Error()}
+	}
+	return map[string]interface{}{
+		"contents": []map[string]string{{"uri": request.Uri, "mimeType": mimeType, "text": text}},
+	}, nil
+}
@@ -0,0 +1,102 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServer(t *testing.T) {
+	server := NewServer("fixture", "0.1")
+	server.AddTool(Tool{Name: "echo", Description: "echo text", InputSchema: json.RawMessage(`{"type":"object"}`)},
+		func(session string, args json.RawMessage) (string, error) {
+			var params struct {
+				Text string `json:"text"`
+			}
+			json.Unmarshal(args, &params)
+			if params.Text == "" {
+				return "", fmt.Errorf("empty text")
+			}
+			return "echo: " + params.Text, nil
+		})
+	server.AddTool(Tool{Name: "session", Description: "session id", InputSchema: json.RawMessage(`{"type":"object"}`)},
+		func(session string, args json.RawMessage) (string, error) {
+			return session, nil
+		})
+	server.AddResource(Resource{Uri: "kb://hp", Name: "hp"}, func(uri string) (string, error) {
+		return "knowledge base", nil
+	})
+	server.AddResourceTemplate(ResourceTemplate{UriTemplate: "kb://hp/search{?query}", Name: "hp search"}, func(uri string) (string, error) {
+		return "search " + strings.TrimPrefix(uri, "kb://hp/search?query="), nil
+	})
+	closed := make(chan string, 2)
+	server.OnSessionClose(func(session string) {
+		closed <- session
+	})
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	client, err := NewHttpClient("fixture", httpServer.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	{ // case tools
+		text, err := client.CallTool("echo", json.RawMessage(`{"text":"hi"}`))
+		if err != nil || text != "echo: hi" {
+			t.Fatal("expected call echo tool", text, err)
+		}
+		if _, err := client.CallTool("echo", nil); err == nil {
+			t.Fatal("expected tool error")
+		}
+	}
+	{ // case sessions
+		first, err := client.CallTool("session", nil)
+		if err != nil || first == "" {
+			t.Fatal("expected session id", first, err)
+		}
+		second, _ := client.CallTool("session", nil)
+		if second != first {
+			t.Fatal("expected the same session for one client", first, second)
+		}
+		other, err := NewHttpClient("other", httpServer.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		session, _ := other.CallTool("session", nil)
+		if session == first {
+			t.Fatal("expected different sessions for different clients")
+		}
+		other.Close()
+		if ended := <-closed; ended != session {
+			t.Fatal("expected delete to end the session", ended, session)
+		}
+	}
+	{ // case reject calls without a session
+		body := `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"session"}}`
+		resp, err := http.Post(httpServer.URL, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatal("expected bad request", resp.StatusCode)
+		}
+	}
+	{ // case resources
+		text, err := client.ReadResource("kb://hp")
+		if err != nil || text != "knowledge base" {
+			t.Fatal("expected read resource", text, err)
+		}
+		text, err = client.ReadResource("kb://hp/search?query=snape")
+		if err != nil || text != "search snape" {
+			t.Fatal("expected read resource template", text, err)
+		}
+		if _, err := client.ReadResource("kb://none"); err == nil {
+			t.Fatal("expected resource not found")
+		}
+	}
+}
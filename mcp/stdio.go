@@ -0,0 +1,156 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// stdioTransport 标准输入输出传输
+// 启动本地 MCP 服务进程，通过 stdin/stdout 以换行分隔的 JSON-RPC 消息通信
+type stdioTransport struct {
+	cmd     *exec.Cmd      // MCP 服务进程
+	stdin   io.WriteCloser // 进程标准输入
+	writeMu sync.Mutex     // 保证消息按行完整写入
+
+	mu      sync.Mutex                      // 保护 pending 和 err
+	pending map[string]chan *jsonrpcMessage // 请求 ID 到等待响应 channel 的映射
+	done    chan struct{}                   // 读取结束（进程退出）时关闭
+	err     error                           // 读取结束的原因
+}
+
+// newStdioTransport 启动 MCP 服务进程并创建传输
+// 参数 command: 可执行文件
+// 参数 args: 命令行参数
+// 参数 env: 额外的环境变量（KEY=VALUE），在当前进程环境变量基础上追加
+// 返回: stdioTransport 实例、error
+func newStdioTransport(command string, args []string, env []string) (*stdioTransport, error) {
+	cmd := exec.Command(command, args...)
+	cmd.Env = append(os.Environ(), env...)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start mcp server: %w", err)
+	}
+
+	t := &stdioTransport{
+		cmd:     cmd,
+		stdin:   stdin,
+		pending: make(map[string]chan *jsonrpcMessage),
+		done:    make(chan struct{}),
+	}
+	go t.readLoop(stdout)
+	return t, nil
+}
+
+// readLoop 持续读取服务输出，将响应分发给等待中的请求
+func (t *stdioTransport) readLoop(stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var message jsonrpcMessage
+		if err := json.Unmarshal(scanner.Bytes(), &message); err != nil {
+			// 忽略非 JSON-RPC 输出
+			continue
+		}
+		switch {
+		case message.isResponse():
+			t.mu.Lock()
+			ch, ok := t.pending[string(message.Id)]
+			delete(t.pending, string(message.Id))
+			t.mu.Unlock()
+			if ok {
+				ch <- &message
+			}
+		case message.isRequest():
+			t.write(handleServerRequest(&message))
+		}
+		// 通知消息忽略
+	}
+
+	t.mu.Lock()
+	t.err = scanner.Err()
+	if t.err == nil {
+		t.err = fmt.Errorf("mcp server closed")
+	}
+	t.mu.Unlock()
+	close(t.done)
+}
+
+// write 写入一条消息
+func (t *stdioTransport) write(message *jsonrpcMessage) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("json error: %v", err)
+	}
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	_, err = t.stdin.Write(append(data, '\n'))
+	return err
+}
+
+// call 发送请求并等待响应
+func (t *stdioTransport) call(ctx context.Context, request *jsonrpcMessage) (*jsonrpcMessage, error) {
+	ch := make(chan *jsonrpcMessage, 1)
+	key := string(request.Id)
+	t.mu.Lock()
+	if t.err != nil {
+		t.mu.Unlock()
+		return nil, t.err
+	}
+	t.pending[key] = ch
+	t.mu.Unlock()
+
+	if err := t.write(request); err != nil {
+		t.mu.Lock()
+		delete(t.pending, key)
+		t.mu.Unlock()
+		return nil, err
+	}
+
+	select {
+	case response := <-ch:
+		return response, nil
+	case <-t.done:
+		return nil, t.err
+	case <-ctx.Done():
+		t.mu.Lock()
+		delete(t.pending, key)
+		t.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+// notify 发送通知
+func (t *stdioTransport) notify(ctx context.Context, notification *jsonrpcMessage) error {
+	return t.write(notification)
+}
+
+// closeTimeout 关闭标准输入后等待进程退出的时间，超时后强制结束进程
+const closeTimeout = 5 * time.Second
+
+// close 关闭标准输入，等待进程退出
+func (t *stdioTransport) close() error {
+	t.stdin.Close()
+	// 进程退出后输出结束，需要先读完输出再调用 Wait
+	select {
+	case <-t.done:
+	case <-time.After(closeTimeout):
+		t.cmd.Process.Kill()
+		<-t.done
+	}
+	return t.cmd.Wait()
+}
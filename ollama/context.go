@@ -1,11 +1,53 @@
 package ollama
 
+import (
+	"strings"
+	"unicode"
+)
+
+// TrimStrategy 历史记录裁剪策略
+type TrimStrategy string
+
+const (
+	// TrimDropOldest 超出 token 预算时，从最早的对话轮次开始丢弃
+	TrimDropOldest TrimStrategy = "drop_oldest"
+	// TrimKeepLastN 只保留最近 N 轮对话，仍超出预算时再丢弃最早的轮次
+	TrimKeepLastN TrimStrategy = "keep_last_n"
+)
+
+// defaultMaxContextTokens 默认的上下文 token 预算，与 Ollama 默认的 num_ctx 一致
+const defaultMaxContextTokens = 4096
+
+// ContextWindow 上下文窗口配置
+type ContextWindow struct {
+	MaxTokens  int          // token 预算，0 表示不限制
+	Strategy   TrimStrategy // 裁剪策略
+	KeepTurns  int          // TrimKeepLastN 策略保留的对话轮数
+	Summarizer Summarizer   // 摘要提示词构建器，不为 nil 时被裁剪的历史会压缩为摘要而不是直接丢弃
+}
+
+// Summarizer 摘要提示词构建接口，用于将被裁剪的历史压缩为滚动摘要
+type Summarizer interface {
+	SummaryMessage(summary string, history string) string // 构建生成摘要的提示词（已有摘要 + 被裁剪的历史）
+	SummaryContextMessage(summary string) string          // 构建注入上下文的摘要消息
+}
+
+// DefaultContextWindow 默认的上下文窗口配置
+func DefaultContextWindow() ContextWindow {
+	return ContextWindow{MaxTokens: defaultMaxContextTokens, Strategy: TrimDropOldest}
+}
+
 // ChatContext 对话上下文，维护多轮对话的历史记录
 type ChatContext struct {
 	modelName     string        // 使用的模型名称
 	chatId        int           // 对话 ID，用于区分不同的对话
 	systemMessage ChatMessage   // 系统提示词
 	history       []ChatMessage // 对话历史（用户消息和助手回答）
+	window        ContextWindow // 上下文窗口配置
+	summary       string        // 被裁剪历史的滚动摘要
+	tokenRatio    float64       // 实际 token 数与本地估算值的比例，根据 PromptEvalCount 校准
+	options       Options       // 模型生成参数，覆盖 OllamaManager 的默认参数
+	keepAlive     string        // 模型驻留时间，为空时使用默认值
 }
 
 // newChat 创建新的对话上下文
@@ -14,7 +56,57 @@ type ChatContext struct {
 // 参数 systemMessage: 系统提示词
 // 返回: ChatContext 实例
 func newChat(modelName string, chatId int, systemMessage string) *ChatContext {
-	return &ChatContext{modelName: modelName, chatId: chatId, systemMessage: ChatMessage{Role: "system", Content: systemMessage}}
+	return &ChatContext{
+		modelName:     modelName,
+		chatId:        chatId,
+		systemMessage: ChatMessage{Role: "system", Content: systemMessage},
+		window:        DefaultContextWindow(),
+		tokenRatio:    1,
+	}
+}
+
+// SetContextWindow 设置上下文窗口配置
+// 参数 window: 上下文窗口配置
+func (c *ChatContext) SetContextWindow(window ContextWindow) {
+	c.window = window
+}
+
+// SetOptions 设置对话的模型生成参数
+// 参数 options: 模型生成参数，未设置的字段使用 OllamaManager 的默认参数
+// 参数 keepAlive: 模型驻留时间，为空时使用默认值
+func (c *ChatContext) SetOptions(options Options, keepAlive string) {
+	c.options = options
+	c.keepAlive = keepAlive
+}
+
+// ChatSnapshot 对话上下文快照，用于持久化和恢复对话
+type ChatSnapshot struct {
+	ModelName     string        `json:"model_name"`        // 使用的模型名称
+	SystemMessage string        `json:"system_message"`    // 系统提示词
+	Summary       string        `json:"summary,omitempty"` // 被裁剪历史的滚动摘要
+	History       []ChatMessage `json:"history"`           // 对话历史
+}
+
+// Snapshot 导出对话上下文快照
+func (c *ChatContext) Snapshot() ChatSnapshot {
+	history := make([]ChatMessage, len(c.history))
+	copy(history, c.history)
+	return ChatSnapshot{
+		ModelName:     c.modelName,
+		SystemMessage: c.systemMessage.Content,
+		Summary:       c.summary,
+		History:       history,
+	}
+}
+
+// Restore 从快照恢复对话上下文，模型和上下文窗口配置保持不变
+// 模型以当前配置为准，避免快照中的模型已被删除或更换
+// 参数 snapshot: 对话上下文快照
+func (c *ChatContext) Restore(snapshot ChatSnapshot) {
+	c.systemMessage = ChatMessage{Role: "system", Content: snapshot.SystemMessage}
+	c.summary = snapshot.Summary
+	c.history = make([]ChatMessage, len(snapshot.History))
+	copy(c.history, snapshot.History)
 }
 
 // chatMessagesFromChatString 将字符串转换为单次对话的消息数组
@@ -37,12 +129,156 @@ func (c *ChatContext) addChatString(content string) {
 	c.history = append(c.history, ChatMessage{Role: "user", Content: content})
 }
 
+// rollback 撤销最近新增的消息
+// 裁剪只会丢弃最早的轮次，新增的消息始终位于历史末尾
+// 参数 count: 新增的消息数
+func (c *ChatContext) rollback(count int) {
+	c.history = c.history[:max(len(c.history)-count, 0)]
+}
+
 // getMessages 获取完整的消息列表，用于发送给 LLM
-// 格式：系统消息 + 对话历史
+// 格式：系统消息 + 历史摘要（如果有）+ 对话历史
 // 返回: 消息数组，第一个是系统消息，后面是对话历史
 func (c *ChatContext) getMessages() []ChatMessage {
 	messages := make([]ChatMessage, 1)
 	messages[0] = c.systemMessage
+	if c.summary != "" && c.window.Summarizer != nil {
+		messages = append(messages, ChatMessage{Role: "system", Content: c.window.Summarizer.SummaryContextMessage(c.summary)})
+	}
 	messages = append(messages, c.history...)
 	return messages
 }
+
+// RecentHistory 获取最近几轮对话的文本，用于结合上下文改写问题
+// 只包含用户消息和助手回答，每条消息只保留结尾的 maxRunes 个字符
+// 用户消息中检索到的文档位于问题之前，截取结尾可以保留问题本身
+// 参数 turns: 对话轮数
+// 参数 maxRunes: 每条消息保留的最大字符数
+// 返回: 格式化的对话历史，没有历史时为空字符串
+func (c *ChatContext) RecentHistory(turns int, maxRunes int) string {
+	start := len(c.history)
+	for count := 0; start > 0 && count < turns; {
+		start--
+		if c.history[start].Role == "user" {
+			count++
+		}
+	}
+	var messages []ChatMessage
+	for _, message := range c.history[start:] {
+		if (message.Role != "user" && message.Role != "assistant") || message.Content == "" {
+			continue
+		}
+		if runes := []rune(message.Content); len(runes) > maxRunes {
+			message.Content = "..." + string(runes[len(runes)-maxRunes:])
+		}
+		messages = append(messages, message)
+	}
+	return formatHistory(messages)
+}
+
+// setSummary 更新滚动摘要
+func (c *ChatContext) setSummary(summary string) {
+	c.summary = summary
+}
+
+// estimateTokens 估算消息列表的 token 数
+// 本地启发式估算后乘以校准比例
+func (c *ChatContext) estimateTokens(messages []ChatMessage) int {
+	return int(float64(estimateMessagesTokens(messages)) * c.tokenRatio)
+}
+
+// calibrate 根据 Ollama 返回的实际提示词 token 数校准估算比例
+// 参数 messages: 本次发送的消息列表
+// 参数 promptEvalCount: Ollama 返回的提示词 token 数
+func (c *ChatContext) calibrate(messages []ChatMessage, promptEvalCount int) {
+	estimated := estimateMessagesTokens(messages)
+	if promptEvalCount <= 0 || estimated <= 0 {
+		return
+	}
+	// Ollama 命中提示词缓存时 PromptEvalCount 会偏小，此时不参与校准
+	ratio := float64(promptEvalCount) / float64(estimated)
+	if ratio < 0.5 {
+		return
+	}
+	// 平滑处理，避免单次偏差影响过大
+	c.tokenRatio = c.tokenRatio*0.5 + ratio*0.5
+}
+
+// trim 按上下文窗口配置裁剪历史记录，始终保留系统消息和最后一条用户消息
+// 以完整的对话轮次（用户消息 + 助手回答）为单位丢弃
+// 返回: 被丢弃的消息、裁剪后是否仍超出预算
+func (c *ChatContext) trim() ([]ChatMessage, bool) {
+	var dropped []ChatMessage
+	if c.window.Strategy == TrimKeepLastN && c.window.KeepTurns > 0 {
+		for countTurns(c.history) > c.window.KeepTurns {
+			dropped = append(dropped, c.dropOldestTurn()...)
+		}
+	}
+
+	if c.window.MaxTokens <= 0 {
+		return dropped, false
+	}
+	for c.estimateTokens(c.getMessages()) > c.window.MaxTokens {
+		// 只剩最后一条用户消息时无法继续裁剪
+		if countTurns(c.history) <= 1 {
+			return dropped, true
+		}
+		dropped = append(dropped, c.dropOldestTurn()...)
+	}
+	return dropped, false
+}
+
+// dropOldestTurn 丢弃最早的一轮对话
+// 返回: 被丢弃的消息
+func (c *ChatContext) dropOldestTurn() []ChatMessage {
+	// 一轮对话从用户消息开始，到下一条用户消息之前结束
+	end := 1
+	for end < len(c.history) && c.history[end].Role != "user" {
+		end++
+	}
+	dropped := c.history[:end:end]
+	c.history = c.history[end:]
+	return dropped
+}
+
+// formatHistory 将消息列表格式化为文本，用于生成摘要
+func formatHistory(messages []ChatMessage) string {
+	var builder strings.Builder
+	for _, message := range messages {
+		builder.WriteString(message.Role)
+		builder.WriteString(": ")
+		builder.WriteString(message.Content)
+		builder.WriteString("\n")
+	}
+	return builder.String()
+}
+
+// countTurns 统计对话轮数（以用户消息计）
+func countTurns(history []ChatMessage) int {
+	turns := 0
+	for _, message := range history {
+		if message.Role == "user" {
+			turns++
+		}
+	}
+	return turns
+}
+
+// estimateMessagesTokens 本地启发式估算消息列表的 token 数
+// 中日韩字符按每字 1 个 token，其他字符按每 4 个字符 1 个 token，每条消息额外计 4 个 token 的格式开销
+func estimateMessagesTokens(messages []ChatMessage) int {
+	total := 0
+	for _, message := range messages {
+		cjk, other := 0, 0
+		for _, r := range message.Content {
+			if unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+				unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r) {
+				cjk++
+			} else {
+				other++
+			}
+		}
+		total += cjk + (other+3)/4 + 4
+	}
+	return total
+}
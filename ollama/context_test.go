@@ -0,0 +1,58 @@
+package ollama
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestContextTrim(t *testing.T) {
+	newTurns := func(turns int, content string) *ChatContext {
+		chatCtx := newChat("model", 0, "system")
+		for i := 0; i < turns; i++ {
+			chatCtx.addChatString(content)
+			chatCtx.addMessage(ChatMessage{Role: "assistant", Content: content})
+		}
+		chatCtx.addChatString("last")
+		return chatCtx
+	}
+	{ // case keep last n
+		chatCtx := newTurns(5, "hello")
+		chatCtx.SetContextWindow(ContextWindow{Strategy: TrimKeepLastN, KeepTurns: 2})
+		dropped, overflow := chatCtx.trim()
+		if len(dropped) != 8 || overflow {
+			t.Fatalf("expected drop 8 messages, got %d overflow %v", len(dropped), overflow)
+		}
+		messages := chatCtx.getMessages()
+		if messages[0].Role != "system" || messages[len(messages)-1].Content != "last" {
+			t.Fatal("expected keep system message and last question")
+		}
+	}
+	{ // case drop oldest by token budget
+		chatCtx := newTurns(10, strings.Repeat("哈", 100))
+		chatCtx.SetContextWindow(ContextWindow{MaxTokens: 500, Strategy: TrimDropOldest})
+		_, overflow := chatCtx.trim()
+		if overflow || chatCtx.estimateTokens(chatCtx.getMessages()) > 500 {
+			t.Fatal("expected history within budget")
+		}
+		if countTurns(chatCtx.history) < 2 {
+			t.Fatal("expected keep recent turns")
+		}
+	}
+	{ // case overflow
+		chatCtx := newChat("model", 0, "system")
+		chatCtx.addChatString(strings.Repeat("哈", 1000))
+		chatCtx.SetContextWindow(ContextWindow{MaxTokens: 100, Strategy: TrimDropOldest})
+		_, overflow := chatCtx.trim()
+		if !overflow || len(chatCtx.history) != 1 {
+			t.Fatal("expected overflow and keep last question")
+		}
+	}
+	{ // case recent history
+		chatCtx := newTurns(3, "hello")
+		chatCtx.addMessage(ChatMessage{Role: "assistant", Content: "请阅读以下文字：很长的文档\n问题：他是谁？"})
+		history := chatCtx.RecentHistory(2, 7)
+		if history != "user: hello\nassistant: hello\nuser: last\nassistant: ...问题：他是谁？\n" {
+			t.Fatalf("unexpected recent history: %q", history)
+		}
+	}
+}
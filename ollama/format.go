@@ -0,0 +1,39 @@
+package ollama
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// jsonFormat 不指定 Schema 时的 format 取值，仅要求模型输出合法 JSON
+var jsonFormat = json.RawMessage(`"json"`)
+
+// formatFromSchema 将 JSON Schema 转换为请求的 format 字段
+// 参数 schema: JSON Schema，为空时使用 "json"
+// 返回: format 字段的取值
+func formatFromSchema(schema json.RawMessage) json.RawMessage {
+	if len(schema) == 0 {
+		return jsonFormat
+	}
+	return schema
+}
+
+// decodeStructured 将模型返回的结构化文本解码到 result
+// 部分模型即使在 format 约束下也会用 ```json 代码块包裹输出，解码前先去除
+// 参数 content: 模型返回的文本
+// 参数 result: 解码目标，需为指针
+// 返回: error
+func decodeStructured(content string, result interface{}) error {
+	text := strings.TrimSpace(content)
+	if strings.HasPrefix(text, "```") {
+		text = strings.TrimPrefix(text, "```json")
+		text = strings.TrimPrefix(text, "```")
+		text = strings.TrimSuffix(text, "```")
+		text = strings.TrimSpace(text)
+	}
+	if err := json.Unmarshal([]byte(text), result); err != nil {
+		return fmt.Errorf("decode structured output: %w", err)
+	}
+	return nil
+}
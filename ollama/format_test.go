@@ -0,0 +1,39 @@
+package ollama
+
+import "testing"
+
+func TestDecodeStructured(t *testing.T) {
+	type review struct {
+		Score  int    `json:"score"`
+		Review string `json:"review"`
+	}
+	{ // case plain json
+		var result review
+		if err := decodeStructured(`{"score": 85, "review": "score: 写得不错"}`, &result); err != nil {
+			t.Fatal(err)
+		}
+		if result.Score != 85 || result.Review != "score: 写得不错" {
+			t.Fatalf("unexpected result: %+v", result)
+		}
+	}
+	{ // case code fence
+		var result review
+		if err := decodeStructured("```json\n{\"score\": 60, \"review\": \"ok\"}\n```", &result); err != nil {
+			t.Fatal(err)
+		}
+		if result.Score != 60 {
+			t.Fatalf("unexpected result: %+v", result)
+		}
+	}
+	{ // case not json
+		var result review
+		if err := decodeStructured("score: 60 review: ok", &result); err == nil {
+			t.Fatal("expected decode error")
+		}
+	}
+	{ // case default format
+		if string(formatFromSchema(nil)) != `"json"` {
+			t.Fatal("expected json format")
+		}
+	}
+}
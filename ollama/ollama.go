@@ -1,6 +1,8 @@
 package ollama
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -15,32 +17,53 @@ import (
 // 负责与本地 Ollama 服务通信，管理模型和对话上下文
 type OllamaManager interface {
 	GetAvailableModelName(modelName string) string
+	ResolveModelName(modelName string) string
 	GetDefaultEmbedModelName() string
 	GetDefaultLlmModelName() string
 	ChatWithoutContext(modelName string, message string) (string, error)
 	NewChat(modelName string, systemMessage string) *ChatContext
 	NextChat(chatCtx *ChatContext, message string) (string, error)
+	NextChatWithTools(chatCtx *ChatContext, messages []ChatMessage, tools []Tool) (ChatMessage, error)
+	ChatWithoutContextJSON(modelName string, message string, schema json.RawMessage, result interface{}) (string, error)
+	NextChatJSON(chatCtx *ChatContext, message string, schema json.RawMessage, result interface{}) (string, error)
+	ChatWithoutContextStream(ctx context.Context, modelName string, message string) chan StreamChunk
+	SetDefaultOptions(options Options, keepAlive string)
+	Embed(modelName string, input []string) ([][]float32, error)
+	NextChatStream(ctx context.Context, chatCtx *ChatContext, message string) chan StreamChunk
 	// 统计信息
 	GetTotalQCount() int
 	GetTotalACount() int
 	GetTotalDuration() time.Duration
 	GetTotalToken() int
+	GetTotalEmbedCount() int
+	GetTotalEmbedToken() int
 }
 
 // ollamaManager Ollama 服务管理器实现（包私有）
 type ollamaManager struct {
-	domain string            // Ollama 服务地址
-	models []string          // 可用的模型列表
+	domain string             // Ollama 服务地址
+	models []string           // 可用的模型列表
 	logger logger.ErrorLogger // 日志记录器
 
-	mu            sync.RWMutex // 保护并发访问的读写锁
-	autogenChatId int          // 自动生成的对话 ID，用于区分不同的对话上下文
+	mu               sync.RWMutex // 保护并发访问的读写锁
+	autogenChatId    int          // 自动生成的对话 ID，用于区分不同的对话上下文
+	defaultOptions   Options      // 默认的模型生成参数
+	defaultKeepAlive string       // 默认的模型驻留时间
 
 	// 数据统计（需要并发保护）
 	totalQCount   int           // 总问题数
 	totalACount   int           // 总回答数
 	totalDuration time.Duration // 总响应时间
 	totalToken    int           // 总 token 使用量
+	totalEmbed    int           // 总向量化请求数
+	totalEmbedTok int           // 总向量化 token 数
+}
+
+// StreamChunk 流式对话的增量输出，通过 channel 实时返回
+type StreamChunk struct {
+	Content string // 增量内容
+	Done    bool   // 是否为最后一块，为 true 时 Content 为完整回答
+	Err     error  // 错误信息，出错后 channel 会关闭
 }
 
 var (
@@ -103,6 +126,22 @@ func (o *ollamaManager) GetAvailableModelName(modelName string) string {
 	return ""
 }
 
+// ResolveModelName 将配置的模型名称解析为可用的完整模型名称
+// 依次尝试完整名称、补全 :latest 标签、关键词模糊匹配
+// 参数 modelName: 配置的模型名称
+// 返回: 完整的模型名称，如果未找到则返回空字符串
+func (o *ollamaManager) ResolveModelName(modelName string) string {
+	if modelName == "" {
+		return ""
+	}
+	for _, model := range o.models {
+		if model == modelName || model == modelName+":latest" {
+			return model
+		}
+	}
+	return o.GetAvailableModelName(modelName)
+}
+
 // GetDefaultEmbedModelName 获取默认的嵌入模型名称
 // 用于文档向量化
 func (o *ollamaManager) GetDefaultEmbedModelName() string {
@@ -121,14 +160,37 @@ func (o *ollamaManager) GetDefaultLlmModelName() string {
 // 参数 message: 用户消息
 // 返回: LLM 生成的回答、error
 func (o *ollamaManager) ChatWithoutContext(modelName string, message string) (string, error) {
+	return o.chatWithoutContext(modelName, message, nil)
+}
+
+// ChatWithoutContextJSON 单次结构化对话，不维护上下文
+// 通过 format 约束模型按 JSON Schema 输出，并将结果解码到 result
+// 参数 modelName: 模型名称
+// 参数 message: 用户消息
+// 参数 schema: 输出的 JSON Schema，为 nil 时仅要求输出 JSON
+// 参数 result: 解码目标，需为指针
+// 返回: 模型返回的原始文本（解码失败时可用于降级解析）、error
+func (o *ollamaManager) ChatWithoutContextJSON(modelName string, message string, schema json.RawMessage, result interface{}) (string, error) {
+	content, err := o.chatWithoutContext(modelName, message, formatFromSchema(schema))
+	if err != nil {
+		return "", err
+	}
+	return content, decodeStructured(content, result)
+}
+
+// chatWithoutContext 单次对话的实现
+// 参数 format: 输出格式，为 nil 时输出普通文本
+func (o *ollamaManager) chatWithoutContext(modelName string, message string, format json.RawMessage) (string, error) {
 	o.logger.LogInfo("q#: " + message)
-	
+
 	o.mu.Lock()
 	o.totalQCount++
 	o.mu.Unlock()
 
 	start := time.Now()
-	response, err := sendChatRequest(o.domain, modelName, chatMessagesFromChatString(message))
+	requestData := o.newChatRequest(modelName, chatMessagesFromChatString(message), nil)
+	requestData.Format = format
+	response, err := sendChatRequest(o.domain, requestData)
 	if err != nil {
 		o.logger.LogError(fmt.Errorf("send chat err: %v", err), "sendchat")
 		return "", fmt.Errorf("chat request failed: %w", err)
@@ -167,21 +229,48 @@ func (o *ollamaManager) NewChat(modelName string, systemMessage string) *ChatCon
 // 将新的消息添加到历史记录，调用 LLM 生成回答，并保存回答到历史
 // 参数 chatCtx: 对话上下文
 // 参数 message: 用户消息
+// 发送前按上下文窗口裁剪历史记录，避免历史记录过长导致 token 超限
 // 返回: LLM 生成的回答、error
-// todo 上下文优化：实现有限上下文窗口，避免历史记录过长导致 token 超限
 func (o *ollamaManager) NextChat(chatCtx *ChatContext, message string) (string, error) {
+	return o.nextChat(chatCtx, message, nil)
+}
+
+// NextChatJSON 继续进行结构化对话，维护上下文
+// 通过 format 约束模型按 JSON Schema 输出，并将结果解码到 result
+// 参数 chatCtx: 对话上下文
+// 参数 message: 用户消息
+// 参数 schema: 输出的 JSON Schema，为 nil 时仅要求输出 JSON
+// 参数 result: 解码目标，需为指针
+// 返回: 模型返回的原始文本（解码失败时可用于降级解析）、error（请求失败时新增的消息不会保留在历史中）
+func (o *ollamaManager) NextChatJSON(chatCtx *ChatContext, message string, schema json.RawMessage, result interface{}) (string, error) {
+	content, err := o.nextChat(chatCtx, message, formatFromSchema(schema))
+	if err != nil {
+		return "", err
+	}
+	return content, decodeStructured(content, result)
+}
+
+// nextChat 多轮对话的实现
+// 参数 format: 输出格式，为 nil 时输出普通文本
+func (o *ollamaManager) nextChat(chatCtx *ChatContext, message string, format json.RawMessage) (string, error) {
 	o.logger.LogInfo("q" + strconv.Itoa(chatCtx.chatId) + ": " + message)
-	
+
 	o.mu.Lock()
 	o.totalQCount++
 	o.mu.Unlock()
 
 	// 问题+历史记录
 	chatCtx.addChatString(message)
+	o.trimContext(chatCtx)
+	messages := chatCtx.getMessages()
 
 	start := time.Now()
-	response, err := sendChatRequest(o.domain, chatCtx.modelName, chatCtx.getMessages())
+	requestData := o.newChatRequest(chatCtx.modelName, messages, chatCtx)
+	requestData.Format = format
+	response, err := sendChatRequest(o.domain, requestData)
 	if err != nil {
+		// 回滚新增的消息，便于调用者降级重试
+		chatCtx.rollback(1)
 		o.logger.LogError(fmt.Errorf("send chat err: %v", err), "sendchat")
 		return "", fmt.Errorf("chat request failed: %w", err)
 	}
@@ -198,12 +287,261 @@ func (o *ollamaManager) NextChat(chatCtx *ChatContext, message string) (string,
 
 	o.logger.LogInfo("a" + strconv.Itoa(chatCtx.chatId) + ": " + respMessage.Content)
 
-	// 保存历史记录
+	// 保存历史记录，并用实际 token 数校准估算
 	chatCtx.addMessage(respMessage)
+	chatCtx.calibrate(messages, response.PromptEvalCount)
 
 	return respMessage.Content, nil
 }
 
+// NextChatWithTools 继续进行对话，允许模型调用工具
+// 新消息可以是用户消息，也可以是上一轮工具调用的结果（role 为 tool）
+// 返回的消息包含 ToolCalls 时，调用者需要执行工具并将结果再次传入，直到模型给出最终回答
+// 参数 chatCtx: 对话上下文
+// 参数 messages: 新增的消息
+// 参数 tools: 可供模型调用的工具
+// 返回: 模型返回的消息、error（出错时新增的消息不会保留在历史中）
+func (o *ollamaManager) NextChatWithTools(chatCtx *ChatContext, messages []ChatMessage, tools []Tool) (ChatMessage, error) {
+	for _, message := range messages {
+		o.logger.LogInfo("q" + strconv.Itoa(chatCtx.chatId) + "(" + message.Role + "): " + message.Content)
+	}
+
+	o.mu.Lock()
+	o.totalQCount++
+	o.mu.Unlock()
+
+	// 问题+历史记录
+	for _, message := range messages {
+		chatCtx.addMessage(message)
+	}
+	o.trimContext(chatCtx)
+	allMessages := chatCtx.getMessages()
+
+	start := time.Now()
+	requestData := o.newChatRequest(chatCtx.modelName, allMessages, chatCtx)
+	requestData.Tools = tools
+	response, err := sendChatRequest(o.domain, requestData)
+	if err != nil {
+		// 回滚新增的消息，便于调用者降级重试
+		chatCtx.rollback(len(messages))
+		o.logger.LogError(fmt.Errorf("send chat err: %v", err), "sendchat tools")
+		return ChatMessage{}, fmt.Errorf("chat request failed: %w", err)
+	}
+
+	// 统计
+	elapsed := time.Since(start)
+	respMessage := response.Message
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.totalACount++
+	o.totalDuration += elapsed
+	o.totalToken += response.EvalCount
+
+	for _, call := range respMessage.ToolCalls {
+		o.logger.LogInfo("t" + strconv.Itoa(chatCtx.chatId) + ": " + call.Function.Name + " " + string(call.Function.Arguments))
+	}
+	o.logger.LogInfo("a" + strconv.Itoa(chatCtx.chatId) + ": " + respMessage.Content)
+
+	// 保存历史记录，并用实际 token 数校准估算
+	chatCtx.addMessage(respMessage)
+	chatCtx.calibrate(allMessages, response.PromptEvalCount)
+
+	return respMessage, nil
+}
+
+// ChatWithoutContextStream 单次流式对话，不维护上下文
+// 参数 ctx: 请求的 context，取消后停止生成，channel 返回错误后关闭
+// 参数 modelName: 模型名称
+// 参数 message: 用户消息
+// 返回: StreamChunk channel，逐块返回增量内容，最后一块 Done 为 true
+// 注意：返回的 channel 需要调用者消费，否则会导致 goroutine 阻塞
+func (o *ollamaManager) ChatWithoutContextStream(ctx context.Context, modelName string, message string) chan StreamChunk {
+	o.logger.LogInfo("q#: " + message)
+
+	o.mu.Lock()
+	o.totalQCount++
+	o.mu.Unlock()
+
+	chStream := make(chan StreamChunk)
+	go func() {
+		defer close(chStream)
+		response, err := o.streamChat(ctx, o.newChatRequest(modelName, chatMessagesFromChatString(message), nil), chStream)
+		if err != nil {
+			chStream <- StreamChunk{Err: err}
+			return
+		}
+		respMessage := response.Message
+		o.logger.LogInfo("a#: " + respMessage.Content)
+		chStream <- StreamChunk{Content: respMessage.Content, Done: true}
+	}()
+	return chStream
+}
+
+// NextChatStream 继续进行流式对话，维护上下文
+// 与 NextChat 相同，完整回答在流结束后保存到历史，出错或取消时新增的消息不会保留在历史中
+// 参数 ctx: 请求的 context，取消后停止生成，channel 返回错误后关闭
+// 参数 chatCtx: 对话上下文
+// 参数 message: 用户消息
+// 返回: StreamChunk channel，逐块返回增量内容，最后一块 Done 为 true
+// 注意：返回的 channel 需要调用者消费，否则会导致 goroutine 阻塞
+func (o *ollamaManager) NextChatStream(ctx context.Context, chatCtx *ChatContext, message string) chan StreamChunk {
+	o.logger.LogInfo("q" + strconv.Itoa(chatCtx.chatId) + ": " + message)
+
+	o.mu.Lock()
+	o.totalQCount++
+	o.mu.Unlock()
+
+	// 问题+历史记录
+	chatCtx.addChatString(message)
+	o.trimContext(chatCtx)
+	messages := chatCtx.getMessages()
+
+	chStream := make(chan StreamChunk)
+	go func() {
+		defer close(chStream)
+		response, err := o.streamChat(ctx, o.newChatRequest(chatCtx.modelName, messages, chatCtx), chStream)
+		if err != nil {
+			// 回滚新增的消息，避免历史中留下没有回答的问题
+			chatCtx.rollback(1)
+			chStream <- StreamChunk{Err: err}
+			return
+		}
+		respMessage := response.Message
+		o.logger.LogInfo("a" + strconv.Itoa(chatCtx.chatId) + ": " + respMessage.Content)
+
+		// 保存历史记录，并用实际 token 数校准估算
+		chatCtx.addMessage(respMessage)
+		chatCtx.calibrate(messages, response.PromptEvalCount)
+		chStream <- StreamChunk{Content: respMessage.Content, Done: true}
+	}()
+	return chStream
+}
+
+// streamChat 发送流式请求，将增量内容写入 channel，并根据 done 块更新统计
+// 参数 ctx: 请求的 context
+// 参数 requestData: 请求结构
+// 参数 chStream: 增量内容输出的 channel
+// 返回: 最终的 ChatResponse（包含完整的回答消息）、error
+func (o *ollamaManager) streamChat(ctx context.Context, requestData ChatRequest, chStream chan StreamChunk) (*ChatResponse, error) {
+	start := time.Now()
+	response, err := sendChatStreamRequest(ctx, o.domain, requestData, func(content string) {
+		chStream <- StreamChunk{Content: content}
+	})
+	if err != nil {
+		o.logger.LogError(fmt.Errorf("send chat err: %v", err), "sendchat stream")
+		return nil, fmt.Errorf("chat request failed: %w", err)
+	}
+
+	// 统计
+	elapsed := time.Since(start)
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.totalACount++
+	o.totalDuration += elapsed
+	o.totalToken += response.EvalCount
+
+	return response, nil
+}
+
+// SetDefaultOptions 设置默认的模型生成参数
+// 对话上下文中设置的参数会覆盖默认参数
+// 参数 options: 默认的模型生成参数
+// 参数 keepAlive: 默认的模型驻留时间（如 "10m"，负数表示常驻），为空时使用 Ollama 的默认值
+func (o *ollamaManager) SetDefaultOptions(options Options, keepAlive string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.defaultOptions = options
+	o.defaultKeepAlive = keepAlive
+}
+
+// Embed 调用 Ollama 的 /api/embed 接口将文本向量化
+// 参数 modelName: 嵌入模型名称
+// 参数 input: 需要向量化的文本，支持批量
+// 返回: 向量数组，与输入文本一一对应、error
+func (o *ollamaManager) Embed(modelName string, input []string) ([][]float32, error) {
+	if modelName == "" {
+		return nil, fmt.Errorf("no embed model")
+	}
+
+	o.mu.RLock()
+	keepAlive := o.defaultKeepAlive
+	o.mu.RUnlock()
+
+	response, err := sendEmbedRequest(o.domain, EmbedRequest{
+		Model:     modelName,
+		Input:     input,
+		KeepAlive: keepAlive,
+	})
+	if err != nil {
+		o.logger.LogError(fmt.Errorf("send embed err: %v", err), "embed")
+		return nil, fmt.Errorf("embed request failed: %w", err)
+	}
+
+	// 统计（向量化耗时不计入回答的响应时间）
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.totalEmbed++
+	o.totalEmbedTok += response.PromptEvalCount
+
+	return response.Embeddings, nil
+}
+
+// newChatRequest 构建聊天请求，合并默认参数和对话上下文中的参数
+// 参数 modelName: 模型名称
+// 参数 messages: 消息列表
+// 参数 chatCtx: 对话上下文，为 nil 时只使用默认参数
+// 返回: ChatRequest
+func (o *ollamaManager) newChatRequest(modelName string, messages []ChatMessage, chatCtx *ChatContext) ChatRequest {
+	o.mu.RLock()
+	options := o.defaultOptions
+	keepAlive := o.defaultKeepAlive
+	o.mu.RUnlock()
+
+	if chatCtx != nil {
+		options = options.merge(chatCtx.options)
+		if chatCtx.keepAlive != "" {
+			keepAlive = chatCtx.keepAlive
+		}
+	}
+
+	requestData := ChatRequest{
+		Model:     modelName,
+		Messages:  messages,
+		KeepAlive: keepAlive,
+	}
+	if !options.isEmpty() {
+		requestData.Options = &options
+	}
+	return requestData
+}
+
+// trimContext 按上下文窗口裁剪对话历史，并记录裁剪和溢出情况
+// 配置了摘要时，被裁剪的历史会与已有摘要合并压缩为新的摘要
+// 参数 chatCtx: 对话上下文
+func (o *ollamaManager) trimContext(chatCtx *ChatContext) {
+	dropped, overflow := chatCtx.trim()
+	if len(dropped) > 0 {
+		o.logger.LogInfo("c" + strconv.Itoa(chatCtx.chatId) + ": trimmed " + strconv.Itoa(len(dropped)) + " history messages")
+		if summarizer := chatCtx.window.Summarizer; summarizer != nil {
+			message := summarizer.SummaryMessage(chatCtx.summary, formatHistory(dropped))
+			summary, err := o.ChatWithoutContext(chatCtx.modelName, message)
+			if err != nil {
+				// 摘要失败时保留旧摘要，被裁剪的历史直接丢弃
+				o.logger.LogError(err, "context summary", "chat "+strconv.Itoa(chatCtx.chatId))
+			} else {
+				chatCtx.setSummary(summary)
+			}
+		}
+	}
+	if overflow {
+		o.logger.LogError(fmt.Errorf("context overflow: %d estimated tokens exceed budget %d",
+			chatCtx.estimateTokens(chatCtx.getMessages()), chatCtx.window.MaxTokens),
+			"context window", "chat "+strconv.Itoa(chatCtx.chatId))
+	}
+}
+
 // GetTotalQCount 获取总问题数
 func (o *ollamaManager) GetTotalQCount() int {
 	o.mu.RLock()
@@ -231,3 +569,17 @@ func (o *ollamaManager) GetTotalToken() int {
 	defer o.mu.RUnlock()
 	return o.totalToken
 }
+
+// GetTotalEmbedCount 获取总向量化请求数
+func (o *ollamaManager) GetTotalEmbedCount() int {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return o.totalEmbed
+}
+
+// GetTotalEmbedToken 获取总向量化 token 数
+func (o *ollamaManager) GetTotalEmbedToken() int {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return o.totalEmbedTok
+}
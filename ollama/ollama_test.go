@@ -0,0 +1,174 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"go-ollama/logger"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveModelName(t *testing.T) {
+	o := &ollamaManager{models: []string{"deepseek-r1:8b", "gemma3:latest", "qwen3:8b", "qwen3:14b"}}
+	{ // case full name
+		if o.ResolveModelName("qwen3:14b") != "qwen3:14b" {
+			t.Fatal("expected exact match")
+		}
+	}
+	{ // case latest tag
+		if o.ResolveModelName("gemma3") != "gemma3:latest" {
+			t.Fatal("expected latest tag match")
+		}
+	}
+	{ // case keyword
+		if o.ResolveModelName("deepseek") != "deepseek-r1:8b" {
+			t.Fatal("expected keyword match")
+		}
+	}
+	{ // case not available
+		if o.ResolveModelName("llama") != "" || o.ResolveModelName("") != "" {
+			t.Fatal("expected empty model name")
+		}
+	}
+}
+
+func TestEmbed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/embed" {
+			http.NotFound(w, r)
+			return
+		}
+		var request EmbedRequest
+		json.NewDecoder(r.Body).Decode(&request)
+		response := EmbedResponse{Model: request.Model, PromptEvalCount: 3 * len(request.Input)}
+		for range request.Input {
+			response.Embeddings = append(response.Embeddings, []float32{0.6, 0.8})
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+
+	o := &ollamaManager{domain: server.URL}
+	{ // case batch
+		embeddings, err := o.Embed("embed", []string{"a", "b"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(embeddings) != 2 || embeddings[1][1] != 0.8 {
+			t.Fatalf("unexpected embeddings: %v", embeddings)
+		}
+		if o.GetTotalEmbedCount() != 1 || o.GetTotalEmbedToken() != 6 {
+			t.Fatal("expected embed stats updated")
+		}
+	}
+	{ // case no model
+		if _, err := o.Embed("", []string{"a"}); err == nil {
+			t.Fatal("expected error without embed model")
+		}
+	}
+}
+
+func TestChatStream(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request ChatRequest
+		json.NewDecoder(r.Body).Decode(&request)
+		if !request.Stream {
+			t.Error("expected stream request")
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		encoder := json.NewEncoder(w)
+		for _, content := range []string{"哈利", "是", "巫师"} {
+			encoder.Encode(ChatResponse{Message: ChatMessage{Role: "assistant", Content: content}})
+			w.(http.Flusher).Flush()
+		}
+		if request.Model == "slow" {
+			// 模拟仍在生成中的长回答，直到客户端取消
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+			return
+		}
+		encoder.Encode(ChatResponse{Message: ChatMessage{Role: "assistant"}, Done: true, PromptEvalCount: 7, EvalCount: 3})
+	}))
+	defer server.Close()
+	defer close(release)
+
+	{ // case decode ndjson chunks
+		var chunks []string
+		response, err := sendChatStreamRequest(context.Background(), server.URL, ChatRequest{Model: "m"}, func(content string) {
+			chunks = append(chunks, content)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Join(chunks, "|") != "哈利|是|巫师" || response.Message.Content != "哈利是巫师" || response.Message.Role != "assistant" {
+			t.Fatalf("unexpected stream result: %q %+v", chunks, response.Message)
+		}
+		if response.PromptEvalCount != 7 || response.EvalCount != 3 {
+			t.Fatalf("expected stats from done chunk, got %+v", response)
+		}
+	}
+	{ // case canceled by caller
+		ctx, cancel := context.WithCancel(context.Background())
+		_, err := sendChatStreamRequest(ctx, server.URL, ChatRequest{Model: "slow"}, func(content string) {
+			if content == "巫师" {
+				cancel()
+			}
+		})
+		if err == nil || !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected canceled error, got %v", err)
+		}
+	}
+	{ // case stream manager
+		errorLogger, err := logger.NewErrorLogger(filepath.Join(t.TempDir(), "test.log"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer errorLogger.Close()
+		o := &ollamaManager{domain: server.URL, logger: errorLogger}
+		var content string
+		for chunk := range o.ChatWithoutContextStream(context.Background(), "m", "哈利是谁？") {
+			if chunk.Err != nil {
+				t.Fatal(chunk.Err)
+			}
+			if chunk.Done {
+				content = chunk.Content
+			}
+		}
+		if content != "哈利是巫师" || o.GetTotalToken() != 3 {
+			t.Fatalf("unexpected stream content: %q", content)
+		}
+	}
+	{ // case canceled stream rolls back the question
+		errorLogger, err := logger.NewErrorLogger(filepath.Join(t.TempDir(), "test.log"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer errorLogger.Close()
+		o := &ollamaManager{domain: server.URL, logger: errorLogger}
+		chatCtx := newChat("slow", 1, "system")
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		var streamErr error
+		for chunk := range o.NextChatStream(ctx, chatCtx, "哈利是谁？") {
+			if chunk.Content == "巫师" {
+				cancel()
+			}
+			if chunk.Err != nil {
+				streamErr = chunk.Err
+			}
+		}
+		if !errors.Is(streamErr, context.Canceled) {
+			t.Fatalf("expected canceled error, got %v", streamErr)
+		}
+		if history := chatCtx.Snapshot().History; len(history) != 0 {
+			t.Fatalf("unanswered question left in history: %+v", history)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package ollama
+
+// Options 模型生成参数，对应 Ollama API 的 options 字段
+// 字段为 nil 时不发送，由 Ollama 使用模型自身的默认值
+type Options struct {
+	Temperature *float64 `json:"temperature,omitempty"` // 采样温度，越高回答越发散
+	NumCtx      *int     `json:"num_ctx,omitempty"`     // 上下文长度（token 数）
+	TopP        *float64 `json:"top_p,omitempty"`       // 核采样概率阈值
+	Seed        *int     `json:"seed,omitempty"`        // 随机种子，固定后相同输入得到相同输出
+	Stop        []string `json:"stop,omitempty"`        // 停止词，生成到任意一个时结束
+}
+
+// merge 用 override 中已设置的字段覆盖当前参数
+// 参数 override: 覆盖的参数
+// 返回: 合并后的参数
+func (o Options) merge(override Options) Options {
+	if override.Temperature != nil {
+		o.Temperature = override.Temperature
+	}
+	if override.NumCtx != nil {
+		o.NumCtx = override.NumCtx
+	}
+	if override.TopP != nil {
+		o.TopP = override.TopP
+	}
+	if override.Seed != nil {
+		o.Seed = override.Seed
+	}
+	if override.Stop != nil {
+		o.Stop = override.Stop
+	}
+	return o
+}
+
+// isEmpty 是否没有设置任何参数
+func (o Options) isEmpty() bool {
+	return o.Temperature == nil && o.NumCtx == nil && o.TopP == nil && o.Seed == nil && o.Stop == nil
+}
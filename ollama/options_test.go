@@ -0,0 +1,44 @@
+package ollama
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestNewChatRequestOptions(t *testing.T) {
+	temperature := 0.7
+	seed := 42
+	o := &ollamaManager{}
+	{ // case no options
+		request := o.newChatRequest("m", nil, nil)
+		data, _ := json.Marshal(request)
+		var fields map[string]interface{}
+		json.Unmarshal(data, &fields)
+		if _, ok := fields["options"]; ok {
+			t.Fatal("expected options omitted")
+		}
+		if _, ok := fields["keep_alive"]; ok {
+			t.Fatal("expected keep_alive omitted")
+		}
+	}
+	o.SetDefaultOptions(Options{Temperature: &temperature}, "10m")
+	{ // case defaults
+		request := o.newChatRequest("m", nil, nil)
+		if request.Options == nil || *request.Options.Temperature != 0.7 || request.KeepAlive != "10m" {
+			t.Fatalf("unexpected request: %+v", request)
+		}
+	}
+	{ // case context override
+		low := 0.1
+		chatCtx := newChat("m", 0, "")
+		chatCtx.SetOptions(Options{Temperature: &low, Seed: &seed}, "-1m")
+		request := o.newChatRequest("m", nil, chatCtx)
+		if *request.Options.Temperature != 0.1 || *request.Options.Seed != 42 || request.KeepAlive != "-1m" {
+			t.Fatalf("unexpected request: %+v", request)
+		}
+		// 默认参数不受影响
+		if *o.newChatRequest("m", nil, nil).Options.Temperature != 0.7 {
+			t.Fatal("expected default temperature unchanged")
+		}
+	}
+}
@@ -2,39 +2,72 @@ package ollama
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"strings"
 	"time"
 )
 
 // ChatRequest Ollama API 聊天请求结构
 type ChatRequest struct {
-	Model    string        `json:"model"`    // 模型名称
-	Messages []ChatMessage `json:"messages"` // 消息列表
-	Stream   bool          `json:"stream"`   // 是否流式输出（当前未使用）
+	Model     string          `json:"model"`                // 模型名称
+	Messages  []ChatMessage   `json:"messages"`             // 消息列表
+	Stream    bool            `json:"stream"`               // 是否流式输出
+	Tools     []Tool          `json:"tools,omitempty"`      // 可供模型调用的工具
+	Format    json.RawMessage `json:"format,omitempty"`     // 输出格式，"json" 或 JSON Schema
+	Options   *Options        `json:"options,omitempty"`    // 模型生成参数
+	KeepAlive string          `json:"keep_alive,omitempty"` // 请求结束后模型在内存中的驻留时间
 }
 
 // ChatMessage 对话消息结构
 type ChatMessage struct {
-	Role    string `json:"role"`    // 角色：system/user/assistant
-	Content string `json:"content"` // 消息内容
+	Role      string     `json:"role"`                 // 角色：system/user/assistant/tool
+	Content   string     `json:"content"`              // 消息内容
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"` // 模型请求的工具调用（role 为 assistant 时）
+	ToolName  string     `json:"tool_name,omitempty"`  // 工具名称（role 为 tool 时）
+}
+
+// Tool 工具定义，对应 Ollama API 的 tools 字段
+type Tool struct {
+	Type     string       `json:"type"`     // 工具类型，固定为 function
+	Function ToolFunction `json:"function"` // 函数定义
+}
+
+// ToolFunction 工具函数定义
+type ToolFunction struct {
+	Name        string          `json:"name"`        // 函数名称
+	Description string          `json:"description"` // 函数说明，供模型判断何时调用
+	Parameters  json.RawMessage `json:"parameters"`  // 参数的 JSON Schema
+}
+
+// ToolCall 模型返回的工具调用
+type ToolCall struct {
+	Function ToolCallFunction `json:"function"`
+}
+
+// ToolCallFunction 工具调用的函数名和参数
+type ToolCallFunction struct {
+	Name      string          `json:"name"`      // 函数名称
+	Arguments json.RawMessage `json:"arguments"` // 参数（JSON 对象）
 }
 
 // ChatResponse Ollama API 聊天响应结构
 type ChatResponse struct {
-	Model              string      `json:"model"`                         // 使用的模型
-	CreatedAt          string      `json:"created_at"`                    // 创建时间
-	Message            ChatMessage `json:"message"`                       // 返回的消息
-	Done               bool        `json:"done"`                          // 是否完成
-	DoneReason         string      `json:"done_reason"`                   // 完成原因
-	TotalDuration      int64       `json:"total_duration,omitempty"`      // 总耗时（纳秒）
-	LoadDuration       int64       `json:"load_duration,omitempty"`       // 模型加载耗时
-	PromptEvalCount    int         `json:"prompt_eval_count,omitempty"`   // 提示词 token 数
-	PromptEvalDuration int64       `json:"prompt_eval_duration,omitempty"`// 提示词评估耗时
-	EvalCount          int         `json:"eval_count,omitempty"`          // 生成 token 数
-	EvalDuration       int64       `json:"eval_duration,omitempty"`       // 生成耗时
+	Model              string      `json:"model"`                          // 使用的模型
+	CreatedAt          string      `json:"created_at"`                     // 创建时间
+	Message            ChatMessage `json:"message"`                        // 返回的消息
+	Done               bool        `json:"done"`                           // 是否完成
+	DoneReason         string      `json:"done_reason"`                    // 完成原因
+	TotalDuration      int64       `json:"total_duration,omitempty"`       // 总耗时（纳秒）
+	LoadDuration       int64       `json:"load_duration,omitempty"`        // 模型加载耗时
+	PromptEvalCount    int         `json:"prompt_eval_count,omitempty"`    // 提示词 token 数
+	PromptEvalDuration int64       `json:"prompt_eval_duration,omitempty"` // 提示词评估耗时
+	EvalCount          int         `json:"eval_count,omitempty"`           // 生成 token 数
+	EvalDuration       int64       `json:"eval_duration,omitempty"`        // 生成耗时
 }
 
 // listModels 列出本地 Ollama 服务可用的所有模型
@@ -71,29 +104,158 @@ func listModels(domain string) ([]string, error) {
 	return models, nil
 }
 
-// sendChatRequest 发送聊天请求到 Ollama API
+// chatTimeout 等待 Ollama 返回响应头的超时时间
+// 非流式请求在生成完成后才返回响应头；流式请求的响应体不限时，由调用者通过 context 取消
+const chatTimeout = 180 * time.Second
+
+// dialTimeout 连接 Ollama 服务的超时时间
+const dialTimeout = 10 * time.Second
+
+// chatClient 聊天请求使用的 HTTP 客户端
+// 不设置 http.Client.Timeout：它限制的是读取整个响应体的时间，会截断较长的流式回答
+var chatClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           (&net.Dialer{Timeout: dialTimeout}).DialContext,
+		ResponseHeaderTimeout: chatTimeout,
+	},
+}
+
+// postChatRequest 发送聊天请求到 Ollama API，返回未读取的响应
+// 调用者负责关闭响应体
+// 参数 ctx: 请求的 context，取消后请求（包括读取流式响应体）立即结束
+// 参数 domain: Ollama 服务地址
+// 参数 requestData: 请求结构
+// 返回: http.Response、error
+func postChatRequest(ctx context.Context, domain string, requestData ChatRequest) (*http.Response, error) {
+	jsonData, err := json.Marshal(requestData)
+	if err != nil {
+		return nil, fmt.Errorf("json error: %v", err)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, domain+"/api/chat", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("http request error: %v", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	resp, err := chatClient.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("http request error: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("api error: %s - %s", resp.Status, string(body))
+	}
+	return resp, nil
+}
+
+// sendChatRequest 发送非流式聊天请求到 Ollama API
 // 参数 domain: Ollama 服务地址
-// 参数 model: 模型名称
-// 参数 messages: 消息列表
+// 参数 requestData: 请求结构，Tools 为 nil 时不启用工具调用，Format 为 nil 时输出普通文本
 // 返回: ChatResponse、error
-func sendChatRequest(domain string, model string, messages []ChatMessage) (*ChatResponse, error) {
-	requestData := ChatRequest{
-		Model:    model,
-		Messages: messages,
-		Stream:   false,
+func sendChatRequest(domain string, requestData ChatRequest) (*ChatResponse, error) {
+	requestData.Stream = false
+
+	resp, err := postChatRequest(context.Background(), domain, requestData)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read resp error: %v", err)
 	}
 
+	var chatResp ChatResponse
+	if err := json.Unmarshal(body, &chatResp); err != nil {
+		return nil, fmt.Errorf("json error: %v", err)
+	}
+
+	return &chatResp, nil
+}
+
+// sendChatStreamRequest 发送流式聊天请求到 Ollama API
+// Ollama 以 NDJSON 格式逐行返回响应块，每个块携带增量内容，最后一个块 done 为 true 并携带统计信息
+// 参数 ctx: 请求的 context，调用者取消后停止读取并返回错误
+// 参数 domain: Ollama 服务地址
+// 参数 requestData: 请求结构
+// 参数 onChunk: 每收到一个响应块时的回调，参数为增量内容
+// 返回: 最终的 ChatResponse（Message 为拼接后的完整内容，统计信息来自 done 块）、error
+func sendChatStreamRequest(ctx context.Context, domain string, requestData ChatRequest, onChunk func(content string)) (*ChatResponse, error) {
+	requestData.Stream = true
+
+	resp, err := postChatRequest(ctx, domain, requestData)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var builder strings.Builder
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var chunk ChatResponse
+		if err := decoder.Decode(&chunk); err != nil {
+			if err == io.EOF {
+				return nil, fmt.Errorf("stream closed before done")
+			}
+			if ctx.Err() != nil {
+				return nil, fmt.Errorf("stream canceled: %w", ctx.Err())
+			}
+			return nil, fmt.Errorf("json error: %v", err)
+		}
+
+		if chunk.Message.Content != "" {
+			builder.WriteString(chunk.Message.Content)
+			onChunk(chunk.Message.Content)
+		}
+
+		// done 块携带本次请求的统计信息
+		if chunk.Done {
+			chunk.Message.Role = "assistant"
+			chunk.Message.Content = builder.String()
+			return &chunk, nil
+		}
+	}
+}
+
+// EmbedRequest Ollama API 向量化请求结构
+type EmbedRequest struct {
+	Model     string   `json:"model"`                // 嵌入模型名称
+	Input     []string `json:"input"`                // 需要向量化的文本，支持批量
+	KeepAlive string   `json:"keep_alive,omitempty"` // 请求结束后模型在内存中的驻留时间
+}
+
+// EmbedResponse Ollama API 向量化响应结构
+type EmbedResponse struct {
+	Model           string      `json:"model"`                       // 使用的模型
+	Embeddings      [][]float32 `json:"embeddings"`                  // 向量，与输入文本一一对应
+	TotalDuration   int64       `json:"total_duration,omitempty"`    // 总耗时（纳秒）
+	PromptEvalCount int         `json:"prompt_eval_count,omitempty"` // 输入 token 数
+}
+
+// embedTimeout 单次向量化请求的超时时间
+const embedTimeout = 60 * time.Second
+
+// sendEmbedRequest 发送向量化请求到 Ollama API
+// 参数 domain: Ollama 服务地址
+// 参数 requestData: 请求结构
+// 返回: EmbedResponse、error
+func sendEmbedRequest(domain string, requestData EmbedRequest) (*EmbedResponse, error) {
 	jsonData, err := json.Marshal(requestData)
 	if err != nil {
 		return nil, fmt.Errorf("json error: %v", err)
 	}
 
 	client := &http.Client{
-		Timeout: 180 * time.Second,
+		Timeout: embedTimeout,
 	}
 
 	resp, err := client.Post(
-		domain+"/api/chat",
+		domain+"/api/embed",
 		"application/json",
 		bytes.NewBuffer(jsonData),
 	)
@@ -102,20 +264,21 @@ func sendChatRequest(domain string, model string, messages []ChatMessage) (*Chat
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("api error: %s - %s", resp.Status, string(body))
-	}
-
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, fmt.Errorf("read resp error: %v", err)
 	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("api error: %s - %s", resp.Status, string(body))
+	}
 
-	var chatResp ChatResponse
-	if err := json.Unmarshal(body, &chatResp); err != nil {
+	var embedResp EmbedResponse
+	if err := json.Unmarshal(body, &embedResp); err != nil {
 		return nil, fmt.Errorf("json error: %v", err)
 	}
+	if len(embedResp.Embeddings) != len(requestData.Input) {
+		return nil, fmt.Errorf("embedding count mismatch: %d inputs, %d embeddings", len(requestData.Input), len(embedResp.Embeddings))
+	}
 
-	return &chatResp, nil
+	return &embedResp, nil
 }
@@ -0,0 +1,152 @@
+package rag
+
+import (
+	"math"
+	"sort"
+	"sync"
+)
+
+// BM25 参数
+const (
+	bm25K1 = 1.2  // 词频饱和参数，越大词频的影响越大
+	bm25B  = 0.75 // 文本块长度归一化参数
+)
+
+// bm25Index 基于分词结果的 BM25 倒排索引
+// 弥补向量检索对人名、术语等精确词语不敏感的问题，索引只保存在内存中，打开知识库时从向量集合重建
+type bm25Index struct {
+	mu       sync.RWMutex
+	chunks   map[string]*bm25Chunk     // 文本块 ID 到文本块的映射
+	postings map[string]map[string]int // 词语到包含它的文本块 ID 和词频的映射
+	totalLen int                       // 所有文本块的词语总数，用于计算平均长度
+}
+
+// bm25Chunk 索引中的文本块
+type bm25Chunk struct {
+	docId  string         // 所属文档 ID
+	index  int            // 在文档中的序号
+	length int            // 词语数
+	terms  map[string]int // 词语到词频的映射
+}
+
+// bm25Hit 关键词检索命中的文本块
+type bm25Hit struct {
+	docId string  // 所属文档 ID
+	index int     // 在文档中的序号
+	score float64 // BM25 得分
+}
+
+// newBm25Index 创建空的 BM25 索引
+func newBm25Index() *bm25Index {
+	return &bm25Index{
+		chunks:   make(map[string]*bm25Chunk),
+		postings: make(map[string]map[string]int),
+	}
+}
+
+// add 添加或替换文本块
+// 参数 docId: 文档 ID
+// 参数 index: 在文档中的序号
+// 参数 tokens: 文本块的分词结果
+func (b *bm25Index) add(docId string, index int, tokens []string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	id := chunkId(docId, index)
+	b.remove(id)
+	chunk := &bm25Chunk{docId: docId, index: index, length: len(tokens), terms: make(map[string]int)}
+	for _, token := range tokens {
+		chunk.terms[token]++
+	}
+	for term, tf := range chunk.terms {
+		if b.postings[term] == nil {
+			b.postings[term] = make(map[string]int)
+		}
+		b.postings[term][id] = tf
+	}
+	b.chunks[id] = chunk
+	b.totalLen += chunk.length
+}
+
+// removeFrom 删除文档中序号不小于 start 的文本块
+func (b *bm25Index) removeFrom(docId string, start int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for id, chunk := range b.chunks {
+		if chunk.docId == docId && chunk.index >= start {
+			b.remove(id)
+		}
+	}
+}
+
+// removeDocument 删除文档的所有文本块
+func (b *bm25Index) removeDocument(docId string) {
+	b.removeFrom(docId, 0)
+}
+
+// remove 删除文本块，调用者需要持有写锁
+func (b *bm25Index) remove(id string) {
+	chunk, ok := b.chunks[id]
+	if !ok {
+		return
+	}
+	for term := range chunk.terms {
+		delete(b.postings[term], id)
+		if len(b.postings[term]) == 0 {
+			delete(b.postings, term)
+		}
+	}
+	b.totalLen -= chunk.length
+	delete(b.chunks, id)
+}
+
+// search 检索与查询词语最相关的文本块
+// 参数 tokens: 查询文本的分词结果，重复的词语只计算一次
+// 参数 n: 返回的最大数量
+// 返回: 按得分从高到低排序的文本块，不包含得分为 0 的文本块
+func (b *bm25Index) search(tokens []string, n int) []bm25Hit {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if len(b.chunks) == 0 {
+		return nil
+	}
+	count := float64(len(b.chunks))
+	avgLen := float64(b.totalLen) / count
+	scores := make(map[string]float64)
+	seen := make(map[string]bool)
+	for _, token := range tokens {
+		if seen[token] {
+			continue
+		}
+		seen[token] = true
+		postings := b.postings[token]
+		if len(postings) == 0 {
+			continue
+		}
+		df := float64(len(postings))
+		idf := math.Log(1 + (count-df+0.5)/(df+0.5))
+		for id, tf := range postings {
+			length := float64(b.chunks[id].length)
+			f := float64(tf)
+			scores[id] += idf * f * (bm25K1 + 1) / (f + bm25K1*(1-bm25B+bm25B*length/avgLen))
+		}
+	}
+
+	hits := make([]bm25Hit, 0, len(scores))
+	for id, score := range scores {
+		chunk := b.chunks[id]
+		hits = append(hits, bm25Hit{docId: chunk.docId, index: chunk.index, score: score})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].score != hits[j].score {
+			return hits[i].score > hits[j].score
+		}
+		if hits[i].docId != hits[j].docId {
+			return hits[i].docId < hits[j].docId
+		}
+		return hits[i].index < hits[j].index
+	})
+	if len(hits) > n {
+		hits = hits[:n]
+	}
+	return hits
+}
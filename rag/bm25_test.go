@@ -0,0 +1,80 @@
+package rag
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBm25Index(t *testing.T) {
+	index := newBm25Index()
+	index.add("a", 0, []string{"哈利", "魔法", "学校"})
+	index.add("a", 1, []string{"斯内普", "教授", "魔药"})
+	index.add("b", 0, []string{"魔法", "魔法", "部"})
+
+	{ // case exact term
+		hits := index.search([]string{"斯内普", "斯内普"}, 10)
+		if len(hits) != 1 || hits[0].docId != "a" || hits[0].index != 1 {
+			t.Fatalf("unexpected hits: %+v", hits)
+		}
+	}
+	{ // case term frequency
+		hits := index.search([]string{"魔法"}, 10)
+		if len(hits) != 2 || hits[0].docId != "b" {
+			t.Fatalf("expected higher term frequency first, got %+v", hits)
+		}
+	}
+	{ // case remove
+		index.removeFrom("a", 1)
+		index.add("b", 0, []string{"部长"})
+		if hits := index.search([]string{"斯内普", "魔法"}, 10); len(hits) != 1 || hits[0].docId != "a" {
+			t.Fatalf("unexpected hits after remove: %+v", hits)
+		}
+	}
+}
+
+func TestHybridRetrieval(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "hp.txt")
+	os.WriteFile(source, []byte("哈利是一个巫师。罗恩是他的朋友。斯内普是魔药课教授。"), 0644)
+	open := func() (*ragManager, *RagContext) {
+		r, err := newRagManager(nil, nil, &fakeEmbedder{model: "m1"}, nil, filepath.Join(dir, "vectors"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ragCtx, err := r.OpenCollection("hp", CollectionOptions{Chunk: ChunkOptions{Strategy: ChunkerSentence, MinSize: 1, MaxSize: 10}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r, ragCtx
+	}
+	keywordOnly := retrievalWeights{keyword: 1}
+	query := func(text string) []retrievalQuery { return []retrievalQuery{{text: text}} }
+
+	{ // case keyword retrieval
+		r, ragCtx := open()
+		chProg, _ := r.AddDocuments(ragCtx, []DocumentSource{{Id: "hp", Source: source}})
+		for range chProg {
+		}
+		refs, err := r.retrieve(ragCtx, query("斯内普"), keywordOnly)
+		if err != nil || len(refs) != 1 || refs[0].content != "斯内普是魔药课教授。" {
+			t.Fatalf("unexpected keyword result: %+v %v", refs, err)
+		}
+		if refs, _ := r.retrieve(ragCtx, query("斯內普是誰？"), keywordOnly); len(refs) != 1 || refs[0].index != 2 {
+			t.Fatalf("expected traditional query normalized, got %+v", refs)
+		}
+		if refs, _ := r.retrieve(ragCtx, query("斯内普"), retrievalWeights{vector: 1, keyword: 1}); len(refs) != 3 {
+			t.Fatalf("expected fused result of all chunks, got %+v", refs)
+		}
+	}
+	{ // case index rebuilt after restart and delete
+		r, ragCtx := open()
+		if refs, _ := r.retrieve(ragCtx, query("斯内普"), keywordOnly); len(refs) != 1 || refs[0].index != 2 {
+			t.Fatalf("expected keyword index rebuilt, got %+v", refs)
+		}
+		r.DeleteDocument(ragCtx, "hp")
+		if refs, _ := r.retrieve(ragCtx, query("斯内普"), keywordOnly); len(refs) != 0 {
+			t.Fatalf("expected keyword index cleared, got %+v", refs)
+		}
+	}
+}
@@ -2,7 +2,11 @@ package rag
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"maps"
+	"path/filepath"
 	"strconv"
 	"sync"
 
@@ -14,29 +18,62 @@ import (
 type ChromemManager struct {
 	mu            sync.RWMutex                // 保护并发访问的读写锁
 	db            *chromem.DB                 // Chromem 数据库实例
+	dir           string                      // 持久化目录，为空时只保存在内存中
 	collectionMap map[int]*chromem.Collection // RAG ID 到向量集合的映射
+	embedder      Embeddable                  // 向量化接口
 }
 
-// ollamaEmbedModelName Ollama 嵌入模型名称，用于文档向量化
-const ollamaEmbedModelName = "nomic-embed-text-v2-moe"
-
+// chunkRef 检索命中的文本块
+type chunkRef struct {
+	docId      string  // 所属文档 ID
+	index      int     // 在文档中的序号
+	source     string  // 源文件路径
+	offset     int     // 在源文件中的字节偏移
+	page       int     // 起始页码，没有分页的文档为 0
+	endPage    int     // 结束页码，跨页的文本块大于 page
+	paragraph  int     // 第一个段落的序号
+	content    string  // 文本块原文
+	similarity float32 // 向量检索的相似度，只在向量检索的结果中有效
+}
 
 // newChromemManager 创建并初始化向量数据库管理器
-func newChromemManager() *ChromemManager {
+// 参数 embedder: 向量化接口，文档和查询文本都通过它向量化
+// 参数 dir: 向量数据库的持久化目录，为空时只保存在内存中
+// 返回: ChromemManager 实例、error
+func newChromemManager(embedder Embeddable, dir string) (*ChromemManager, error) {
+	db := chromem.NewDB()
+	if dir != "" {
+		var err error
+		db, err = chromem.NewPersistentDB(dir, true)
+		if err != nil {
+			return nil, fmt.Errorf("open vector db: %w", err)
+		}
+	}
 	return &ChromemManager{
-		db:            chromem.NewDB(),
+		db:            db,
+		dir:           dir,
 		collectionMap: make(map[int]*chromem.Collection),
+		embedder:      embedder,
+	}, nil
+}
+
+// embeddingFunc 将向量化接口适配为 Chromem 的 EmbeddingFunc
+func (c *ChromemManager) embeddingFunc() chromem.EmbeddingFunc {
+	return func(ctx context.Context, text string) ([]float32, error) {
+		return c.embedder.EmbedText(text)
 	}
 }
 
-// newCollection 为指定的 RAG 上下文创建新的向量集合
+// newCollection 为指定的 RAG 上下文打开向量集合
+// 集合以知识库名称命名，持久化时重启后可以复用已有的向量
 // 参数 ragId: RAG 上下文 ID
+// 参数 name: 知识库名称
 // 返回: error
-func (c *ChromemManager) newCollection(ragId int) error {
-	collection, err := c.db.CreateCollection(
-		"rag-"+strconv.Itoa(ragId),
-		nil,
-		chromem.NewEmbeddingFuncOllama(ollamaEmbedModelName, ""))
+func (c *ChromemManager) newCollection(ragId int, name string) error {
+	collection, err := c.db.GetOrCreateCollection(
+		"kb-"+name,
+		map[string]string{"name": name},
+		c.embeddingFunc())
 	if err != nil {
 		return err
 	}
@@ -46,45 +83,173 @@ func (c *ChromemManager) newCollection(ragId int) error {
 	return nil
 }
 
-// addDocuments 添加文档到向量集合
-// 会自动调用 Ollama 进行向量化，然后存储到向量数据库
+// manifestPath 获取知识库文档清单的持久化路径
+// 清单保存在持久化目录的顶层，Chromem 加载时会忽略顶层的文件
+// 参数 name: 知识库名称
+// 返回: 文件路径，只保存在内存中时返回空字符串
+func (c *ChromemManager) manifestPath(name string) string {
+	if c.dir == "" {
+		return ""
+	}
+	return filepath.Join(c.dir, "kb-"+hashText(name)[:16]+".json")
+}
+
+// addChunk 添加文本块到向量集合
+// 集合中已有原文、内容哈希和嵌入模型都相同的文本块时直接复用，否则调用 Ollama 进行向量化后存储
+// 参数 ragId: RAG 上下文 ID
+// 参数 ref: 文本块的所属文档、序号、来源和原文，原文检索时原样返回
+// 参数 words: 用于向量化的文本（规范化和分词后的文本）
+// 返回: 是否复用了已有的向量、error
+func (c *ChromemManager) addChunk(ragId int, ref chunkRef, words string) (bool, error) {
+	ctx := context.Background()
+	collection, err := c.getCollection(ragId)
+	if err != nil {
+		return false, err
+	}
+
+	id := chunkId(ref.docId, ref.index)
+	metadata := map[string]string{
+		"doc":      ref.docId,
+		"index":    strconv.Itoa(ref.index),
+		"source":   ref.source,
+		"offset":   strconv.Itoa(ref.offset),
+		"page":     strconv.Itoa(ref.page),
+		"end_page": strconv.Itoa(ref.endPage),
+		"para":     strconv.Itoa(ref.paragraph),
+		"hash":     hashText(words),
+		"model":    c.embedder.EmbedModelName(),
+	}
+	if doc, err := collection.GetByID(ctx, id); err == nil && doc.Content == ref.content &&
+		doc.Metadata["hash"] == metadata["hash"] && doc.Metadata["model"] == metadata["model"] {
+		if maps.Equal(doc.Metadata, metadata) {
+			return true, nil
+		}
+		// 只有来源或位置变化时，复用已有向量更新元数据
+		return true, collection.AddDocument(ctx, chromem.Document{ID: id, Metadata: metadata, Embedding: doc.Embedding, Content: ref.content})
+	}
+	embedding, err := c.embedder.EmbedText(words)
+	if err != nil {
+		return false, err
+	}
+	return false, collection.AddDocument(ctx, chromem.Document{ID: id, Metadata: metadata, Embedding: embedding, Content: ref.content})
+}
+
+// removeChunksFrom 删除文档中序号不小于 count 的文本块
+// 文档变短后，清理多余的旧文本块
 // 参数 ragId: RAG 上下文 ID
-// 参数 index: 文档索引（用作文档 ID）
-// 参数 content: 文档内容
+// 参数 docId: 文档 ID
+// 参数 count: 保留的文本块数量
 // 返回: error
-func (c *ChromemManager) addDocuments(ragId int, index int, content string) error {
+func (c *ChromemManager) removeChunksFrom(ragId int, docId string, count int) error {
 	ctx := context.Background()
+	collection, err := c.getCollection(ragId)
+	if err != nil {
+		return err
+	}
+	var ids []string
+	for index := count; ; index++ {
+		id := chunkId(docId, index)
+		if _, err := collection.GetByID(ctx, id); err != nil {
+			break
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	return collection.Delete(ctx, nil, nil, ids...)
+}
+
+// deleteDocument 删除文档的所有文本块
+// 参数 ragId: RAG 上下文 ID
+// 参数 docId: 文档 ID
+// 返回: error
+func (c *ChromemManager) deleteDocument(ragId int, docId string) error {
+	collection, err := c.getCollection(ragId)
+	if err != nil {
+		return err
+	}
+	return collection.Delete(context.Background(), map[string]string{"doc": docId}, nil)
+}
+
+// getChunk 获取文本块原文
+// 参数 ragId: RAG 上下文 ID
+// 参数 docId: 文档 ID
+// 参数 index: 在文档中的序号
+// 返回: 文本块原文、是否存在
+func (c *ChromemManager) getChunk(ragId int, docId string, index int) (string, bool) {
+	collection, err := c.getCollection(ragId)
+	if err != nil {
+		return "", false
+	}
+	doc, err := collection.GetByID(context.Background(), chunkId(docId, index))
+	if err != nil {
+		return "", false
+	}
+	return doc.Content, true
+}
+
+// getCollection 获取 RAG 上下文对应的向量集合
+func (c *ChromemManager) getCollection(ragId int) (*chromem.Collection, error) {
 	c.mu.RLock()
+	defer c.mu.RUnlock()
 	collection, ok := c.collectionMap[ragId]
-	c.mu.RUnlock()
 	if !ok {
-		return fmt.Errorf("collection not found for ragId: %d", ragId)
+		return nil, fmt.Errorf("collection not found for ragId: %d", ragId)
 	}
-	return collection.AddDocument(ctx, chromem.Document{ID: strconv.Itoa(index), Content: content})
+	return collection, nil
+}
+
+// chunkId 文本块在向量集合中的 ID，由文档 ID 和序号组成
+func chunkId(docId string, index int) string {
+	return docId + "#" + strconv.Itoa(index)
+}
+
+// hashText 计算文本的 SHA-256 哈希（十六进制）
+func hashText(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
 }
 
 // query 向量相似度检索
-// 将查询文本向量化，然后检索最相似的文档
+// 将查询文本向量化，然后检索最相似的文本块
 // 参数 ragId: RAG 上下文 ID
 // 参数 text: 查询文本
-// 参数 nResults: 返回的文档数量
-// 返回: 文档索引数组（按相似度排序）、error
-func (c *ChromemManager) query(ragId int, text string, nResults int) ([]int, error) {
+// 参数 nResults: 返回的文本块数量，超过集合大小时返回全部
+// 返回: 文本块数组（按文档 ID 和序号排序）、error
+func (c *ChromemManager) query(ragId int, text string, nResults int) ([]chunkRef, error) {
 	ctx := context.Background()
-	c.mu.RLock()
-	collection, ok := c.collectionMap[ragId]
-	c.mu.RUnlock()
-	if !ok {
-		return nil, fmt.Errorf("collection not found for ragId: %d", ragId)
+	collection, err := c.getCollection(ragId)
+	if err != nil {
+		return nil, err
+	}
+	nResults = min(nResults, collection.Count())
+	if nResults == 0 {
+		return nil, nil
 	}
 	res, err := collection.Query(ctx, text, nResults, nil, nil)
 	if err != nil {
 		return nil, err
 	}
-	var indexArr []int
+	var refs []chunkRef
 	for i := 0; i < len(res); i++ {
-		index, _ := strconv.Atoi(res[i].ID)
-		indexArr = append(indexArr, index)
+		index, _ := strconv.Atoi(res[i].Metadata["index"])
+		offset, _ := strconv.Atoi(res[i].Metadata["offset"])
+		page, _ := strconv.Atoi(res[i].Metadata["page"])
+		endPage, _ := strconv.Atoi(res[i].Metadata["end_page"])
+		paragraph, _ := strconv.Atoi(res[i].Metadata["para"])
+		refs = append(refs, chunkRef{
+			docId:      res[i].Metadata["doc"],
+			index:      index,
+			source:     res[i].Metadata["source"],
+			offset:     offset,
+			page:       page,
+			endPage:    endPage,
+			paragraph:  paragraph,
+			content:    res[i].Content,
+			similarity: res[i].Similarity,
+		})
 	}
-	return indexArr, nil
+	sortChunkRefs(refs)
+	return refs, nil
 }
@@ -0,0 +1,229 @@
+package rag
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeEmbedder 测试用的向量化器，按文本长度生成向量并记录调用次数
+type fakeEmbedder struct {
+	model string
+	calls int
+}
+
+func (f *fakeEmbedder) EmbedText(text string) ([]float32, error) {
+	f.calls++
+	return []float32{float32(len(text)), 1}, nil
+}
+
+func (f *fakeEmbedder) EmbedModelName() string {
+	return f.model
+}
+
+func TestPersistentCollection(t *testing.T) {
+	dir := t.TempDir()
+	vectorDir := filepath.Join(dir, "vectors")
+
+	add := func(embedder *fakeEmbedder, contents ...string) int {
+		chromem, err := newChromemManager(embedder, vectorDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := chromem.newCollection(0, "test"); err != nil {
+			t.Fatal(err)
+		}
+		reusedCount := 0
+		for i, content := range contents {
+			reused, err := chromem.addChunk(0, chunkRef{docId: "doc", index: i, content: content}, content)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if reused {
+				reusedCount++
+			}
+		}
+		if err := chromem.removeChunksFrom(0, "doc", len(contents)); err != nil {
+			t.Fatal(err)
+		}
+		return reusedCount
+	}
+
+	{ // case first run
+		embedder := &fakeEmbedder{model: "m1"}
+		if add(embedder, "a", "bb", "ccc") != 0 || embedder.calls != 3 {
+			t.Fatal("expected all documents embedded")
+		}
+	}
+	{ // case restart
+		embedder := &fakeEmbedder{model: "m1"}
+		if add(embedder, "a", "bb", "ccc") != 3 || embedder.calls != 0 {
+			t.Fatal("expected all documents reused")
+		}
+	}
+	{ // case content changed
+		embedder := &fakeEmbedder{model: "m1"}
+		if add(embedder, "a", "changed") != 1 || embedder.calls != 1 {
+			t.Fatal("expected only changed document embedded")
+		}
+		chromem, _ := newChromemManager(embedder, vectorDir)
+		chromem.newCollection(0, "test")
+		collection, _ := chromem.getCollection(0)
+		if collection.Count() != 2 {
+			t.Fatalf("expected stale documents removed, got %d", collection.Count())
+		}
+	}
+	{ // case model changed
+		embedder := &fakeEmbedder{model: "m2"}
+		if add(embedder, "a", "changed") != 0 || embedder.calls != 2 {
+			t.Fatal("expected all documents embedded with new model")
+		}
+	}
+}
+
+func TestManageDocuments(t *testing.T) {
+	dir := t.TempDir()
+	vectorDir := filepath.Join(dir, "vectors")
+	writeDoc := func(name string, paragraphs ...string) string {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, []byte(strings.Join(paragraphs, "\n\n")), 0644)
+		return path
+	}
+	long := strings.Repeat("x", 100)
+	docA := writeDoc("a.txt", long, "a1", long, "a2")
+	docB := writeDoc("b.txt", long, "b1")
+
+	open := func() (*ragManager, *RagContext) {
+		r, err := newRagManager(nil, nil, &fakeEmbedder{model: "m1"}, nil, vectorDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ragCtx, err := r.OpenCollection("hp", CollectionOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r, ragCtx
+	}
+	drain := func(chProg chan ProgressInfo, err error) int {
+		if err != nil {
+			t.Fatal(err)
+		}
+		errCount := 0
+		for p := range chProg {
+			if p.Err != nil {
+				errCount++
+			}
+		}
+		return errCount
+	}
+
+	{ // case add
+		r, ragCtx := open()
+		drain(r.AddDocuments(ragCtx, []DocumentSource{{Id: "a", Source: docA}, {Id: "b", Source: docB}}))
+		docs := r.ListDocuments(ragCtx)
+		if len(docs) != 2 || docs[0].Id != "a" || docs[0].Chunks != 2 || docs[1].Chunks != 1 {
+			t.Fatalf("unexpected documents: %+v", docs)
+		}
+	}
+	{ // case restart keeps documents
+		r, ragCtx := open()
+		if docs := r.ListDocuments(ragCtx); len(docs) != 2 {
+			t.Fatalf("expected manifest restored, got %+v", docs)
+		}
+		refs, err := r.chromem.query(ragCtx.ragId, "query", 10)
+		if err != nil || len(refs) != 3 || refs[0].docId != "a" || !strings.HasSuffix(refs[0].content, "a1") {
+			t.Fatalf("unexpected query result: %+v %v", refs, err)
+		}
+		if refs[1].source != docA || refs[1].offset != len(long)+6 {
+			t.Fatalf("unexpected chunk origin: %+v", refs[1])
+		}
+	}
+	{ // case update and delete
+		r, ragCtx := open()
+		writeDoc("a.txt", long, "a1")
+		if drain(r.Reindex(ragCtx)) != 0 {
+			t.Fatal("unexpected reindex error")
+		}
+		if doc, _ := ragCtx.getDocument("a"); doc.Chunks != 1 {
+			t.Fatalf("expected document updated, got %+v", doc)
+		}
+		if err := r.DeleteDocument(ragCtx, "b"); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.DeleteDocument(ragCtx, "b"); !errors.Is(err, ErrDocumentNotFound) {
+			t.Fatalf("expected ErrDocumentNotFound, got %v", err)
+		}
+		collection, _ := r.chromem.getCollection(ragCtx.ragId)
+		if collection.Count() != 1 || len(r.ListDocuments(ragCtx)) != 1 {
+			t.Fatalf("expected 1 chunk left, got %d", collection.Count())
+		}
+	}
+	{ // case delete while indexing
+		r, ragCtx := open()
+		chProg, err := r.AddDocuments(ragCtx, []DocumentSource{{Id: "a", Source: docA}, {Id: "b", Source: docB}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		<-chProg
+		if err := r.DeleteDocument(ragCtx, "a"); !errors.Is(err, ErrIndexInProgress) {
+			t.Fatalf("expected ErrIndexInProgress, got %v", err)
+		}
+		drain(chProg, nil)
+		if err := r.DeleteDocument(ragCtx, "b"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	{ // case invalid id
+		r, ragCtx := open()
+		if _, err := r.AddDocuments(ragCtx, []DocumentSource{{Id: "../a", Source: docA}}); !errors.Is(err, ErrInvalidDocumentId) {
+			t.Fatalf("expected ErrInvalidDocumentId, got %v", err)
+		}
+	}
+}
+
+func TestSourceDocuments(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.md", "b.txt", "sub/c.md", "sub/deep/a.md"} {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte("test"), 0644)
+	}
+
+	{ // case double star
+		sources, err := SourceDocuments([]string{filepath.Join(dir, "**/*.md")}, nil)
+		if err != nil || len(sources) != 3 {
+			t.Fatalf("expected 3 markdown files, got %+v %v", sources, err)
+		}
+		if sources[0].Id == sources[2].Id || !strings.HasPrefix(sources[2].Id, "a.md~") || sources[1].Id != "c.md" {
+			t.Fatalf("expected unique ids, got %+v", sources)
+		}
+	}
+	{ // case single star and literal path, duplicates removed
+		sources, err := SourceDocuments([]string{filepath.Join(dir, "*"), filepath.Join(dir, "b.txt")}, nil)
+		if err != nil || len(sources) != 2 || sources[1].Id != "b.txt" || sources[1].Origin != OriginConfig {
+			t.Fatalf("expected top level files, got %+v %v", sources, err)
+		}
+	}
+	{ // case existing ids stay stable when a file with the same name is added
+		existing := []DocumentInfo{
+			{Id: "a.md", Source: filepath.Join(dir, "sub/deep/a.md"), Origin: OriginConfig},
+			{Id: "c.md", Source: filepath.Join(dir, "removed/c.md"), Origin: OriginConfig},
+			{Id: "b.txt", Origin: OriginUpload},
+		}
+		sources, err := SourceDocuments([]string{filepath.Join(dir, "**/*")}, existing)
+		if err != nil || len(sources) != 4 {
+			t.Fatalf("expected 4 files, got %+v %v", sources, err)
+		}
+		ids := make(map[string]string)
+		for _, source := range sources {
+			rel, _ := filepath.Rel(dir, source.Source)
+			ids[filepath.ToSlash(rel)] = source.Id
+		}
+		if ids["sub/deep/a.md"] != "a.md" || !strings.HasPrefix(ids["a.md"], "a.md~") ||
+			ids["sub/c.md"] != "c.md" || !strings.HasPrefix(ids["b.txt"], "b.txt~") {
+			t.Fatalf("unexpected ids: %v", ids)
+		}
+	}
+}
@@ -2,26 +2,42 @@ package rag
 
 import (
 	"bufio"
+	"fmt"
 	"io"
 	"os"
 	"strings"
+	"unicode/utf8"
 )
 
-// minParaCount 每个文本块最少包含的段落数
-const minParaCount = 2
-// minCharCount 每个文本块最少的字符数
-const minCharCount = 100
+// Paragraph 文档加载器解析出的段落
+type Paragraph struct {
+	Text    string // 段落内容
+	Offset  int    // 在源文件中的字节偏移
+	Heading string // 所属章节的标题路径，如 "安装 > Linux"，没有章节时为空
+	Page    int    // 所在页码（从 1 开始），没有分页的文档为 0
+}
 
-// chunksFromTextFile 从文本文件读取并分块
-// 按段落读取文件，然后组合成合适大小的文本块
+// textChunk 文本块
+type textChunk struct {
+	text      string // 文本块内容
+	offset    int    // 第一个段落在源文件中的字节偏移
+	page      int    // 第一个段落所在页码，没有分页的文档为 0
+	endPage   int    // 最后一个段落所在页码，跨页的文本块大于 page
+	paragraph int    // 第一个段落的序号（从 1 开始）
+}
+
+// chunksFromFile 使用文档加载器读取文件并分块
+// 加载器将文件解析为段落，然后由分块策略组合成合适大小的文本块
 // 参数 filePath: 文件路径
+// 参数 loader: 文档加载器
+// 参数 chunker: 分块策略
 // 返回: 文本块数组、error
-func chunksFromTextFile(filePath string) ([]string, error) {
-	paragraphs, err := readParagraphs(filePath)
+func chunksFromFile(filePath string, loader Loader, chunker Chunker) ([]textChunk, error) {
+	paragraphs, err := loader.Load(filePath)
 	if err != nil {
 		return nil, err
 	}
-	chunks := chunksFromParagraphs(paragraphs)
+	chunks := chunker.Chunk(paragraphs)
 	return chunks, nil
 }
 
@@ -37,8 +53,8 @@ func chunksFromTextFile(filePath string) ([]string, error) {
 // readParagraphs 从文件读取段落
 // 按空行分割段落，连续的非空行组成一个段落
 // 参数 filePath: 文件路径
-// 返回: 段落数组、error
-func readParagraphs(filePath string) ([]string, error) {
+// 返回: 段落数组（包含在文件中的偏移）、error
+func readParagraphs(filePath string) ([]Paragraph, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
 		return nil, err
@@ -46,11 +62,15 @@ func readParagraphs(filePath string) ([]string, error) {
 	defer file.Close()
 
 	reader := bufio.NewReader(file)
-	var paragraphs []string
+	var paragraphs []Paragraph
 	var builder strings.Builder
+	// pos 当前行的字节偏移，paraOffset 当前段落的字节偏移
+	var pos, paraOffset int
 
 	for {
 		line, err := reader.ReadString('\n')
+		lineOffset := pos
+		pos += len(line)
 
 		// 去除换行符
 		line = strings.TrimSuffix(line, "\n")
@@ -59,12 +79,14 @@ func readParagraphs(filePath string) ([]string, error) {
 		// 检查是否为空行
 		if strings.TrimSpace(line) == "" {
 			if builder.Len() > 0 {
-				paragraphs = append(paragraphs, builder.String())
+				paragraphs = append(paragraphs, Paragraph{Text: builder.String(), Offset: paraOffset})
 				builder.Reset()
 			}
 		} else {
 			if builder.Len() > 0 {
 				builder.WriteString("\n")
+			} else {
+				paraOffset = lineOffset
 			}
 			builder.WriteString(line)
 		}
@@ -73,7 +95,7 @@ func readParagraphs(filePath string) ([]string, error) {
 		if err != nil {
 			if err == io.EOF {
 				if builder.Len() > 0 {
-					paragraphs = append(paragraphs, builder.String())
+					paragraphs = append(paragraphs, Paragraph{Text: builder.String(), Offset: paraOffset})
 				}
 				break
 			}
@@ -84,39 +106,246 @@ func readParagraphs(filePath string) ([]string, error) {
 	return paragraphs, nil
 }
 
-// chunksFromParagraphs 将段落组合成文本块
-// 策略：至少包含 minParaCount 个段落且字符数 >= minCharCount 才形成一个块
-// 这样可以保证每个块有足够的上下文信息，同时避免块过大
+// Chunker 分块策略接口，将文档加载器解析出的段落组合成文本块
+// 文本块不跨越章节，有章节标题时以标题路径开头
+type Chunker interface {
+	Chunk(paragraphs []Paragraph) []textChunk
+}
+
+// 分块策略
+const (
+	ChunkerParagraph = "paragraph" // 按段落组合，达到最小段落数和最小字符数时形成一个块
+	ChunkerFixed     = "fixed"     // 按固定字符数切分，相邻的块之间重叠
+	ChunkerSentence  = "sentence"  // 按句子（中英文标点）组合，尽量填满最大字符数
+	ChunkerRecursive = "recursive" // 依次按换行、句子、分句、空格递归切分过长的段落，再组合到最大字符数
+)
+
+// ChunkOptions 分块选项，字符数按 Unicode 字符计算，不包含章节标题
+type ChunkOptions struct {
+	Strategy string // 分块策略，为空时按段落组合
+	MinSize  int    // 文本块的最小字符数，0 表示使用默认值
+	MaxSize  int    // 文本块的最大字符数，0 表示使用默认值
+	Overlap  int    // 相邻文本块重叠的字符数，以句子、段落等切分单位为粒度
+}
+
+// minParaCount 按段落组合时，每个文本块最少包含的段落数
+const minParaCount = 2
+
+// 默认的文本块字符数
+const (
+	defaultMinChunkSize = 33   // 最小字符数，保证每个块有足够的上下文信息（约 100 字节的中文，与之前按字节计算的分块结果一致）
+	defaultMaxChunkSize = 1000 // 最大字符数，避免超长的段落成为一个块
+)
+
+// newChunker 根据分块选项创建分块策略
+// 参数 options: 分块选项
+// 返回: 分块策略、error
+func newChunker(options ChunkOptions) (Chunker, error) {
+	// 只配置了一项时，默认值不超过另一项的约束
+	if options.MinSize == 0 {
+		options.MinSize = defaultMinChunkSize
+		if options.MaxSize > 0 {
+			options.MinSize = min(options.MinSize, options.MaxSize)
+		}
+	}
+	if options.MaxSize == 0 {
+		options.MaxSize = max(defaultMaxChunkSize, options.MinSize)
+	}
+	if options.MinSize < 0 || options.MaxSize < options.MinSize {
+		return nil, fmt.Errorf("invalid chunk size: min %d, max %d", options.MinSize, options.MaxSize)
+	}
+	if options.Overlap < 0 || options.Overlap >= options.MaxSize {
+		return nil, fmt.Errorf("invalid chunk overlap: %d, must be less than max size %d", options.Overlap, options.MaxSize)
+	}
+	switch options.Strategy {
+	case "", ChunkerParagraph:
+		return paragraphChunker{options: options}, nil
+	case ChunkerFixed:
+		return fixedChunker{options: options}, nil
+	case ChunkerSentence:
+		return sentenceChunker{options: options}, nil
+	case ChunkerRecursive:
+		return recursiveChunker{options: options}, nil
+	}
+	return nil, fmt.Errorf("unknown chunker: %s", options.Strategy)
+}
+
+// paragraphChunker 按段落组合的分块策略
+// 至少包含 minParaCount 个段落且达到最小字符数才形成一个块，超过最大字符数的段落递归切分
+type paragraphChunker struct {
+	options ChunkOptions
+}
+
+// Chunk 将段落组合成文本块
+func (c paragraphChunker) Chunk(paragraphs []Paragraph) []textChunk {
+	return packSections(paragraphs, c.options, true, func(text string) []string {
+		return splitRecursive(text, c.options.MaxSize, 0)
+	})
+}
+
+// fixedChunker 固定大小的分块策略，忽略段落和句子的边界
+type fixedChunker struct {
+	options ChunkOptions
+}
+
+// Chunk 将段落按固定字符数切分成文本块
+func (c fixedChunker) Chunk(paragraphs []Paragraph) []textChunk {
+	// 切分单位取最大字符数和重叠字符数的最大公约数，使每个块正好在单位的边界上
+	unit := c.options.MaxSize
+	for overlap := c.options.Overlap; overlap > 0; {
+		unit, overlap = overlap, unit%overlap
+	}
+	return packSections(paragraphs, c.options, false, func(text string) []string {
+		return splitRunes(text, unit)
+	})
+}
+
+// sentenceChunker 按句子组合的分块策略，块的边界总在句子结尾
+type sentenceChunker struct {
+	options ChunkOptions
+}
+
+// Chunk 将段落按句子组合成文本块
+func (c sentenceChunker) Chunk(paragraphs []Paragraph) []textChunk {
+	return packSections(paragraphs, c.options, false, func(text string) []string {
+		var parts []string
+		for _, sentence := range splitSentences(text) {
+			parts = append(parts, splitRecursive(sentence, c.options.MaxSize, 0)...)
+		}
+		return parts
+	})
+}
+
+// recursiveChunker 递归切分的分块策略
+// 段落不超过最大字符数时整体保留，否则依次尝试更细的分隔符，再将切分结果组合到最大字符数
+type recursiveChunker struct {
+	options ChunkOptions
+}
+
+// Chunk 将段落递归切分并组合成文本块
+func (c recursiveChunker) Chunk(paragraphs []Paragraph) []textChunk {
+	return packSections(paragraphs, c.options, false, func(text string) []string {
+		return splitRecursive(text, c.options.MaxSize, 0)
+	})
+}
+
+// chunkPiece 组合文本块的最小单位（段落或段落的一部分）
+type chunkPiece struct {
+	text  string // 内容
+	para  int    // 所在段落的下标
+	index int    // 在段落内容中的字节位置
+	size  int    // 字符数
+}
+
+// packSections 按章节将段落切分成片段，再组合成文本块
+// 片段按顺序加入当前块，加入后超过最大字符数时先结束当前块；结束的块末尾不超过重叠字符数的片段作为下一个块的开头
+// 章节末尾不足最小字符数的剩余片段合并到上一个块（不超过最大字符数时）
 // 参数 paragraphs: 段落数组
+// 参数 options: 分块选项
+// 参数 flushAtMin: 是否在达到最小段落数和最小字符数时立即结束当前块（按段落组合），否则尽量填满最大字符数
+// 参数 split: 将段落内容切分成片段，片段按顺序连接后等于原内容
 // 返回: 文本块数组
-func chunksFromParagraphs(paragraphs []string) []string {
-	var chunks []string
-	var chunk string
-	var paraCount int
-
-	for i := 0; i < len(paragraphs); i++ {
-		para := paragraphs[i]
-		chunk += para
-		paraCount++
-
-		// 当达到最小段落数和最小字符数时，形成一个文本块
-		if paraCount >= minParaCount && len(chunk) >= minCharCount {
-			chunks = append(chunks, chunk)
-
-			// 重置，开始下一个块
-			// 注意：这里采用重置策略而不是滑动窗口
-			// 可以根据需要修改为：chunk = para; paraCount = 1（保留最后一段）
-
-			chunk = ""
-			paraCount = 0
+func packSections(paragraphs []Paragraph, options ChunkOptions, flushAtMin bool, split func(text string) []string) []textChunk {
+	var chunks []textChunk
+	for start := 0; start < len(paragraphs); {
+		// 找到当前章节的范围
+		end := start + 1
+		for end < len(paragraphs) && paragraphs[end].Heading == paragraphs[start].Heading {
+			end++
+		}
+
+		var pieces []chunkPiece
+		for i := start; i < end; i++ {
+			index := 0
+			for _, part := range split(paragraphs[i].Text) {
+				if strings.TrimSpace(part) != "" {
+					pieces = append(pieces, chunkPiece{text: part, para: i, index: index, size: utf8.RuneCountInString(part)})
+				}
+				index += len(part)
+			}
+		}
+		chunks = append(chunks, packPieces(paragraphs, pieces, options, flushAtMin)...)
+		start = end
+	}
+	return chunks
+}
+
+// packPieces 将同一章节的片段组合成文本块
+func packPieces(paragraphs []Paragraph, pieces []chunkPiece, options ChunkOptions, flushAtMin bool) []textChunk {
+	var chunks []textChunk
+	var last []chunkPiece    // 上一个文本块的片段，用于合并剩余片段
+	var current []chunkPiece // 当前文本块的片段
+	size, fresh := 0, 0      // 当前块的字符数、重叠部分之后新加入的片段数
+
+	flush := func() {
+		chunks = append(chunks, newTextChunk(paragraphs, current))
+		last = current
+		// 保留末尾的片段作为重叠部分，至少去掉一个片段以保证前进
+		keep, keepSize := len(current), 0
+		for keep > 1 && keepSize+current[keep-1].size <= options.Overlap {
+			keep--
+			keepSize += current[keep].size
 		}
-		// 内容较少时，继续累积到下一个块
+		current = append([]chunkPiece(nil), current[keep:]...)
+		size, fresh = keepSize, 0
 	}
 
-	// 处理剩余的段落（至少 2 段且有内容）
-	if paraCount > 1 && len(chunk) > 0 {
-		chunks = append(chunks, chunk)
+	for _, piece := range pieces {
+		if size+piece.size > options.MaxSize {
+			if fresh > 0 {
+				flush()
+			}
+			// 重叠部分加上新片段超过最大字符数时，缩短重叠部分
+			for len(current) > 0 && size+piece.size > options.MaxSize {
+				size -= current[0].size
+				current = current[1:]
+			}
+		}
+		current = append(current, piece)
+		size += piece.size
+		fresh++
+		if flushAtMin && len(current) >= minParaCount && size >= options.MinSize {
+			flush()
+		}
 	}
 
+	if fresh > 0 {
+		rest := current[len(current)-fresh:]
+		restSize := 0
+		for _, piece := range rest {
+			restSize += piece.size
+		}
+		lastSize := 0
+		for _, piece := range last {
+			lastSize += piece.size
+		}
+		if len(chunks) > 0 && restSize < options.MinSize && lastSize+restSize <= options.MaxSize {
+			chunks[len(chunks)-1] = newTextChunk(paragraphs, append(last, rest...))
+		} else {
+			chunks = append(chunks, newTextChunk(paragraphs, current))
+		}
+	}
 	return chunks
 }
+
+// newTextChunk 使用片段创建文本块
+// 同一段落的片段直接连接，不同段落之间用换行分隔，有章节标题时加在内容之前
+// 偏移为第一个片段所在段落的偏移加上片段在段落中的位置，段落内容与源文件一致时（纯文本、Markdown）是准确的
+// 参数 paragraphs: 段落数组
+// 参数 pieces: 文本块的片段
+func newTextChunk(paragraphs []Paragraph, pieces []chunkPiece) textChunk {
+	var builder strings.Builder
+	for i, piece := range pieces {
+		if i > 0 && piece.para != pieces[i-1].para {
+			builder.WriteString("\n")
+		}
+		builder.WriteString(piece.text)
+	}
+	text := strings.TrimSpace(builder.String())
+	para := paragraphs[pieces[0].para]
+	if para.Heading != "" {
+		text = para.Heading + "\n" + text
+	}
+	endPage := paragraphs[pieces[len(pieces)-1].para].Page
+	return textChunk{text: text, offset: para.Offset + pieces[0].index, page: para.Page, endPage: endPage, paragraph: pieces[0].para + 1}
+}
@@ -0,0 +1,79 @@
+package rag
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestChunkers(t *testing.T) {
+	chunk := func(options ChunkOptions, paragraphs ...string) []textChunk {
+		chunker, err := newChunker(options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var paras []Paragraph
+		offset := 0
+		for _, text := range paragraphs {
+			paras = append(paras, Paragraph{Text: text, Offset: offset})
+			offset += len(text) + 2
+		}
+		return chunker.Chunk(paras)
+	}
+	texts := func(chunks []textChunk) string {
+		var out []string
+		for _, c := range chunks {
+			out = append(out, c.text)
+		}
+		return strings.Join(out, "|")
+	}
+
+	{ // case paragraph with max size
+		long := strings.Repeat("长", 25)
+		chunks := chunk(ChunkOptions{MinSize: 10, MaxSize: 20}, "短", long, "尾")
+		if texts(chunks) != "短|"+strings.Repeat("长", 20)+"|"+strings.Repeat("长", 5)+"\n尾" {
+			t.Fatalf("unexpected paragraph chunks: %q", texts(chunks))
+		}
+		if chunks[2].offset != len("短")+2+len(strings.Repeat("长", 20)) || chunks[2].paragraph != 2 {
+			t.Fatalf("unexpected chunk position: %+v", chunks[2])
+		}
+	}
+	{ // case default min size counts characters
+		para := strings.Repeat("字", 20)
+		chunks := chunk(ChunkOptions{}, para, para, para, para)
+		if len(chunks) != 2 || chunks[1].paragraph != 3 {
+			t.Fatalf("unexpected default chunks: %q", texts(chunks))
+		}
+		if explicit := chunk(ChunkOptions{MinSize: defaultMinChunkSize}, para, para, para, para); texts(explicit) != texts(chunks) {
+			t.Fatalf("explicit default changed chunks: %q", texts(explicit))
+		}
+	}
+	{ // case fixed with overlap
+		chunks := chunk(ChunkOptions{Strategy: ChunkerFixed, MaxSize: 4, Overlap: 2}, "abcdefghij")
+		if texts(chunks) != "abcd|cdef|efgh|ghij" || chunks[3].offset != 6 {
+			t.Fatalf("unexpected fixed chunks: %q", texts(chunks))
+		}
+	}
+	{ // case sentence with overlap
+		chunks := chunk(ChunkOptions{Strategy: ChunkerSentence, MinSize: 1, MaxSize: 6, Overlap: 3}, "甲乙。丙丁！戊己？")
+		if texts(chunks) != "甲乙。丙丁！|丙丁！戊己？" {
+			t.Fatalf("unexpected sentence chunks: %q", texts(chunks))
+		}
+		if sentences := splitSentences("It costs 3.5 dollars. Really?\"Yes\" "); len(sentences) != 3 || sentences[1] != "Really?\"" {
+			t.Fatalf("unexpected sentences: %q", sentences)
+		}
+	}
+	{ // case recursive
+		chunks := chunk(ChunkOptions{Strategy: ChunkerRecursive, MaxSize: 6}, "aaaa bbbb, cccc")
+		if texts(chunks) != "aaaa|bbbb,|cccc" {
+			t.Fatalf("unexpected recursive chunks: %q", texts(chunks))
+		}
+	}
+	{ // case invalid options
+		if _, err := newChunker(ChunkOptions{MaxSize: 10, Overlap: 10}); err == nil {
+			t.Fatal("expected overlap error")
+		}
+		if _, err := newChunker(ChunkOptions{Strategy: "token"}); err == nil {
+			t.Fatal("expected unknown chunker error")
+		}
+	}
+}
@@ -1,7 +1,158 @@
 package rag
 
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrDocumentNotFound 知识库中没有指定的文档
+var ErrDocumentNotFound = errors.New("document not found")
+
+// ErrInvalidDocumentId 文档 ID 不合法
+var ErrInvalidDocumentId = errors.New("invalid document id")
+
+// ErrIndexInProgress 知识库正在建立索引，暂时不能删除文档
+var ErrIndexInProgress = errors.New("knowledge base is being indexed")
+
+// maxDocumentIdLen 文档 ID 的最大长度（字节）
+const maxDocumentIdLen = 128
+
 // RagContext RAG 上下文，存储知识库的相关信息
+// 一个知识库对应一个向量集合，可以包含多篇文档
 type RagContext struct {
-	ragId  int      // RAG 上下文 ID，对应向量数据库中的集合 ID
-	chunks []string // 原始文本块数组，用于根据索引检索完整文本
+	ragId     int                      // RAG 上下文 ID，对应向量数据库中的集合 ID
+	name      string                   // 知识库名称
+	manifest  string                   // 文档清单的持久化路径，为空时只保存在内存中
+	options   CollectionOptions        // 知识库的加载选项
+	reranker  Rerankable               // 知识库使用的重排器，打开知识库时按重排方式创建
+	writeMu   sync.Mutex               // 串行化文档的添加、删除和重建索引，建立索引期间一直持有
+	mu        sync.RWMutex             // 保护文档清单
+	documents map[string]*DocumentInfo // 文档 ID 到文档信息的映射
+	keywords  *bm25Index               // 文本块的关键词索引
+}
+
+// DocumentInfo 知识库中的文档信息
+type DocumentInfo struct {
+	Id        string    `json:"id"`         // 文档 ID，在知识库内唯一
+	Source    string    `json:"source"`     // 源文件路径，重建索引时重新读取
+	Origin    string    `json:"origin"`     // 文档来源：config 或 upload
+	Chunks    int       `json:"chunks"`     // 文本块数量
+	Hash      string    `json:"hash"`       // 源文件内容的哈希
+	UpdatedAt time.Time `json:"updated_at"` // 最后一次建立索引的时间
+}
+
+// DocumentSource 待添加到知识库的文档
+type DocumentSource struct {
+	Id     string // 文档 ID，已存在时更新该文档
+	Source string // 源文件路径
+	Origin string // 文档来源：OriginConfig 或 OriginUpload
+}
+
+// Name 获取知识库名称
+func (c *RagContext) Name() string {
+	return c.name
+}
+
+// checkDocumentId 检查文档 ID 是否合法
+// 文档 ID 会用作向量 ID 的前缀和上传文件的文件名，不能包含路径分隔符和 #
+// 参数 id: 文档 ID
+// 返回: error
+func checkDocumentId(id string) error {
+	if id == "" || len(id) > maxDocumentIdLen || strings.HasPrefix(id, ".") ||
+		strings.ContainsAny(id, "/\\#\x00") {
+		return fmt.Errorf("%w: %q", ErrInvalidDocumentId, id)
+	}
+	return nil
+}
+
+// CheckDocumentId 检查文档 ID 是否合法
+// 参数 id: 文档 ID
+// 返回: error，不合法时可以用 errors.Is(err, ErrInvalidDocumentId) 识别
+func CheckDocumentId(id string) error {
+	return checkDocumentId(id)
+}
+
+// loadManifest 从持久化路径加载文档清单
+// 文件不存在时视为空知识库
+func (c *RagContext) loadManifest() error {
+	c.documents = make(map[string]*DocumentInfo)
+	if c.manifest == "" {
+		return nil
+	}
+	data, err := os.ReadFile(c.manifest)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var documents []*DocumentInfo
+	if err := json.Unmarshal(data, &documents); err != nil {
+		return fmt.Errorf("load manifest %s: %w", c.manifest, err)
+	}
+	for _, doc := range documents {
+		c.documents[doc.Id] = doc
+	}
+	return nil
+}
+
+// saveManifest 保存文档清单，先写临时文件再重命名，避免写到一半时损坏
+func (c *RagContext) saveManifest() error {
+	if c.manifest == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(c.listDocuments(), "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := c.manifest + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.manifest)
+}
+
+// listDocuments 获取按 ID 排序的文档信息
+func (c *RagContext) listDocuments() []DocumentInfo {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	documents := make([]DocumentInfo, 0, len(c.documents))
+	for _, doc := range c.documents {
+		documents = append(documents, *doc)
+	}
+	sort.Slice(documents, func(i, j int) bool {
+		return documents[i].Id < documents[j].Id
+	})
+	return documents
+}
+
+// getDocument 获取文档信息
+func (c *RagContext) getDocument(id string) (DocumentInfo, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	doc, ok := c.documents[id]
+	if !ok {
+		return DocumentInfo{}, false
+	}
+	return *doc, true
+}
+
+// setDocument 添加或更新文档信息
+func (c *RagContext) setDocument(doc DocumentInfo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.documents[doc.Id] = &doc
+}
+
+// removeDocument 删除文档信息
+func (c *RagContext) removeDocument(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.documents, id)
 }
@@ -0,0 +1,256 @@
+package rag
+
+import (
+	"strings"
+	"unicode"
+)
+
+// englishStopwords 英文停用词
+var englishStopwords = toSet(strings.Fields(`
+	a an the and or but if then else of to in on at by for with from into onto about as than so
+	is am are was were be been being do does did done have has had having will would shall should can could may might must
+	i me my mine we us our ours you your yours he him his she her hers it its they them their theirs
+	this that these those there here what which who whom whose when where why how
+	not no nor all any both each few more most other some such only own same too very just also
+	s t d ll m re ve don doesn didn isn aren wasn weren won wouldn shouldn couldn
+`))
+
+// splitEnglishWords 按单词切分英文文本，字母、数字和单词中间的撇号组成一个单词
+// 包含汉字的部分交给 gse 分词，兼容中英混合的文本
+// 参数 text: 规范化后的文本
+// 参数 cut: 中文分词函数
+// 返回: 单词数组
+func splitEnglishWords(text string, cut func(string) []string) []string {
+	var words []string
+	fields := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\''
+	})
+	for _, field := range fields {
+		field = strings.Trim(field, "'")
+		field = strings.TrimSuffix(field, "'s")
+		if field == "" {
+			continue
+		}
+		if strings.ContainsFunc(field, func(r rune) bool { return unicode.Is(unicode.Han, r) }) {
+			words = append(words, cut(field)...)
+			continue
+		}
+		words = append(words, field)
+	}
+	return words
+}
+
+// isEnglishWord 是否为只包含 ASCII 字母的单词
+func isEnglishWord(word string) bool {
+	for i := 0; i < len(word); i++ {
+		if c := word[i]; c < 'a' || c > 'z' {
+			return false
+		}
+	}
+	return word != ""
+}
+
+// porterStem 使用 Porter 算法提取英文单词的词干，如 running -> run、wizards -> wizard
+// 参数 word: 小写的英文单词，包含其他字符时原样返回
+// 返回: 词干
+func porterStem(word string) string {
+	if len(word) <= 2 || !isEnglishWord(word) {
+		return word
+	}
+	w := []byte(word)
+	w = stemStep1ab(w)
+	w = stemStep1c(w)
+	w = replaceSuffix(w, stemStep2Rules, 0)
+	w = replaceSuffix(w, stemStep3Rules, 0)
+	w = stemStep4(w)
+	w = stemStep5(w)
+	return string(w)
+}
+
+// stemStep2Rules Porter 算法第 2 步的后缀替换规则
+var stemStep2Rules = [][2]string{
+	{"ational", "ate"}, {"tional", "tion"}, {"enci", "ence"}, {"anci", "ance"}, {"izer", "ize"},
+	{"bli", "ble"}, {"alli", "al"}, {"entli", "ent"}, {"eli", "e"}, {"ousli", "ous"},
+	{"ization", "ize"}, {"ation", "ate"}, {"ator", "ate"}, {"alism", "al"}, {"iveness", "ive"},
+	{"fulness", "ful"}, {"ousness", "ous"}, {"aliti", "al"}, {"iviti", "ive"}, {"biliti", "ble"}, {"logi", "log"},
+}
+
+// stemStep3Rules Porter 算法第 3 步的后缀替换规则
+var stemStep3Rules = [][2]string{
+	{"icate", "ic"}, {"ative", ""}, {"alize", "al"}, {"iciti", "ic"}, {"ical", "ic"}, {"ful", ""}, {"ness", ""},
+}
+
+// stemStep4Suffixes Porter 算法第 4 步去掉的后缀
+var stemStep4Suffixes = []string{
+	"al", "ance", "ence", "er", "ic", "able", "ible", "ant", "ement", "ment", "ent",
+	"ion", "ou", "ism", "ate", "iti", "ous", "ive", "ize",
+}
+
+// isConsonant 第 i 个字母是否为辅音，y 在辅音之后时视为元音
+func isConsonant(w []byte, i int) bool {
+	switch w[i] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return false
+	case 'y':
+		return i == 0 || !isConsonant(w, i-1)
+	}
+	return true
+}
+
+// measure 计算词干中 "元音+辅音" 序列的数量
+func measure(w []byte) int {
+	m, i := 0, 0
+	for i < len(w) && isConsonant(w, i) {
+		i++
+	}
+	for i < len(w) {
+		for i < len(w) && !isConsonant(w, i) {
+			i++
+		}
+		if i >= len(w) {
+			break
+		}
+		m++
+		for i < len(w) && isConsonant(w, i) {
+			i++
+		}
+	}
+	return m
+}
+
+// hasVowel 词干中是否包含元音
+func hasVowel(w []byte) bool {
+	for i := range w {
+		if !isConsonant(w, i) {
+			return true
+		}
+	}
+	return false
+}
+
+// endsDoubleConsonant 是否以两个相同的辅音结尾
+func endsDoubleConsonant(w []byte) bool {
+	n := len(w)
+	return n >= 2 && w[n-1] == w[n-2] && isConsonant(w, n-1)
+}
+
+// endsCvc 是否以 "辅音-元音-辅音" 结尾，且最后的辅音不是 w、x、y
+func endsCvc(w []byte) bool {
+	n := len(w)
+	if n < 3 || !isConsonant(w, n-1) || isConsonant(w, n-2) || !isConsonant(w, n-3) {
+		return false
+	}
+	c := w[n-1]
+	return c != 'w' && c != 'x' && c != 'y'
+}
+
+// hasSuffix 是否以 suffix 结尾
+func hasSuffix(w []byte, suffix string) bool {
+	return len(w) >= len(suffix) && string(w[len(w)-len(suffix):]) == suffix
+}
+
+// withSuffix 将结尾的 n 个字母替换为 suffix
+func withSuffix(w []byte, n int, suffix string) []byte {
+	end := len(w) - n
+	return append(w[:end:end], suffix...)
+}
+
+// replaceSuffix 匹配最长的后缀，词干的 measure 大于 minMeasure 时替换
+func replaceSuffix(w []byte, rules [][2]string, minMeasure int) []byte {
+	best := -1
+	for i, rule := range rules {
+		if hasSuffix(w, rule[0]) && (best < 0 || len(rule[0]) > len(rules[best][0])) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return w
+	}
+	n := len(rules[best][0])
+	if measure(w[:len(w)-n]) > minMeasure {
+		return withSuffix(w, n, rules[best][1])
+	}
+	return w
+}
+
+// stemStep1ab 处理复数和 -ed、-ing
+func stemStep1ab(w []byte) []byte {
+	switch {
+	case hasSuffix(w, "sses"), hasSuffix(w, "ies"):
+		w = w[:len(w)-2]
+	case hasSuffix(w, "ss"):
+	case hasSuffix(w, "s"):
+		w = w[:len(w)-1]
+	}
+
+	if hasSuffix(w, "eed") {
+		if measure(w[:len(w)-3]) > 0 {
+			w = w[:len(w)-1]
+		}
+		return w
+	}
+	n := 0
+	if hasSuffix(w, "ed") && hasVowel(w[:len(w)-2]) {
+		n = 2
+	} else if hasSuffix(w, "ing") && hasVowel(w[:len(w)-3]) {
+		n = 3
+	}
+	if n == 0 {
+		return w
+	}
+	w = w[:len(w)-n]
+	switch {
+	case hasSuffix(w, "at"), hasSuffix(w, "bl"), hasSuffix(w, "iz"):
+		return withSuffix(w, 0, "e")
+	case endsDoubleConsonant(w):
+		if c := w[len(w)-1]; c != 'l' && c != 's' && c != 'z' {
+			return w[:len(w)-1]
+		}
+	case measure(w) == 1 && endsCvc(w):
+		return withSuffix(w, 0, "e")
+	}
+	return w
+}
+
+// stemStep1c 词干包含元音时，结尾的 y 替换为 i
+func stemStep1c(w []byte) []byte {
+	if hasSuffix(w, "y") && hasVowel(w[:len(w)-1]) {
+		return withSuffix(w, 1, "i")
+	}
+	return w
+}
+
+// stemStep4 词干的 measure 大于 1 时去掉后缀，-ion 要求词干以 s 或 t 结尾
+func stemStep4(w []byte) []byte {
+	best := ""
+	for _, suffix := range stemStep4Suffixes {
+		if hasSuffix(w, suffix) && len(suffix) > len(best) {
+			best = suffix
+		}
+	}
+	if best == "" {
+		return w
+	}
+	stem := w[:len(w)-len(best)]
+	if measure(stem) <= 1 {
+		return w
+	}
+	if best == "ion" && !hasSuffix(stem, "s") && !hasSuffix(stem, "t") {
+		return w
+	}
+	return stem
+}
+
+// stemStep5 去掉结尾的 e，并将结尾的 ll 变为 l
+func stemStep5(w []byte) []byte {
+	if hasSuffix(w, "e") {
+		stem := w[:len(w)-1]
+		if m := measure(stem); m > 1 || m == 1 && !endsCvc(stem) {
+			w = stem
+		}
+	}
+	if measure(w) > 1 && endsDoubleConsonant(w) && hasSuffix(w, "l") {
+		w = w[:len(w)-1]
+	}
+	return w
+}
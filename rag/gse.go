@@ -1,13 +1,11 @@
 package rag
 
 import (
-	"strings"
-
 	"github.com/go-ego/gse"
 )
 
 // GseManager 中文分词管理器
-// 使用 GSE 库对中文文本进行分词，提升向量化的准确性
+// 使用 GSE 库对中文文本进行分词，分词结果经过 textNormalizer 过滤后用于向量化和关键词索引
 type GseManager struct {
 	seg gse.Segmenter // GSE 分词器实例
 }
@@ -20,11 +18,13 @@ func newGseManager() *GseManager {
 	return &GseManager{seg: seg}
 }
 
-// splitChineseWords 对中文文本进行分词
-// 将分词结果用空格连接，便于后续向量化处理
-// 参数 text: 待分词的中文文本
-// 返回: 分词后的文本（词语间用空格分隔）
-func (g *GseManager) splitChineseWords(text string) string {
-	cut := g.seg.Cut(text)
-	return strings.Join(cut, " ")
+// cut 对文本进行分词
+// 参数 text: 待分词的文本
+// 参数 search: 是否使用搜索引擎模式，长词会同时切分出其中的短词以提高召回率
+// 返回: 分词结果，包含空白和标点
+func (g *GseManager) cut(text string, search bool) []string {
+	if search {
+		return g.seg.CutSearch(text)
+	}
+	return g.seg.Cut(text)
 }
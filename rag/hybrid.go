@@ -0,0 +1,123 @@
+package rag
+
+import (
+	"go-ollama/rule"
+	"sort"
+)
+
+// rrfK 倒数排名融合（RRF）的平滑常数，越大排名靠后的结果影响越大
+const rrfK = 60
+
+// retrievalWeights 混合检索中向量检索和关键词检索的权重
+type retrievalWeights struct {
+	vector  float64 // 向量检索的权重，为 0 时不进行向量检索
+	keyword float64 // 关键词（BM25）检索的权重，为 0 时不进行关键词检索
+}
+
+// weightsFromRule 获取规则配置的检索权重
+// 未配置的权重默认为 1，负数视为 0，两者都为 0 时使用默认值
+func weightsFromRule(rule *rule.Rule) retrievalWeights {
+	weights := retrievalWeights{vector: 1, keyword: 1}
+	if rule == nil {
+		return weights
+	}
+	cfg := rule.Retrieval()
+	if cfg.VectorWeight != nil {
+		weights.vector = max(*cfg.VectorWeight, 0)
+	}
+	if cfg.KeywordWeight != nil {
+		weights.keyword = max(*cfg.KeywordWeight, 0)
+	}
+	if weights.vector == 0 && weights.keyword == 0 {
+		return retrievalWeights{vector: 1, keyword: 1}
+	}
+	return weights
+}
+
+// rebuildKeywords 从向量集合中的文本块原文重建关键词索引
+// 关键词索引不持久化，打开知识库时根据文档清单读取已有的文本块
+// 参数 ragCtx: RAG 上下文
+func (r *ragManager) rebuildKeywords(ragCtx *RagContext) {
+	for _, doc := range ragCtx.listDocuments() {
+		for i := 0; i < doc.Chunks; i++ {
+			if content, ok := r.chromem.getChunk(ragCtx.ragId, doc.Id, i); ok {
+				ragCtx.keywords.add(doc.Id, i, r.normalizer.keywords(content, ragCtx.options.Language))
+			}
+		}
+	}
+}
+
+// retrieve 混合检索：每个查询分别进行向量检索和 BM25 关键词检索，再通过加权的倒数排名融合（RRF）合并结果
+// 每个文本块的得分为 Σ 权重 / (rrfK + 排名)，只被一种方式或一个查询命中的文本块也可以入选
+// 参数 ragCtx: RAG 上下文
+// 参数 queries: 查询列表，多个查询的结果合并为并集
+// 参数 weights: 检索权重
+// 返回: 得分最高的 retrievalCount 个文本块（按文档 ID 和序号排序）、error
+func (r *ragManager) retrieve(ragCtx *RagContext, queries []retrievalQuery, weights retrievalWeights) ([]chunkRef, error) {
+	refs := make(map[string]chunkRef)
+	scores := make(map[string]float64)
+
+	for _, query := range queries {
+		if weights.vector > 0 {
+			// 问题和文本块使用相同的规范化流程，使两者处于相同的词语空间
+			vectorRefs, err := r.chromem.query(ragCtx.ragId, r.normalizer.embeddingText(query.text, ragCtx.options.Language), retrievalCount)
+			if err != nil {
+				return nil, err
+			}
+			// 向量检索的结果按文档排序，融合前按相似度排名
+			sort.SliceStable(vectorRefs, func(i, j int) bool {
+				return vectorRefs[i].similarity > vectorRefs[j].similarity
+			})
+			for rank, ref := range vectorRefs {
+				id := chunkId(ref.docId, ref.index)
+				refs[id] = ref
+				scores[id] += weights.vector / float64(rrfK+rank+1)
+			}
+		}
+
+		if weights.keyword > 0 && !query.vectorOnly {
+			keywords := r.normalizer.keywords(query.text, ragCtx.options.Language)
+			for rank, hit := range ragCtx.keywords.search(keywords, retrievalCount) {
+				id := chunkId(hit.docId, hit.index)
+				if _, ok := refs[id]; !ok {
+					content, ok := r.chromem.getChunk(ragCtx.ragId, hit.docId, hit.index)
+					if !ok {
+						continue
+					}
+					refs[id] = chunkRef{docId: hit.docId, index: hit.index, content: content}
+				}
+				scores[id] += weights.keyword / float64(rrfK+rank+1)
+			}
+		}
+	}
+
+	ids := make([]string, 0, len(refs))
+	for id := range refs {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if scores[ids[i]] != scores[ids[j]] {
+			return scores[ids[i]] > scores[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+	if len(ids) > retrievalCount {
+		ids = ids[:retrievalCount]
+	}
+	result := make([]chunkRef, 0, len(ids))
+	for _, id := range ids {
+		result = append(result, refs[id])
+	}
+	sortChunkRefs(result)
+	return result, nil
+}
+
+// sortChunkRefs 按文档 ID 和序号排序，便于合并相邻的文本块
+func sortChunkRefs(refs []chunkRef) {
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].docId != refs[j].docId {
+			return refs[i].docId < refs[j].docId
+		}
+		return refs[i].index < refs[j].index
+	})
+}
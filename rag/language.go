@@ -0,0 +1,56 @@
+package rag
+
+import (
+	"fmt"
+	"unicode"
+)
+
+// 文本语言
+const (
+	LanguageAuto    = "auto" // 按文本内容自动检测
+	LanguageChinese = "zh"   // 中文，使用 gse 分词
+	LanguageEnglish = "en"   // 英文，按单词切分并提取词干
+)
+
+// checkLanguage 检查语言配置，auto 和空字符串表示自动检测
+// 返回: 规范化的语言（自动检测时为空字符串）、error
+func checkLanguage(language string) (string, error) {
+	switch language {
+	case "", LanguageAuto:
+		return "", nil
+	case LanguageChinese, LanguageEnglish:
+		return language, nil
+	}
+	return "", fmt.Errorf("unknown language: %s", language)
+}
+
+// detectLanguage 检测文本的主要语言
+// 比较汉字数和英文单词数（按字母数估算），一个汉字约等于一个英文单词
+// 参数 text: 文本
+// 返回: LanguageChinese 或 LanguageEnglish，没有文字时为 LanguageChinese
+func detectLanguage(text string) string {
+	han, letters := 0, 0
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			han++
+		case r < unicode.MaxASCII && unicode.IsLetter(r):
+			letters++
+		}
+	}
+	// 英文单词平均约 5 个字母
+	if letters > 0 && han*5 < letters {
+		return LanguageEnglish
+	}
+	return LanguageChinese
+}
+
+// languageOf 获取文本的语言，配置了语言时直接使用，否则自动检测
+// 参数 language: 配置的语言，为空时自动检测
+// 参数 text: 文本
+func languageOf(language string, text string) string {
+	if language != "" {
+		return language
+	}
+	return detectLanguage(text)
+}
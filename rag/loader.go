@@ -0,0 +1,83 @@
+package rag
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Loader 文档加载器接口，将源文件解析为段落，再由分块流程组合成文本块
+type Loader interface {
+	Load(filePath string) ([]Paragraph, error)
+}
+
+// 文档加载器类型
+const (
+	LoaderText     = "text"     // 纯文本，按空行分割段落
+	LoaderMarkdown = "markdown" // Markdown，按标题划分章节
+	LoaderHtml     = "html"     // HTML，提取正文和标题
+	LoaderCsv      = "csv"      // CSV，每行一条记录，附带表头
+	LoaderJson     = "json"     // JSON 数组或 JSON Lines，每个对象一条记录
+	LoaderPdf      = "pdf"      // PDF，按页提取文本，记录页码
+	LoaderDocx     = "docx"     // Word 文档，按段落提取文本，标题样式作为章节
+)
+
+// loaderExtensions 文件扩展名到文档加载器类型的映射
+var loaderExtensions = map[string]string{
+	".md":       LoaderMarkdown,
+	".markdown": LoaderMarkdown,
+	".html":     LoaderHtml,
+	".htm":      LoaderHtml,
+	".csv":      LoaderCsv,
+	".json":     LoaderJson,
+	".jsonl":    LoaderJson,
+	".ndjson":   LoaderJson,
+	".pdf":      LoaderPdf,
+	".docx":     LoaderDocx,
+}
+
+// CollectionOptions 知识库的加载选项
+type CollectionOptions struct {
+	Loader     string       // 文档加载器类型，为空时按文件扩展名选择
+	TextFields []string     // JSON 记录中用于检索的字段，支持 a.b 形式的嵌套字段，为空时使用所有字段
+	Chunk      ChunkOptions // 分块选项
+	Language   string       // 文本语言：zh、en 或 auto，为空时按每个文本块和问题自动检测
+	Reranker   string       // 重排方式：llm、embedding 或 mmr，为空时使用 LLM 重排
+	MmrLambda  *float64     // MMR 中相关性的权重（0-1），为 nil 时使用默认值
+}
+
+// newLoader 根据知识库的加载选项和文件扩展名选择文档加载器
+// 参数 filePath: 文件路径
+// 参数 options: 知识库的加载选项
+// 返回: 文档加载器、error
+func newLoader(filePath string, options CollectionOptions) (Loader, error) {
+	name := options.Loader
+	if name == "" {
+		name = loaderExtensions[strings.ToLower(filepath.Ext(filePath))]
+	}
+	switch name {
+	case "", LoaderText:
+		return textLoader{}, nil
+	case LoaderMarkdown:
+		return markdownLoader{}, nil
+	case LoaderHtml:
+		return htmlLoader{}, nil
+	case LoaderCsv:
+		return csvLoader{}, nil
+	case LoaderJson:
+		return jsonLoader{textFields: options.TextFields}, nil
+	case LoaderPdf:
+		return pdfLoader{}, nil
+	case LoaderDocx:
+		return docxLoader{}, nil
+	}
+	return nil, fmt.Errorf("unknown loader: %s", name)
+}
+
+// textLoader 纯文本加载器，按空行分割段落
+type textLoader struct{}
+
+// Load 读取纯文本文件的段落
+func (textLoader) Load(filePath string) ([]Paragraph, error) {
+	return readParagraphs(filePath)
+}
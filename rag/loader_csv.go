@@ -0,0 +1,64 @@
+package rag
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// csvLoader CSV 加载器
+// 第一行为表头，之后每行作为一条记录，记录中每个字段附带列名，如 "姓名: 哈利"
+type csvLoader struct{}
+
+// Load 读取 CSV 文件的记录
+func (csvLoader) Load(filePath string) ([]Paragraph, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+
+	var paragraphs []Paragraph
+	for {
+		offset := reader.InputOffset()
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var lines []string
+		for i, value := range record {
+			value = strings.TrimSpace(value)
+			if value == "" {
+				continue
+			}
+			name := fmt.Sprintf("column%d", i+1)
+			if i < len(header) && strings.TrimSpace(header[i]) != "" {
+				name = strings.TrimSpace(header[i])
+			}
+			lines = append(lines, name+": "+value)
+		}
+		if len(lines) > 0 {
+			paragraphs = append(paragraphs, Paragraph{Text: strings.Join(lines, "\n"), Offset: int(offset)})
+		}
+	}
+	return paragraphs, nil
+}
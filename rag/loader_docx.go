@@ -0,0 +1,202 @@
+package rag
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// docxLoader Word 文档（DOCX）加载器
+// 读取 word/document.xml 中的段落，标题样式的段落作为章节标题
+// DOCX 没有可用的字节偏移和页码，通过段落序号定位
+type docxLoader struct{}
+
+// docxNamespace WordprocessingML 的命名空间
+const docxNamespace = "http://schemas.openxmlformats.org/wordprocessingml/2006/main"
+
+// Load 读取 DOCX 文件的段落
+func (docxLoader) Load(filePath string) ([]Paragraph, error) {
+	archive, err := zip.OpenReader(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("open docx %s: %w", filePath, err)
+	}
+	defer archive.Close()
+
+	var document *zip.File
+	styles := make(map[string]int)
+	for _, file := range archive.File {
+		switch file.Name {
+		case "word/document.xml":
+			document = file
+		case "word/styles.xml":
+			// 样式表缺失或损坏时只按样式 ID 识别标题
+			if reader, err := file.Open(); err == nil {
+				styles = docxStyles(reader)
+				reader.Close()
+			}
+		}
+	}
+	if document == nil {
+		return nil, fmt.Errorf("open docx %s: word/document.xml not found", filePath)
+	}
+	reader, err := document.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return parseDocx(reader, styles)
+}
+
+// docxStyles 读取样式表中标题样式的级别
+// 本地化的 Word 中标题样式的 ID 可能是数字（如中文版的 "1"），需要通过样式名称或大纲级别识别
+// 返回: 样式 ID 到标题级别的映射
+func docxStyles(reader io.Reader) map[string]int {
+	styles := make(map[string]int)
+	decoder := xml.NewDecoder(reader)
+	styleId := ""
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return styles
+		}
+		element, ok := token.(xml.StartElement)
+		if !ok || element.Name.Space != docxNamespace {
+			continue
+		}
+		switch element.Name.Local {
+		case "style":
+			styleId = docxAttr(element, "styleId")
+		case "name":
+			if level := docxHeadingLevel(docxAttr(element, "val")); level > 0 && styleId != "" {
+				styles[styleId] = level
+			}
+		case "outlineLvl":
+			if level := outlineLevel(docxAttr(element, "val")); level > 0 && styleId != "" {
+				if _, ok := styles[styleId]; !ok {
+					styles[styleId] = level
+				}
+			}
+		}
+	}
+}
+
+// parseDocx 解析 document.xml 中的段落
+// 参数 reader: document.xml 的内容
+// 参数 styles: 样式 ID 到标题级别的映射
+// 返回: 段落数组、error
+func parseDocx(reader io.Reader, styles map[string]int) ([]Paragraph, error) {
+	decoder := xml.NewDecoder(reader)
+	var paragraphs []Paragraph
+	var builder strings.Builder
+	headings := make([]string, maxHeadingLevel)
+	level := 0      // 当前段落的标题级别，不是标题时为 0
+	inText := false // 是否在 w:t 元素中
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse docx: %w", err)
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if t.Name.Space != docxNamespace {
+				continue
+			}
+			switch t.Name.Local {
+			case "p":
+				builder.Reset()
+				level = 0
+			case "pStyle":
+				style := docxAttr(t, "val")
+				if styleLevel, ok := styles[style]; ok {
+					level = styleLevel
+				} else {
+					level = docxHeadingLevel(style)
+				}
+			case "outlineLvl":
+				level = outlineLevel(docxAttr(t, "val"))
+			case "t":
+				inText = true
+			case "tab":
+				builder.WriteString("\t")
+			case "br", "cr":
+				builder.WriteString("\n")
+			}
+		case xml.CharData:
+			if inText {
+				builder.Write(t)
+			}
+		case xml.EndElement:
+			if t.Name.Space != docxNamespace {
+				continue
+			}
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				text := strings.TrimSpace(builder.String())
+				builder.Reset()
+				if text == "" {
+					continue
+				}
+				if level > 0 {
+					// 标题段落更新标题路径，并清空更低级别的标题
+					headings[level-1] = text
+					for i := level; i < maxHeadingLevel; i++ {
+						headings[i] = ""
+					}
+					continue
+				}
+				paragraphs = append(paragraphs, Paragraph{Text: text, Heading: headingPath(headings)})
+			}
+		}
+	}
+	return paragraphs, nil
+}
+
+// docxHeadingLevel 根据段落样式获取标题级别
+// 内置的标题样式为 Title、Heading1 到 Heading9（样式名称为 heading 1），超过最大级别的按最大级别处理
+// 返回: 标题级别，不是标题时为 0
+func docxHeadingLevel(style string) int {
+	style = strings.ToLower(strings.ReplaceAll(style, " ", ""))
+	if style == "title" {
+		return 1
+	}
+	if !strings.HasPrefix(style, "heading") {
+		return 0
+	}
+	level := 0
+	for _, c := range style[len("heading"):] {
+		if c < '0' || c > '9' {
+			return 0
+		}
+		level = level*10 + int(c-'0')
+	}
+	if level == 0 {
+		return 0
+	}
+	return min(level, maxHeadingLevel)
+}
+
+// outlineLevel 将大纲级别（从 0 开始，9 表示正文）转换为标题级别
+func outlineLevel(value string) int {
+	if len(value) != 1 || value[0] < '0' || value[0] > '8' {
+		return 0
+	}
+	return min(int(value[0]-'0')+1, maxHeadingLevel)
+}
+
+// docxAttr 获取 WordprocessingML 命名空间下的属性值
+func docxAttr(element xml.StartElement, name string) string {
+	for _, attr := range element.Attr {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
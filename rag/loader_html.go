@@ -0,0 +1,207 @@
+package rag
+
+import (
+	"html"
+	"os"
+	"strings"
+)
+
+// htmlLoader HTML 加载器
+// 提取正文文本，块级元素分割段落，h1-h6 作为章节标题，忽略脚本和样式
+type htmlLoader struct{}
+
+// htmlBlockTags 分割段落的块级元素
+var htmlBlockTags = map[string]bool{
+	"p": true, "div": true, "br": true, "li": true, "ul": true, "ol": true,
+	"table": true, "tr": true, "section": true, "article": true, "header": true,
+	"footer": true, "main": true, "aside": true, "nav": true, "blockquote": true,
+	"pre": true, "dl": true, "dt": true, "dd": true, "hr": true, "title": true,
+	"figure": true, "figcaption": true, "form": true,
+}
+
+// htmlSkipTags 忽略内容的元素
+var htmlSkipTags = map[string]bool{
+	"script": true, "style": true, "noscript": true, "template": true, "svg": true,
+}
+
+// htmlParser HTML 正文提取的状态
+type htmlParser struct {
+	paragraphs []Paragraph
+	builder    strings.Builder
+	paraOffset int                     // 当前段落的字节偏移
+	headings   [maxHeadingLevel]string // 各级标题
+	heading    int                     // 正在读取的标题级别，不在标题中时为 0
+	pre        int                     // pre 元素的嵌套层数，pre 内保留空白
+}
+
+// Load 读取 HTML 文件的段落
+func (htmlLoader) Load(filePath string) ([]Paragraph, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return parseHtml(string(data)), nil
+}
+
+// parseHtml 从 HTML 文本中提取段落
+func parseHtml(text string) []Paragraph {
+	p := &htmlParser{}
+	for pos := 0; pos < len(text); {
+		start := strings.IndexByte(text[pos:], '<')
+		if start < 0 {
+			p.addText(text[pos:], pos)
+			break
+		}
+		p.addText(text[pos:pos+start], pos)
+		pos += start
+
+		switch {
+		case !isTagStart(text[pos+1:]):
+			// 不是标签的 < 作为普通文本，如 a < b
+			p.addText("<", pos)
+			pos++
+			continue
+		case strings.HasPrefix(text[pos:], "<!--"):
+			pos = skipPast(text, pos, "-->")
+			continue
+		case strings.HasPrefix(text[pos:], "<!"), strings.HasPrefix(text[pos:], "<?"):
+			pos = skipPast(text, pos, ">")
+			continue
+		}
+
+		end := skipPast(text, pos, ">")
+		name, closing := tagName(text[pos:end])
+		pos = end
+		if name == "" {
+			continue
+		}
+		if htmlSkipTags[name] && !closing {
+			// 跳过脚本等元素的全部内容
+			closeTag := strings.Index(strings.ToLower(text[pos:]), "</"+name)
+			if closeTag < 0 {
+				break
+			}
+			pos = skipPast(text, pos+closeTag, ">")
+			continue
+		}
+		p.handleTag(name, closing)
+	}
+	// 未闭合的标题在文件结束时结束
+	p.endHeading()
+	p.flush()
+	return p.paragraphs
+}
+
+// isTagStart < 之后的文本是否构成标签：字母、/字母、! 或 ?
+func isTagStart(text string) bool {
+	text = strings.TrimPrefix(text, "/")
+	if text == "" {
+		return false
+	}
+	c := text[0]
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '!' || c == '?'
+}
+
+// handleTag 处理开始或结束标签
+func (p *htmlParser) handleTag(name string, closing bool) {
+	if len(name) == 2 && name[0] == 'h' && name[1] >= '1' && name[1] <= '6' {
+		// 新标题开始时，未闭合的上一个标题随之结束
+		p.endHeading()
+		p.flush()
+		if !closing {
+			p.heading = int(name[1] - '0')
+		}
+		return
+	}
+	if name == "pre" {
+		if closing {
+			p.pre = max(p.pre-1, 0)
+		} else {
+			p.pre++
+		}
+	}
+	if htmlBlockTags[name] {
+		// 标题内不应出现除 br 之外的块级元素，视为标题未闭合，在此结束标题
+		if name != "br" {
+			p.endHeading()
+		}
+		p.flush()
+	}
+}
+
+// endHeading 结束正在读取的标题，更新标题路径，标题本身不作为段落
+func (p *htmlParser) endHeading() {
+	if p.heading == 0 {
+		return
+	}
+	p.headings[p.heading-1] = strings.TrimSpace(p.builder.String())
+	for i := p.heading; i < maxHeadingLevel; i++ {
+		p.headings[i] = ""
+	}
+	p.builder.Reset()
+	p.heading = 0
+}
+
+// addText 添加文本内容，pre 之外的连续空白合并为一个空格
+// 参数 text: 原始文本
+// 参数 offset: 文本在文件中的字节偏移
+func (p *htmlParser) addText(text string, offset int) {
+	if strings.TrimSpace(text) == "" {
+		if p.builder.Len() > 0 && p.pre == 0 {
+			p.builder.WriteString(" ")
+		}
+		return
+	}
+	if p.builder.Len() == 0 && p.heading == 0 {
+		p.paraOffset = offset + len(text) - len(strings.TrimLeft(text, " \t\r\n"))
+	}
+	text = html.UnescapeString(text)
+	if p.pre == 0 {
+		leading := strings.TrimLeft(text, " \t\r\n") != text
+		trailing := strings.TrimRight(text, " \t\r\n") != text
+		text = strings.Join(strings.Fields(text), " ")
+		if leading && p.builder.Len() > 0 {
+			text = " " + text
+		}
+		if trailing {
+			text += " "
+		}
+	}
+	p.builder.WriteString(text)
+}
+
+// flush 结束当前段落
+func (p *htmlParser) flush() {
+	if p.heading > 0 {
+		return
+	}
+	text := strings.TrimSpace(p.builder.String())
+	p.builder.Reset()
+	if text == "" {
+		return
+	}
+	p.paragraphs = append(p.paragraphs, Paragraph{Text: text, Offset: p.paraOffset, Heading: headingPath(p.headings[:])})
+}
+
+// tagName 解析标签名称
+// 参数 tag: 完整的标签，如 <div class="a">、</p>
+// 返回: 小写的标签名称、是否为结束标签
+func tagName(tag string) (string, bool) {
+	tag = strings.TrimPrefix(tag, "<")
+	closing := strings.HasPrefix(tag, "/")
+	tag = strings.TrimPrefix(tag, "/")
+	end := 0
+	for end < len(tag) && (tag[end] >= 'a' && tag[end] <= 'z' || tag[end] >= 'A' && tag[end] <= 'Z' || tag[end] >= '0' && tag[end] <= '9') {
+		end++
+	}
+	return strings.ToLower(tag[:end]), closing
+}
+
+// skipPast 获取 pos 之后第一个 sep 结束的位置，找不到时返回文本末尾
+func skipPast(text string, pos int, sep string) int {
+	index := strings.Index(text[pos:], sep)
+	if index < 0 {
+		return len(text)
+	}
+	return pos + index + len(sep)
+}
@@ -0,0 +1,140 @@
+package rag
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// jsonLoader JSON 加载器
+// 支持 JSON 数组、单个 JSON 对象和 JSON Lines，每个元素作为一条记录
+type jsonLoader struct {
+	textFields []string // 用于检索的字段，支持 a.b 形式的嵌套字段，为空时使用所有字段
+}
+
+// Load 读取 JSON 文件的记录
+func (l jsonLoader) Load(filePath string) ([]Paragraph, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var paragraphs []Paragraph
+	add := func(offset int, value any) {
+		if text := l.recordText(value); text != "" {
+			paragraphs = append(paragraphs, Paragraph{Text: text, Offset: offset})
+		}
+	}
+
+	// 跳过 UTF-8 BOM，偏移仍然相对于文件开头
+	start := len(data) - len(bytes.TrimPrefix(data, []byte("\ufeff")))
+	decoder := json.NewDecoder(bytes.NewReader(data[start:]))
+	decoder.UseNumber()
+	if trimmed := bytes.TrimLeft(data[start:], " \t\r\n"); len(trimmed) > 0 && trimmed[0] == '[' {
+		// JSON 数组，逐个解析元素
+		if _, err := decoder.Token(); err != nil {
+			return nil, err
+		}
+		for decoder.More() {
+			offset := skipJsonSpace(data, start+int(decoder.InputOffset()))
+			var value any
+			if err := decoder.Decode(&value); err != nil {
+				return nil, fmt.Errorf("decode %s: %w", filePath, err)
+			}
+			add(offset, value)
+		}
+		return paragraphs, nil
+	}
+
+	// 单个 JSON 对象或 JSON Lines
+	for {
+		offset := skipJsonSpace(data, start+int(decoder.InputOffset()))
+		var value any
+		err := decoder.Decode(&value)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("decode %s: %w", filePath, err)
+		}
+		add(offset, value)
+	}
+	return paragraphs, nil
+}
+
+// recordText 将一条记录转换为检索文本
+// 配置了一个字段时直接使用字段值，多个字段或使用所有字段时每行为 "字段: 值"
+func (l jsonLoader) recordText(value any) string {
+	object, ok := value.(map[string]any)
+	if !ok {
+		return jsonText(value)
+	}
+	fields := l.textFields
+	if len(fields) == 0 {
+		for key := range object {
+			fields = append(fields, key)
+		}
+		sort.Strings(fields)
+	} else if len(fields) == 1 {
+		return jsonText(lookupField(object, fields[0]))
+	}
+	var lines []string
+	for _, field := range fields {
+		if text := jsonText(lookupField(object, field)); text != "" {
+			lines = append(lines, field+": "+text)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// lookupField 获取对象中的字段，支持 a.b 形式的嵌套字段
+func lookupField(object map[string]any, field string) any {
+	if value, ok := object[field]; ok {
+		return value
+	}
+	var value any = object
+	for _, key := range strings.Split(field, ".") {
+		current, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value = current[key]
+	}
+	return value
+}
+
+// jsonText 将 JSON 值转换为文本，字符串数组用逗号连接，对象保留 JSON 格式
+func jsonText(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.TrimSpace(v)
+	case json.Number:
+		return v.String()
+	case bool:
+		return fmt.Sprint(v)
+	case []any:
+		var items []string
+		for _, item := range v {
+			if text := jsonText(item); text != "" {
+				items = append(items, text)
+			}
+		}
+		return strings.Join(items, ", ")
+	}
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+// skipJsonSpace 跳过 JSON 值之间的空白和逗号，获取下一个值的偏移
+func skipJsonSpace(data []byte, offset int) int {
+	for offset < len(data) && strings.IndexByte(" \t\r\n,", data[offset]) >= 0 {
+		offset++
+	}
+	return offset
+}
@@ -0,0 +1,100 @@
+package rag
+
+import (
+	"os"
+	"strings"
+)
+
+// markdownLoader Markdown 加载器
+// 按标题划分章节，段落记录所属章节的标题路径；代码块内的空行不分割段落
+type markdownLoader struct{}
+
+// maxHeadingLevel Markdown 标题的最大级别
+const maxHeadingLevel = 6
+
+// Load 读取 Markdown 文件的段落
+func (markdownLoader) Load(filePath string) ([]Paragraph, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var paragraphs []Paragraph
+	var builder strings.Builder
+	var paraOffset int
+	headings := make([]string, maxHeadingLevel)
+	fenced := false
+
+	flush := func() {
+		if builder.Len() > 0 {
+			paragraphs = append(paragraphs, Paragraph{Text: builder.String(), Offset: paraOffset, Heading: headingPath(headings)})
+			builder.Reset()
+		}
+	}
+
+	text := string(data)
+	for pos := 0; pos < len(text); {
+		end := strings.IndexByte(text[pos:], '\n')
+		if end < 0 {
+			end = len(text)
+		} else {
+			end += pos + 1
+		}
+		lineOffset := pos
+		line := strings.TrimRight(text[pos:end], "\r\n")
+		pos = end
+
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fenced = !fenced
+		} else if !fenced {
+			if level, title := parseHeading(trimmed); level > 0 {
+				// 新的标题结束当前段落，并清空更低级别的标题
+				flush()
+				headings[level-1] = title
+				for i := level; i < maxHeadingLevel; i++ {
+					headings[i] = ""
+				}
+				continue
+			}
+			if trimmed == "" {
+				flush()
+				continue
+			}
+		}
+
+		if builder.Len() > 0 {
+			builder.WriteString("\n")
+		} else {
+			paraOffset = lineOffset
+		}
+		builder.WriteString(line)
+	}
+	flush()
+	return paragraphs, nil
+}
+
+// parseHeading 解析 ATX 风格的 Markdown 标题，如 "## 安装"
+// 返回: 标题级别（不是标题时为 0）、标题文本
+func parseHeading(line string) (int, string) {
+	level := 0
+	for level < len(line) && line[level] == '#' {
+		level++
+	}
+	if level == 0 || level > maxHeadingLevel || (level < len(line) && line[level] != ' ' && line[level] != '\t') {
+		return 0, ""
+	}
+	title := strings.TrimSpace(strings.TrimRight(strings.TrimSpace(line[level:]), "#"))
+	return level, title
+}
+
+// headingPath 将各级标题连接成标题路径
+func headingPath(headings []string) string {
+	var path []string
+	for _, heading := range headings {
+		if heading != "" {
+			path = append(path, heading)
+		}
+	}
+	return strings.Join(path, " > ")
+}
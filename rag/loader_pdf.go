@@ -0,0 +1,593 @@
+package rag
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// pdfLoader PDF 加载器
+// 按页提取文本层，根据文本行之间的距离划分段落，段落记录所在页码
+// 扫描版 PDF（只有图片）没有可提取的文本
+type pdfLoader struct{}
+
+// Load 读取 PDF 文件的段落
+func (pdfLoader) Load(filePath string) ([]Paragraph, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := parsePdf(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse pdf %s: %w", filePath, err)
+	}
+	pages, err := doc.pages()
+	if err != nil {
+		return nil, fmt.Errorf("parse pdf %s: %w", filePath, err)
+	}
+
+	extractor := &pdfTextExtractor{doc: doc, fonts: make(map[pdfRef]*pdfFont)}
+	var paragraphs []Paragraph
+	var pageErr error
+	for i, page := range pages {
+		// 单页解析失败时跳过该页，保留其他页的内容
+		text, err := extractor.pageText(page)
+		if err != nil {
+			pageErr = fmt.Errorf("parse pdf %s page %d: %w", filePath, i+1, err)
+			continue
+		}
+		for _, para := range strings.Split(text, "\n\n") {
+			if para = strings.TrimSpace(para); para != "" {
+				paragraphs = append(paragraphs, Paragraph{Text: para, Page: i + 1})
+			}
+		}
+	}
+	if len(paragraphs) == 0 && pageErr != nil {
+		return nil, pageErr
+	}
+	return paragraphs, nil
+}
+
+// pdfTextExtractor 解释页面内容流并提取文本
+type pdfTextExtractor struct {
+	doc   *pdfDocument
+	fonts map[pdfRef]*pdfFont // 字体缓存，多个页面通常共享字体对象
+}
+
+// pdfTextState 内容流中与文本位置相关的状态
+type pdfTextState struct {
+	font     *pdfFont
+	fontSize float64
+	leading  float64    // 行距（TL）
+	matrix   [6]float64 // 文本矩阵（Tm）
+	line     [6]float64 // 文本行矩阵
+}
+
+// pdfTextWriter 根据文本位置组织输出，换行和分段由纵向距离决定
+type pdfTextWriter struct {
+	builder strings.Builder
+	lastY   float64 // 上一段文本的纵坐标
+	hasText bool    // 是否已经输出过文本
+	moved   bool    // 上一段文本之后是否移动过文本位置
+}
+
+// identityMatrix 单位矩阵
+var identityMatrix = [6]float64{1, 0, 0, 1, 0, 0}
+
+// pageText 提取一页的文本，段落之间以空行分隔
+func (e *pdfTextExtractor) pageText(page pdfPage) (string, error) {
+	var content [][]byte
+	for _, object := range e.doc.array(page.dict["Contents"]) {
+		stream, ok := e.doc.resolve(object).(*pdfStream)
+		if !ok {
+			continue
+		}
+		data, err := e.doc.decodeStream(stream)
+		if err != nil {
+			return "", err
+		}
+		content = append(content, data)
+	}
+	writer := &pdfTextWriter{}
+	err := e.run(bytes.Join(content, []byte("\n")), page.resources, writer, make(map[pdfRef]bool), 0)
+	return writer.builder.String(), err
+}
+
+// run 解释内容流
+// 参数 content: 解码后的内容流
+// 参数 resources: 当前的资源字典
+// 参数 writer: 文本输出
+// 参数 active: 正在解释的表单对象，防止表单循环引用
+// 参数 depth: 表单嵌套深度
+func (e *pdfTextExtractor) run(content []byte, resources pdfDict, writer *pdfTextWriter, active map[pdfRef]bool, depth int) error {
+	if depth > maxPdfDepth {
+		return errors.New("pdf form nested too deep")
+	}
+	state := pdfTextState{matrix: identityMatrix, line: identityMatrix}
+	parser := &pdfParser{data: content}
+	var operands []any
+	for {
+		object, err := parser.parseObject(0)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		keyword, ok := object.(pdfKeyword)
+		if !ok {
+			operands = append(operands, object)
+			continue
+		}
+		switch keyword {
+		case "BT":
+			state.matrix, state.line = identityMatrix, identityMatrix
+			writer.moved = true
+		case "Tf":
+			if len(operands) >= 2 {
+				name, _ := operands[len(operands)-2].(pdfName)
+				state.font = e.font(resources, name)
+				state.fontSize = pdfFloat(operands[len(operands)-1])
+			}
+		case "TL":
+			if len(operands) >= 1 {
+				state.leading = pdfFloat(operands[len(operands)-1])
+			}
+		case "Td", "TD":
+			if len(operands) >= 2 {
+				tx, ty := pdfFloat(operands[len(operands)-2]), pdfFloat(operands[len(operands)-1])
+				if keyword == "TD" {
+					state.leading = -ty
+				}
+				state.moveLine(tx, ty)
+				writer.moved = true
+			}
+		case "Tm":
+			if len(operands) >= 6 {
+				for i := range state.line {
+					state.line[i] = pdfFloat(operands[len(operands)-6+i])
+				}
+				state.matrix = state.line
+				writer.moved = true
+			}
+		case "T*":
+			state.moveLine(0, -state.leading)
+			writer.moved = true
+		case "Tj", "'", "\"":
+			if keyword != "Tj" {
+				state.moveLine(0, -state.leading)
+				writer.moved = true
+			}
+			if len(operands) >= 1 {
+				if text, ok := operands[len(operands)-1].(pdfString); ok {
+					writer.write(&state, state.decode(text))
+				}
+			}
+		case "TJ":
+			if len(operands) >= 1 {
+				array, _ := operands[len(operands)-1].(pdfArray)
+				var builder strings.Builder
+				for _, item := range array {
+					switch v := item.(type) {
+					case pdfString:
+						builder.WriteString(state.decode(v))
+					case int, float64:
+						// 较大的字距调整通常表示单词之间的空格
+						if pdfFloat(v) < -250 && endsWithAlnum(builder.String()) {
+							builder.WriteString(" ")
+						}
+					}
+				}
+				writer.write(&state, builder.String())
+			}
+		case "Do":
+			if len(operands) >= 1 {
+				name, _ := operands[len(operands)-1].(pdfName)
+				if err := e.runForm(resources, name, writer, active, depth); err != nil {
+					return err
+				}
+			}
+		case "ID":
+			// 跳过内联图片的数据
+			parser.pos = skipInlineImage(content, parser.pos)
+		}
+		operands = operands[:0]
+	}
+}
+
+// runForm 解释表单对象（Form XObject）中的内容
+func (e *pdfTextExtractor) runForm(resources pdfDict, name pdfName, writer *pdfTextWriter, active map[pdfRef]bool, depth int) error {
+	object := e.doc.dict(resources["XObject"])[name]
+	ref, isRef := object.(pdfRef)
+	if isRef && active[ref] {
+		return nil
+	}
+	stream, ok := e.doc.resolve(object).(*pdfStream)
+	if !ok || stream.dict["Subtype"] != pdfName("Form") {
+		return nil
+	}
+	data, err := e.doc.decodeStream(stream)
+	if err != nil {
+		return err
+	}
+	if r := e.doc.dict(stream.dict["Resources"]); r != nil {
+		resources = r
+	}
+	if isRef {
+		active[ref] = true
+		defer delete(active, ref)
+	}
+	return e.run(data, resources, writer, active, depth+1)
+}
+
+// skipInlineImage 跳过内联图片的数据（ID 之后到 EI 之前）
+// 返回: EI 之后的位置
+func skipInlineImage(content []byte, pos int) int {
+	for i := pos + 1; i+1 < len(content); i++ {
+		if content[i] == 'E' && content[i+1] == 'I' && isPdfSpace(content[i-1]) &&
+			(i+2 == len(content) || isPdfSpace(content[i+2]) || isPdfDelimiter(content[i+2])) {
+			return i + 2
+		}
+	}
+	return len(content)
+}
+
+// moveLine 移动到下一行的开头（Td）
+func (s *pdfTextState) moveLine(tx float64, ty float64) {
+	s.line[4] += tx*s.line[0] + ty*s.line[2]
+	s.line[5] += tx*s.line[1] + ty*s.line[3]
+	s.matrix = s.line
+}
+
+// size 当前文本的实际字号，包含文本矩阵的缩放
+func (s *pdfTextState) size() float64 {
+	size := math.Abs(s.fontSize) * math.Hypot(s.matrix[2], s.matrix[3])
+	if size == 0 {
+		return 10
+	}
+	return size
+}
+
+// decode 使用当前字体将字符串转换为文本
+func (s *pdfTextState) decode(text pdfString) string {
+	if s.font == nil {
+		return decodeLatin(text, nil)
+	}
+	return s.font.decode(text)
+}
+
+// write 输出一段文本
+// 纵向距离超过半个字号时换行，超过两倍行距或者向上移动（如分栏）时分段
+func (w *pdfTextWriter) write(state *pdfTextState, text string) {
+	if strings.TrimSpace(text) == "" {
+		if text != "" && w.hasText {
+			w.builder.WriteString(" ")
+		}
+		return
+	}
+	y, size := state.matrix[5], state.size()
+	if w.hasText {
+		dy := w.lastY - y
+		switch {
+		case dy > 1.9*size || dy < -0.5*size:
+			w.builder.WriteString("\n\n")
+		case dy > 0.5*size:
+			w.builder.WriteString("\n")
+		case w.moved && endsWithAlnum(w.builder.String()):
+			// 同一行中重新定位的文本，拉丁文字之间补充空格
+			w.builder.WriteString(" ")
+		}
+	}
+	w.builder.WriteString(text)
+	w.lastY = y
+	w.hasText = true
+	w.moved = false
+}
+
+// endsWithAlnum 文本是否以 ASCII 字母或数字结尾
+func endsWithAlnum(text string) bool {
+	r, _ := utf8.DecodeLastRuneInString(text)
+	return r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+// pdfFont 字体的字符编码，用于将字符串中的字符码转换为文本
+type pdfFont struct {
+	cmap        *pdfCMap        // ToUnicode 映射
+	composite   bool            // 是否为复合字体（Type0），字符码通常为两个字节
+	utf16       bool            // 复合字体使用 UCS-2/UTF-16 编码时直接按 UTF-16BE 解码
+	differences map[byte]string // 简单字体 /Differences 中的字形名称
+}
+
+// font 获取资源字典中的字体
+func (e *pdfTextExtractor) font(resources pdfDict, name pdfName) *pdfFont {
+	object := e.doc.dict(resources["Font"])[name]
+	ref, isRef := object.(pdfRef)
+	if isRef {
+		if font, ok := e.fonts[ref]; ok {
+			return font
+		}
+	}
+	font := e.loadFont(e.doc.dict(object))
+	if isRef {
+		e.fonts[ref] = font
+	}
+	return font
+}
+
+// loadFont 解析字体字典中的编码信息
+func (e *pdfTextExtractor) loadFont(dict pdfDict) *pdfFont {
+	font := &pdfFont{composite: dict["Subtype"] == pdfName("Type0")}
+	if stream, ok := e.doc.resolve(dict["ToUnicode"]).(*pdfStream); ok {
+		if data, err := e.doc.decodeStream(stream); err == nil {
+			font.cmap = parseCMap(data)
+		}
+	}
+	switch encoding := e.doc.resolve(dict["Encoding"]).(type) {
+	case pdfName:
+		font.utf16 = font.composite && (strings.Contains(string(encoding), "UCS2") || strings.Contains(string(encoding), "UTF16"))
+	case pdfDict:
+		font.differences = make(map[byte]string)
+		code := 0
+		for _, item := range e.doc.array(encoding["Differences"]) {
+			switch v := e.doc.resolve(item).(type) {
+			case int:
+				code = v
+			case pdfName:
+				if code >= 0 && code < 256 {
+					font.differences[byte(code)] = string(v)
+				}
+				code++
+			}
+		}
+	}
+	return font
+}
+
+// decode 将字符串中的字符码转换为文本
+// 优先使用 ToUnicode 映射；复合字体没有映射时无法得到文本；简单字体按 WinAnsi 编码处理
+func (f *pdfFont) decode(text pdfString) string {
+	if f.cmap != nil {
+		return f.cmap.decode(text, f.composite)
+	}
+	if f.composite {
+		if f.utf16 {
+			return decodeUtf16(text)
+		}
+		return ""
+	}
+	return decodeLatin(text, f.differences)
+}
+
+// pdfCMap ToUnicode 映射
+type pdfCMap struct {
+	mapping map[string]string // 字符码到文本的映射
+	lengths []int             // 字符码的字节长度（codespacerange），从短到长
+}
+
+// maxCMapRange bfrange 展开的最大字符数，防止恶意文件占用过多内存
+const maxCMapRange = 1 << 16
+
+// parseCMap 解析 ToUnicode CMap 中的 codespacerange、bfchar 和 bfrange
+func parseCMap(data []byte) *pdfCMap {
+	cmap := &pdfCMap{mapping: make(map[string]string)}
+	parser := &pdfParser{data: data}
+	var operands []any
+	for {
+		object, err := parser.parseObject(0)
+		if err != nil {
+			break
+		}
+		keyword, ok := object.(pdfKeyword)
+		if !ok {
+			operands = append(operands, object)
+			continue
+		}
+		switch keyword {
+		case "endcodespacerange":
+			for i := 0; i+1 < len(operands); i += 2 {
+				if low, ok := operands[i].(pdfString); ok && len(low) > 0 {
+					cmap.addLength(len(low))
+				}
+			}
+		case "endbfchar":
+			for i := 0; i+1 < len(operands); i += 2 {
+				code, ok1 := operands[i].(pdfString)
+				dst, ok2 := operands[i+1].(pdfString)
+				if ok1 && ok2 {
+					cmap.mapping[string(code)] = decodeUtf16(dst)
+				}
+			}
+		case "endbfrange":
+			for i := 0; i+2 < len(operands); i += 3 {
+				low, ok1 := operands[i].(pdfString)
+				high, ok2 := operands[i+1].(pdfString)
+				if ok1 && ok2 && len(low) == len(high) && len(low) > 0 {
+					cmap.addRange(low, high, operands[i+2])
+				}
+			}
+		}
+		operands = operands[:0]
+	}
+	if len(cmap.lengths) == 0 {
+		for code := range cmap.mapping {
+			cmap.addLength(len(code))
+		}
+	}
+	return cmap
+}
+
+// addLength 记录字符码的字节长度
+func (c *pdfCMap) addLength(length int) {
+	for i, l := range c.lengths {
+		if l == length {
+			return
+		}
+		if l > length {
+			c.lengths = append(c.lengths[:i], append([]int{length}, c.lengths[i:]...)...)
+			return
+		}
+	}
+	c.lengths = append(c.lengths, length)
+}
+
+// addRange 添加 bfrange 映射，目标为字符串时依次递增最后一个字符，为数组时逐个对应
+func (c *pdfCMap) addRange(low pdfString, high pdfString, dst any) {
+	start, end := codeValue(low), codeValue(high)
+	if end < start || end-start >= maxCMapRange {
+		return
+	}
+	for code := start; code <= end; code++ {
+		key := make([]byte, len(low))
+		for i, v := len(key)-1, code; i >= 0; i, v = i-1, v>>8 {
+			key[i] = byte(v)
+		}
+		switch d := dst.(type) {
+		case pdfString:
+			units := utf16Units(d)
+			if len(units) == 0 {
+				return
+			}
+			units[len(units)-1] += uint16(code - start)
+			c.mapping[string(key)] = string(utf16.Decode(units))
+		case pdfArray:
+			if index := code - start; index < len(d) {
+				if s, ok := d[index].(pdfString); ok {
+					c.mapping[string(key)] = decodeUtf16(s)
+				}
+			}
+		}
+	}
+}
+
+// decode 按字符码长度从短到长匹配映射，没有映射的字符码跳过
+func (c *pdfCMap) decode(text pdfString, composite bool) string {
+	fallback := 1
+	if composite {
+		fallback = 2
+	}
+	if len(c.lengths) == 1 {
+		fallback = c.lengths[0]
+	}
+	var builder strings.Builder
+	for pos := 0; pos < len(text); {
+		step := fallback
+		for _, length := range c.lengths {
+			if pos+length > len(text) {
+				break
+			}
+			if s, ok := c.mapping[string(text[pos:pos+length])]; ok {
+				builder.WriteString(s)
+				step = length
+				break
+			}
+		}
+		pos += step
+	}
+	return cleanPdfText(builder.String())
+}
+
+// codeValue 将字符码转换为整数
+func codeValue(code pdfString) int {
+	value := 0
+	for _, b := range code {
+		value = value<<8 | int(b)
+	}
+	return value
+}
+
+// utf16Units 将 UTF-16BE 字节转换为编码单元
+func utf16Units(data []byte) []uint16 {
+	units := make([]uint16, 0, len(data)/2)
+	for i := 0; i+1 < len(data); i += 2 {
+		units = append(units, uint16(data[i])<<8|uint16(data[i+1]))
+	}
+	return units
+}
+
+// decodeUtf16 解码 UTF-16BE 文本，去掉字节序标记
+func decodeUtf16(data []byte) string {
+	data = bytes.TrimPrefix(data, []byte{0xfe, 0xff})
+	if len(data)%2 == 1 {
+		return decodeLatin(data, nil)
+	}
+	return cleanPdfText(string(utf16.Decode(utf16Units(data))))
+}
+
+// winAnsiHigh WinAnsi 编码中 0x80 到 0x9f 的字符，0 表示未定义
+var winAnsiHigh = [32]rune{
+	'€', 0, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0, 'Ž', 0,
+	0, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0, 'ž', 'Ÿ',
+}
+
+// decodeLatin 按 WinAnsi 编码解码简单字体的字符串
+// 参数 differences: /Differences 中的字形名称，优先于默认编码
+func decodeLatin(text []byte, differences map[byte]string) string {
+	var builder strings.Builder
+	for _, b := range text {
+		if name, ok := differences[b]; ok {
+			if r := glyphRune(name); r != 0 {
+				builder.WriteRune(r)
+				continue
+			}
+		}
+		switch {
+		case b >= 0x80 && b < 0xa0:
+			if r := winAnsiHigh[b-0x80]; r != 0 {
+				builder.WriteRune(r)
+			}
+		default:
+			builder.WriteRune(rune(b))
+		}
+	}
+	return cleanPdfText(builder.String())
+}
+
+// glyphNames 常用字形名称对应的字符
+var glyphNames = map[string]rune{
+	"space": ' ', "exclam": '!', "quotedbl": '"', "numbersign": '#', "dollar": '$', "percent": '%',
+	"ampersand": '&', "quotesingle": '\'', "quoteright": '’', "quoteleft": '‘', "parenleft": '(', "parenright": ')',
+	"asterisk": '*', "plus": '+', "comma": ',', "hyphen": '-', "period": '.', "slash": '/',
+	"colon": ':', "semicolon": ';', "less": '<', "equal": '=', "greater": '>', "question": '?',
+	"at": '@', "bracketleft": '[', "backslash": '\\', "bracketright": ']', "underscore": '_',
+	"braceleft": '{', "bar": '|', "braceright": '}', "endash": '–', "emdash": '—', "bullet": '•',
+	"quotedblleft": '“', "quotedblright": '”', "ellipsis": '…', "fi": 'ﬁ', "fl": 'ﬂ',
+	"zero": '0', "one": '1', "two": '2', "three": '3', "four": '4',
+	"five": '5', "six": '6', "seven": '7', "eight": '8', "nine": '9',
+}
+
+// glyphRune 将字形名称转换为字符，支持 uniXXXX、uXXXX 和单个字母的名称
+// 返回: 字符，无法识别时为 0
+func glyphRune(name string) rune {
+	if r, ok := glyphNames[name]; ok {
+		return r
+	}
+	if len(name) == 1 && (name[0] >= 'a' && name[0] <= 'z' || name[0] >= 'A' && name[0] <= 'Z') {
+		return rune(name[0])
+	}
+	for _, prefix := range []string{"uni", "u"} {
+		if hexText, ok := strings.CutPrefix(name, prefix); ok && len(hexText) >= 4 && len(hexText) <= 6 {
+			if value, err := strconv.ParseUint(hexText, 16, 32); err == nil && utf8.ValidRune(rune(value)) {
+				return rune(value)
+			}
+		}
+	}
+	return 0
+}
+
+// cleanPdfText 去掉控制字符和无法识别的字符
+func cleanPdfText(text string) string {
+	return strings.Map(func(r rune) rune {
+		if r == utf8.RuneError || r < ' ' && r != '\n' && r != '\t' {
+			return -1
+		}
+		return r
+	}, text)
+}
@@ -0,0 +1,177 @@
+package rag
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoaders(t *testing.T) {
+	dir := t.TempDir()
+	chunker, _ := newChunker(ChunkOptions{})
+	load := func(name string, content string, options CollectionOptions) []Paragraph {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, []byte(content), 0644)
+		loader, err := newLoader(path, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		paragraphs, err := loader.Load(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return paragraphs
+	}
+
+	{ // case markdown
+		content := "# 安装\n\n说明\n\n## Linux\n\n```sh\nmake\n\nmake install\n```\n\n# 使用\n运行\n"
+		paragraphs := load("a.md", content, CollectionOptions{})
+		if len(paragraphs) != 3 {
+			t.Fatalf("expected 3 paragraphs, got %+v", paragraphs)
+		}
+		if paragraphs[1].Heading != "安装 > Linux" || !strings.Contains(paragraphs[1].Text, "make install") {
+			t.Fatalf("unexpected code block: %+v", paragraphs[1])
+		}
+		if paragraphs[2].Heading != "使用" || content[paragraphs[2].Offset:paragraphs[2].Offset+len("运行")] != "运行" {
+			t.Fatalf("unexpected section: %+v", paragraphs[2])
+		}
+		chunks := chunker.Chunk(paragraphs)
+		if len(chunks) != 3 || !strings.HasPrefix(chunks[2].text, "使用\n") {
+			t.Fatalf("expected chunks split by section, got %+v", chunks)
+		}
+	}
+	{ // case html
+		content := `<html><head><title>T</title><style>p{}</style></head><body>
+<h1>霍格沃茨</h1><p>魔法&amp;学校</p><script>alert(1)</script><div>第二段<br>第三段</div></body></html>`
+		paragraphs := load("a.html", content, CollectionOptions{})
+		if len(paragraphs) != 4 || paragraphs[1].Text != "魔法&学校" || paragraphs[1].Heading != "霍格沃茨" || paragraphs[3].Text != "第三段" {
+			t.Fatalf("unexpected html paragraphs: %+v", paragraphs)
+		}
+		if !strings.HasPrefix(content[paragraphs[1].Offset:], "魔法") {
+			t.Fatalf("unexpected html offset: %d", paragraphs[1].Offset)
+		}
+	}
+	{ // case html stray < and unclosed heading
+		content := `<p>1 < 2 且 3 <= 4</p><h2>章节<p>正文</p><h3>小节</h3><p>内容</p>`
+		paragraphs := load("b.html", content, CollectionOptions{})
+		if len(paragraphs) != 3 || paragraphs[0].Text != "1 < 2 且 3 <= 4" {
+			t.Fatalf("unexpected html paragraphs: %+v", paragraphs)
+		}
+		if paragraphs[1].Text != "正文" || paragraphs[1].Heading != "章节" || paragraphs[2].Text != "内容" || paragraphs[2].Heading != "章节 > 小节" {
+			t.Fatalf("unexpected html headings: %+v", paragraphs)
+		}
+		if paragraphs := load("c.html", `<p>正文</p><h1>标题`, CollectionOptions{}); len(paragraphs) != 1 || paragraphs[0].Text != "正文" {
+			t.Fatalf("unexpected html paragraphs: %+v", paragraphs)
+		}
+	}
+	{ // case csv
+		content := "姓名,学院\n哈利,格兰芬多\n\"德拉科\",斯莱特林\n"
+		paragraphs := load("a.csv", content, CollectionOptions{})
+		if len(paragraphs) != 2 || paragraphs[1].Text != "姓名: 德拉科\n学院: 斯莱特林" || !strings.HasPrefix(content[paragraphs[1].Offset:], "\"德拉科") {
+			t.Fatalf("unexpected csv paragraphs: %+v", paragraphs)
+		}
+	}
+	{ // case json array with text fields
+		content := `[{"title": "a", "body": {"text": "正文"}, "id": 1}, {"title": "b"}]`
+		paragraphs := load("a.json", content, CollectionOptions{TextFields: []string{"title", "body.text"}})
+		if len(paragraphs) != 2 || paragraphs[0].Text != "title: a\nbody.text: 正文" || !strings.HasPrefix(content[paragraphs[1].Offset:], `{"title": "b"}`) {
+			t.Fatalf("unexpected json paragraphs: %+v", paragraphs)
+		}
+	}
+	{ // case json lines with all fields
+		content := "{\"b\": \"2\", \"a\": [\"x\", \"y\"]}\n{\"a\": true}\n"
+		paragraphs := load("a.jsonl", content, CollectionOptions{})
+		if len(paragraphs) != 2 || paragraphs[0].Text != "a: x, y\nb: 2" || paragraphs[1].Offset != strings.Index(content, "{\"a\": true}") {
+			t.Fatalf("unexpected jsonl paragraphs: %+v", paragraphs)
+		}
+	}
+	{ // case docx with heading styles
+		var buf bytes.Buffer
+		archive := zip.NewWriter(&buf)
+		w, _ := archive.Create("word/styles.xml")
+		w.Write([]byte(`<w:styles xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+<w:style w:styleId="1"><w:name w:val="heading 1"/></w:style></w:styles>`))
+		w, _ = archive.Create("word/document.xml")
+		w.Write([]byte(`<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>
+<w:p><w:r><w:t>前言</w:t></w:r></w:p>
+<w:p><w:pPr><w:pStyle w:val="1"/></w:pPr><w:r><w:t>第一章</w:t></w:r></w:p>
+<w:p><w:r><w:t>大难</w:t></w:r><w:r><w:t xml:space="preserve">不死</w:t></w:r><w:r><w:br/><w:t>的男孩</w:t></w:r></w:p>
+</w:body></w:document>`))
+		archive.Close()
+		paragraphs := load("a.docx", buf.String(), CollectionOptions{})
+		if len(paragraphs) != 2 || paragraphs[0].Heading != "" || paragraphs[1].Text != "大难不死\n的男孩" || paragraphs[1].Heading != "第一章" {
+			t.Fatalf("unexpected docx paragraphs: %+v", paragraphs)
+		}
+		chunks := chunker.Chunk(paragraphs)
+		if len(chunks) != 2 || chunks[1].paragraph != 2 || !strings.HasPrefix(chunks[1].text, "第一章\n") {
+			t.Fatalf("unexpected docx chunks: %+v", chunks)
+		}
+	}
+	{ // case pdf with pages and fonts
+		var compressed bytes.Buffer
+		zw := zlib.NewWriter(&compressed)
+		zw.Write([]byte("BT /F2 12 Tf 72 700 Td <00010002> Tj 0 -14 Td [<0003>] TJ ET"))
+		zw.Close()
+		cmap := "/CIDInit /ProcSet findresource begin 12 dict begin begincmap\n1 begincodespacerange <0000> <FFFF> endcodespacerange\n" +
+			"1 beginbfchar <0003> <4E09> endbfchar\n1 beginbfrange <0001> <0002> <4E00> endbfrange\nendcmap end end"
+		objects := []string{
+			"<< /Type /Catalog /Pages 2 0 R >>",
+			"<< /Type /Pages /Kids [3 0 R 4 0 R] /Count 2 /Resources << /Font << /F1 5 0 R /F2 6 0 R >> >> >>",
+			"<< /Type /Page /Parent 2 0 R /Contents 7 0 R >>",
+			"<< /Type /Page /Parent 2 0 R /Contents [8 0 R] >>",
+			"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+			"<< /Type /Font /Subtype /Type0 /Encoding /Identity-H /ToUnicode 9 0 R >>",
+			pdfTestStream("", "BT /F1 12 Tf 72 700 Td (Hello) Tj 40 0 Td [(wor) -20 (ld) -300 (again)] TJ -40 -14 Td (Next line) Tj 0 -40 Td (Second \\(para\\)) Tj ET"),
+			pdfTestStream("/Filter /FlateDecode", compressed.String()),
+			pdfTestStream("", cmap),
+		}
+		var content strings.Builder
+		content.WriteString("%PDF-1.4\n")
+		for i, object := range objects {
+			fmt.Fprintf(&content, "%d 0 obj\n%s\nendobj\n", i+1, object)
+		}
+		content.WriteString("trailer\n<< /Root 1 0 R >>\n%%EOF\n")
+		paragraphs := load("a.pdf", content.String(), CollectionOptions{})
+		if len(paragraphs) != 3 || paragraphs[0].Text != "Hello world again\nNext line" || paragraphs[1].Text != "Second (para)" || paragraphs[0].Page != 1 {
+			t.Fatalf("unexpected pdf paragraphs: %+v", paragraphs)
+		}
+		if paragraphs[2].Text != "一丁\n三" || paragraphs[2].Page != 2 {
+			t.Fatalf("unexpected pdf page 2: %+v", paragraphs[2])
+		}
+		chunks := chunker.Chunk(paragraphs)
+		if len(chunks) != 1 || chunks[0].page != 1 || chunks[0].endPage != 2 || chunks[0].paragraph != 1 {
+			t.Fatalf("unexpected pdf chunks: %+v", chunks)
+		}
+	}
+	{ // case pdf stream filters
+		if out, err := decodeAscii85([]byte("<~zz~>")); err != nil || len(out) != 8 {
+			t.Fatalf("unexpected ascii85 output: %v %v", out, err)
+		}
+		var bomb bytes.Buffer
+		zw := zlib.NewWriter(&bomb)
+		zw.Write(make([]byte, maxInflateSize+1))
+		zw.Close()
+		if _, err := inflate(bomb.Bytes()); err == nil {
+			t.Fatal("expected decompressed size limit")
+		}
+	}
+	{ // case loader from rule config
+		paragraphs := load("a.txt", `{"content": "正文"}`, CollectionOptions{Loader: LoaderJson, TextFields: []string{"content"}})
+		if len(paragraphs) != 1 || paragraphs[0].Text != "正文" {
+			t.Fatalf("unexpected configured loader: %+v", paragraphs)
+		}
+		if _, err := newLoader("a.txt", CollectionOptions{Loader: "pdf2"}); err == nil {
+			t.Fatal("expected unknown loader error")
+		}
+	}
+}
+
+// pdfTestStream 生成 PDF 流对象
+func pdfTestStream(dict string, data string) string {
+	return fmt.Sprintf("<< %s /Length %d >>\nstream\n%s\nendstream", dict, len(data), data)
+}
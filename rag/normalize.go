@@ -0,0 +1,109 @@
+package rag
+
+import (
+	"strings"
+	"unicode"
+)
+
+// textNormalizer 文本规范化流程，建立索引和查询时对称地使用，使文档和问题处于相同的词语空间
+// 依次进行全角转半角、繁体转简体、英文小写、按语言分词、停用词过滤和英文词干提取
+// 规范化的结果只用于向量化和关键词索引，返回给 LLM 的仍然是文本块原文
+type textNormalizer struct {
+	gse *GseManager // 中文分词管理器
+}
+
+// newTextNormalizer 创建文本规范化流程
+// 参数 gse: 中文分词管理器
+func newTextNormalizer(gse *GseManager) *textNormalizer {
+	return &textNormalizer{gse: gse}
+}
+
+// embeddingText 获取用于向量化的文本
+// 分词后去掉停用词和标点，词语之间用空格分隔；英文单词不提取词干，保持嵌入模型熟悉的词形
+// 没有剩余词语时使用规范化后的文本
+// 参数 text: 原文
+// 参数 language: 文本语言，为空时自动检测
+// 返回: 用于向量化的文本
+func (n *textNormalizer) embeddingText(text string, language string) string {
+	normalized := normalizeText(text)
+	if tokens := n.tokens(normalized, language, false); len(tokens) > 0 {
+		return strings.Join(tokens, " ")
+	}
+	return strings.TrimSpace(normalized)
+}
+
+// keywords 获取用于关键词索引的词语
+// 中文使用搜索引擎模式分词以提高召回率，英文单词提取词干，去掉停用词和标点
+// 参数 text: 原文
+// 参数 language: 文本语言，为空时自动检测
+// 返回: 词语数组
+func (n *textNormalizer) keywords(text string, language string) []string {
+	tokens := n.tokens(normalizeText(text), language, true)
+	for i, token := range tokens {
+		tokens[i] = porterStem(token)
+	}
+	return tokens
+}
+
+// tokens 按语言对规范化后的文本分词，并去掉空白、标点和停用词
+// 中文使用 gse 分词；英文按单词切分，其中的中文部分仍然使用 gse 分词
+func (n *textNormalizer) tokens(normalized string, language string, search bool) []string {
+	cut := func(text string) []string { return n.gse.cut(text, search) }
+	var tokens []string
+	if languageOf(language, normalized) == LanguageEnglish {
+		tokens = splitEnglishWords(normalized, cut)
+	} else {
+		tokens = cut(normalized)
+	}
+
+	var out []string
+	for _, token := range tokens {
+		token = strings.TrimSpace(token)
+		if !strings.ContainsFunc(token, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) {
+			continue
+		}
+		if stopwords[token] || englishStopwords[token] {
+			continue
+		}
+		out = append(out, token)
+	}
+	return out
+}
+
+// normalizeText 字符级的规范化：全角转半角、繁体转简体、英文小写
+// 逐个字符转换，不改变文本的结构
+func normalizeText(text string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '　':
+			// 全角空格
+			r = ' '
+		case r >= '！' && r <= '～':
+			// 全角 ASCII 字符
+			r -= 0xfee0
+		default:
+			if simplified, ok := traditionalChars[r]; ok {
+				r = simplified
+			}
+		}
+		return unicode.ToLower(r)
+	}, text)
+}
+
+// stopwords 停用词，在检索中几乎不提供区分度的虚词、代词和疑问词
+var stopwords = toSet(strings.Fields(`
+	的 地 得 了 着 过 和 与 及 或 而 且 并 但 也 又 就 都 还 才 很 太 更 最
+	是 在 有 为 被 把 让 给 对 从 向 到 以 于 之 其 所 等 等等 则 即
+	我 你 他 她 它 我们 你们 他们 她们 它们 自己 这 那 这个 那个 这些 那些 这里 那里
+	吗 呢 吧 啊 呀 哦 嗯 啦 么 嘛
+	什么 怎么 怎样 怎么样 如何 为什么 哪 哪里 哪个 哪些 谁 几 多少 请问 一下 一个 一些 没有 不是
+`))
+
+// toSet 将字符串数组转换为集合
+func toSet(items []string) map[string]bool {
+	set := make(map[string]bool, len(items))
+	for _, item := range items {
+		set[item] = true
+	}
+	return set
+}
@@ -0,0 +1,66 @@
+package rag
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestTextNormalizer(t *testing.T) {
+	normalizer := newTextNormalizer(newGseManager())
+
+	{ // case character normalization
+		if text := normalizeText("ＨＰ　１２３，魔藥課Ａ"); text != "hp 123,魔药课a" {
+			t.Fatalf("unexpected normalized text: %q", text)
+		}
+	}
+	{ // case traditional query and simplified document share tokens
+		query := normalizer.keywords("斯內普是誰？", "")
+		document := normalizer.keywords("斯内普是魔药课教授。", "")
+		if len(query) == 0 || slices.Contains(query, "是") || slices.Contains(query, "谁") {
+			t.Fatalf("expected stopwords removed, got %q", query)
+		}
+		for _, token := range query {
+			if !slices.Contains(document, token) {
+				t.Fatalf("expected %q in document tokens %q", token, document)
+			}
+		}
+	}
+	{ // case embedding text
+		if text := normalizer.embeddingText("哈利和魔法。", ""); text != "哈利 魔法" {
+			t.Fatalf("unexpected embedding text: %q", text)
+		}
+		if text := normalizer.embeddingText("是吗？", ""); text != "是吗?" {
+			t.Fatalf("expected fallback to normalized text, got %q", text)
+		}
+	}
+	{ // case porter stemming
+		for word, stem := range map[string]string{
+			"wizards": "wizard", "running": "run", "relational": "relat", "ponies": "poni", "caresses": "caress", "hopeful": "hope",
+		} {
+			if got := porterStem(word); got != stem {
+				t.Fatalf("expected %q -> %q, got %q", word, stem, got)
+			}
+		}
+	}
+	{ // case language detection
+		if language := detectLanguage("Who is the Potions master at Hogwarts?"); language != LanguageEnglish {
+			t.Fatalf("expected en, got %s", language)
+		}
+		if language := detectLanguage("斯内普是 Hogwarts 的魔药课教授"); language != LanguageChinese {
+			t.Fatalf("expected zh, got %s", language)
+		}
+	}
+	{ // case english keywords drop stopwords and share stems
+		query := normalizer.keywords("Who teaches Potions?", "")
+		document := normalizer.keywords("Snape taught potion classes and teaching was his job.", LanguageEnglish)
+		if !slices.Equal(query, []string{"teach", "potion"}) {
+			t.Fatalf("unexpected english keywords: %q", query)
+		}
+		if !slices.Contains(document, "potion") || !slices.Contains(document, "teach") || slices.Contains(document, "and") {
+			t.Fatalf("unexpected english document keywords: %q", document)
+		}
+		if text := normalizer.embeddingText("The Wizards' Houses", ""); text != "wizards houses" {
+			t.Fatalf("unexpected english embedding text: %q", text)
+		}
+	}
+}
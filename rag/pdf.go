@@ -0,0 +1,628 @@
+package rag
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"encoding/ascii85"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+)
+
+// 最小化的 PDF 解析器，只实现提取文本需要的部分：
+// 间接对象和对象流、常用的流过滤器、页面树、字体的 ToUnicode 映射
+// 不支持加密的 PDF
+
+// pdfRef 间接对象引用，如 12 0 R
+type pdfRef struct {
+	num int // 对象编号
+	gen int // 生成号
+}
+
+// pdfName 名称对象，如 /Type
+type pdfName string
+
+// pdfString 字符串对象（字面量或十六进制），保留原始字节
+type pdfString []byte
+
+// pdfKeyword 关键字，内容流中的操作符也解析为关键字
+type pdfKeyword string
+
+// pdfDict 字典对象
+type pdfDict map[pdfName]any
+
+// pdfArray 数组对象
+type pdfArray []any
+
+// pdfStream 流对象，data 为未解码的原始数据
+type pdfStream struct {
+	dict pdfDict
+	data []byte
+}
+
+// pdfDocument 已解析的 PDF 文档
+type pdfDocument struct {
+	objects  map[int]any // 对象编号到对象的映射
+	trailers []pdfDict   // 文件尾字典和交叉引用流字典
+}
+
+// maxPdfDepth 解析嵌套结构和解引用的最大深度，防止恶意文件导致无限递归
+const maxPdfDepth = 64
+
+// pdfObjectPattern 间接对象的开头，如 12 0 obj
+var pdfObjectPattern = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+
+// errPdfEncrypted 加密的 PDF
+var errPdfEncrypted = errors.New("encrypted pdf is not supported")
+
+// parsePdf 解析 PDF 文件
+// 不依赖交叉引用表，直接扫描文件中的所有间接对象，后出现的对象覆盖先出现的（增量更新）
+// 参数 data: 文件内容
+// 返回: pdfDocument、error
+func parsePdf(data []byte) (*pdfDocument, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(data, " \t\r\n"), []byte("%PDF-")) {
+		return nil, errors.New("not a pdf file")
+	}
+	doc := &pdfDocument{objects: make(map[int]any)}
+
+	for _, match := range pdfObjectPattern.FindAllSubmatchIndex(data, -1) {
+		num, _ := strconv.Atoi(string(data[match[2]:match[3]]))
+		parser := &pdfParser{data: data, pos: match[1]}
+		object, err := parser.parseIndirect()
+		if err != nil {
+			continue
+		}
+		doc.objects[num] = object
+		if stream, ok := object.(*pdfStream); ok && stream.dict["Type"] == pdfName("XRef") {
+			doc.trailers = append(doc.trailers, stream.dict)
+		}
+	}
+	for _, index := range allIndexes(data, []byte("trailer")) {
+		parser := &pdfParser{data: data, pos: index + len("trailer")}
+		if trailer, err := parser.parseObject(0); err == nil {
+			if dict, ok := trailer.(pdfDict); ok {
+				doc.trailers = append(doc.trailers, dict)
+			}
+		}
+	}
+	for _, trailer := range doc.trailers {
+		if trailer["Encrypt"] != nil {
+			return nil, errPdfEncrypted
+		}
+	}
+
+	// 展开对象流中的对象，文件中直接定义的对象优先
+	for num, object := range doc.objects {
+		if stream, ok := object.(*pdfStream); ok && stream.dict["Type"] == pdfName("ObjStm") {
+			doc.loadObjectStream(num, stream)
+		}
+	}
+	return doc, nil
+}
+
+// loadObjectStream 解析对象流中的对象
+func (d *pdfDocument) loadObjectStream(num int, stream *pdfStream) {
+	data, err := d.decodeStream(stream)
+	if err != nil {
+		return
+	}
+	count := pdfInt(d.resolve(stream.dict["N"]))
+	first := pdfInt(d.resolve(stream.dict["First"]))
+	header := &pdfParser{data: data}
+	for i := 0; i < count; i++ {
+		objNum, err1 := header.parseObject(0)
+		offset, err2 := header.parseObject(0)
+		if err1 != nil || err2 != nil {
+			return
+		}
+		n, start := pdfInt(objNum), first+pdfInt(offset)
+		if _, ok := d.objects[n]; ok || n == num || start < 0 || start >= len(data) {
+			continue
+		}
+		parser := &pdfParser{data: data, pos: start}
+		if object, err := parser.parseObject(0); err == nil {
+			d.objects[n] = object
+		}
+	}
+}
+
+// resolve 解引用间接对象
+func (d *pdfDocument) resolve(object any) any {
+	for i := 0; i < maxPdfDepth; i++ {
+		ref, ok := object.(pdfRef)
+		if !ok {
+			return object
+		}
+		object = d.objects[ref.num]
+	}
+	return nil
+}
+
+// dict 解引用并获取字典，流对象返回流的字典
+func (d *pdfDocument) dict(object any) pdfDict {
+	switch v := d.resolve(object).(type) {
+	case pdfDict:
+		return v
+	case *pdfStream:
+		return v.dict
+	}
+	return nil
+}
+
+// array 解引用并获取数组，单个对象视为只有一个元素的数组
+func (d *pdfDocument) array(object any) pdfArray {
+	switch v := d.resolve(object).(type) {
+	case pdfArray:
+		return v
+	case nil:
+		return nil
+	default:
+		return pdfArray{v}
+	}
+}
+
+// catalog 获取文档目录字典
+func (d *pdfDocument) catalog() pdfDict {
+	for i := len(d.trailers) - 1; i >= 0; i-- {
+		if root := d.dict(d.trailers[i]["Root"]); root != nil {
+			return root
+		}
+	}
+	// 文件尾损坏时查找类型为 Catalog 的对象
+	for _, object := range d.objects {
+		if dict, ok := object.(pdfDict); ok && dict["Type"] == pdfName("Catalog") {
+			return dict
+		}
+	}
+	return nil
+}
+
+// pdfPage 页面字典及其继承的资源
+type pdfPage struct {
+	dict      pdfDict
+	resources pdfDict
+}
+
+// pages 按顺序获取所有页面
+func (d *pdfDocument) pages() ([]pdfPage, error) {
+	catalog := d.catalog()
+	if catalog == nil {
+		return nil, errors.New("pdf catalog not found")
+	}
+	var pages []pdfPage
+	visited := make(map[int]bool)
+	var walk func(object any, resources pdfDict, depth int)
+	walk = func(object any, resources pdfDict, depth int) {
+		if ref, ok := object.(pdfRef); ok {
+			// 防止页面树中的循环引用
+			if visited[ref.num] {
+				return
+			}
+			visited[ref.num] = true
+		}
+		node := d.dict(object)
+		if node == nil || depth > maxPdfDepth {
+			return
+		}
+		if r := d.dict(node["Resources"]); r != nil {
+			resources = r
+		}
+		kids := node["Kids"]
+		if node["Type"] == pdfName("Page") || kids == nil {
+			pages = append(pages, pdfPage{dict: node, resources: resources})
+			return
+		}
+		for _, kid := range d.array(kids) {
+			walk(kid, resources, depth+1)
+		}
+	}
+	walk(catalog["Pages"], nil, 0)
+	return pages, nil
+}
+
+// decodeStream 按 /Filter 解码流数据
+func (d *pdfDocument) decodeStream(stream *pdfStream) ([]byte, error) {
+	data := stream.data
+	for _, filter := range d.array(stream.dict["Filter"]) {
+		name, _ := d.resolve(filter).(pdfName)
+		var err error
+		switch name {
+		case "FlateDecode", "Fl":
+			data, err = inflate(data)
+		case "ASCIIHexDecode", "AHx":
+			data, err = decodeAsciiHex(data)
+		case "ASCII85Decode", "A85":
+			data, err = decodeAscii85(data)
+		default:
+			return nil, fmt.Errorf("unsupported pdf filter: %s", name)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// maxInflateSize 单个流解压后的最大字节数，防止压缩炸弹耗尽内存
+const maxInflateSize = 64 << 20
+
+// inflate 解压 FlateDecode 数据，数据被截断时返回已解压的部分
+// 解压后超过 maxInflateSize 时返回错误
+func inflate(data []byte) ([]byte, error) {
+	var reader io.Reader
+	if zr, err := zlib.NewReader(bytes.NewReader(data)); err == nil {
+		reader = zr
+	} else {
+		// 部分文件缺少 zlib 头，按原始 deflate 数据处理
+		reader = flate.NewReader(bytes.NewReader(data))
+	}
+	out, err := io.ReadAll(io.LimitReader(reader, maxInflateSize+1))
+	if len(out) > maxInflateSize {
+		return nil, fmt.Errorf("pdf stream exceeds %d bytes after decompression", maxInflateSize)
+	}
+	if err != nil && len(out) == 0 {
+		return nil, err
+	}
+	return out, nil
+}
+
+// decodeAsciiHex 解码 ASCIIHexDecode 数据
+func decodeAsciiHex(data []byte) ([]byte, error) {
+	var digits []byte
+	for _, c := range data {
+		if c == '>' {
+			break
+		}
+		if isHexDigit(c) {
+			digits = append(digits, c)
+		}
+	}
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	out := make([]byte, len(digits)/2)
+	_, err := hex.Decode(out, digits)
+	return out, err
+}
+
+// decodeAscii85 解码 ASCII85Decode 数据
+func decodeAscii85(data []byte) ([]byte, error) {
+	data = bytes.TrimSpace(data)
+	data = bytes.TrimPrefix(data, []byte("<~"))
+	if index := bytes.Index(data, []byte("~>")); index >= 0 {
+		data = data[:index]
+	}
+	// 每个 z 解码为 4 个零字节，按最坏情况分配
+	out := make([]byte, 4*len(data))
+	n, _, err := ascii85.Decode(out, data, true)
+	return out[:n], err
+}
+
+// allIndexes 获取 sep 在 data 中出现的所有位置
+func allIndexes(data []byte, sep []byte) []int {
+	var indexes []int
+	for start := 0; ; {
+		index := bytes.Index(data[start:], sep)
+		if index < 0 {
+			return indexes
+		}
+		indexes = append(indexes, start+index)
+		start += index + len(sep)
+	}
+}
+
+// pdfInt 将数字对象转换为整数
+func pdfInt(object any) int {
+	switch v := object.(type) {
+	case int:
+		return v
+	case float64:
+		return int(v)
+	}
+	return 0
+}
+
+// pdfFloat 将数字对象转换为浮点数
+func pdfFloat(object any) float64 {
+	switch v := object.(type) {
+	case int:
+		return float64(v)
+	case float64:
+		return v
+	}
+	return 0
+}
+
+// pdfParser PDF 对象的词法和语法解析器，同时用于内容流和 CMap
+type pdfParser struct {
+	data []byte
+	pos  int
+}
+
+// isPdfSpace 是否为 PDF 空白字符
+func isPdfSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f' || c == 0
+}
+
+// isPdfDelimiter 是否为 PDF 分隔符
+func isPdfDelimiter(c byte) bool {
+	return bytes.IndexByte([]byte("()<>[]{}/%"), c) >= 0
+}
+
+// isHexDigit 是否为十六进制数字
+func isHexDigit(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+// skipSpace 跳过空白和注释
+func (p *pdfParser) skipSpace() {
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		if c == '%' {
+			for p.pos < len(p.data) && p.data[p.pos] != '\n' && p.data[p.pos] != '\r' {
+				p.pos++
+			}
+			continue
+		}
+		if !isPdfSpace(c) {
+			return
+		}
+		p.pos++
+	}
+}
+
+// parseIndirect 解析间接对象的内容（obj 之后），字典之后有 stream 关键字时解析为流
+func (p *pdfParser) parseIndirect() (any, error) {
+	object, err := p.parseObject(0)
+	if err != nil {
+		return nil, err
+	}
+	dict, ok := object.(pdfDict)
+	if !ok {
+		return object, nil
+	}
+	p.skipSpace()
+	if !bytes.HasPrefix(p.data[p.pos:], []byte("stream")) {
+		return dict, nil
+	}
+	p.pos += len("stream")
+	if p.pos < len(p.data) && p.data[p.pos] == '\r' {
+		p.pos++
+	}
+	if p.pos < len(p.data) && p.data[p.pos] == '\n' {
+		p.pos++
+	}
+	start := p.pos
+
+	// 优先使用直接给出的 /Length，校验失败时查找 endstream
+	if length, ok := dict["Length"].(int); ok && length >= 0 && start+length <= len(p.data) {
+		rest := bytes.TrimLeft(p.data[start+length:], " \t\r\n")
+		if bytes.HasPrefix(rest, []byte("endstream")) {
+			return &pdfStream{dict: dict, data: p.data[start : start+length]}, nil
+		}
+	}
+	end := bytes.Index(p.data[start:], []byte("endstream"))
+	if end < 0 {
+		return nil, errors.New("endstream not found")
+	}
+	data := bytes.TrimRight(p.data[start:start+end], "\r\n")
+	return &pdfStream{dict: dict, data: data}, nil
+}
+
+// parseObject 解析一个对象
+// 参数 depth: 当前嵌套深度
+// 返回: 对象、error，数据结束时返回 io.EOF
+func (p *pdfParser) parseObject(depth int) (any, error) {
+	if depth > maxPdfDepth {
+		return nil, errors.New("pdf object nested too deep")
+	}
+	p.skipSpace()
+	if p.pos >= len(p.data) {
+		return nil, io.EOF
+	}
+	c := p.data[p.pos]
+	switch {
+	case c == '/':
+		return p.parseName(), nil
+	case c == '(':
+		return p.parseLiteralString(), nil
+	case c == '<' && p.pos+1 < len(p.data) && p.data[p.pos+1] == '<':
+		p.pos += 2
+		return p.parseDict(depth)
+	case c == '<':
+		return p.parseHexString(), nil
+	case c == '[':
+		p.pos++
+		return p.parseArray(depth)
+	case c == '>' || c == ']' || c == ')' || c == '{' || c == '}':
+		// 多余的分隔符作为关键字返回，由调用者处理
+		p.pos++
+		return pdfKeyword([]byte{c}), nil
+	case c >= '0' && c <= '9' || c == '+' || c == '-' || c == '.':
+		return p.parseNumberOrRef(), nil
+	}
+	start := p.pos
+	for p.pos < len(p.data) && !isPdfSpace(p.data[p.pos]) && !isPdfDelimiter(p.data[p.pos]) {
+		p.pos++
+	}
+	if p.pos == start {
+		p.pos++
+	}
+	switch word := string(p.data[start:p.pos]); word {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	default:
+		return pdfKeyword(word), nil
+	}
+}
+
+// parseName 解析名称，处理 #xx 转义
+func (p *pdfParser) parseName() pdfName {
+	p.pos++
+	var name []byte
+	for p.pos < len(p.data) && !isPdfSpace(p.data[p.pos]) && !isPdfDelimiter(p.data[p.pos]) {
+		c := p.data[p.pos]
+		if c == '#' && p.pos+2 < len(p.data) && isHexDigit(p.data[p.pos+1]) && isHexDigit(p.data[p.pos+2]) {
+			value, _ := strconv.ParseUint(string(p.data[p.pos+1:p.pos+3]), 16, 8)
+			name = append(name, byte(value))
+			p.pos += 3
+			continue
+		}
+		name = append(name, c)
+		p.pos++
+	}
+	return pdfName(name)
+}
+
+// parseLiteralString 解析字面量字符串，处理转义和嵌套的括号
+func (p *pdfParser) parseLiteralString() pdfString {
+	p.pos++
+	var out []byte
+	for depth := 1; p.pos < len(p.data); {
+		c := p.data[p.pos]
+		p.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return out
+			}
+		case '\\':
+			if p.pos >= len(p.data) {
+				return out
+			}
+			c = p.data[p.pos]
+			p.pos++
+			switch c {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				// 行尾的反斜杠表示续行
+				if p.pos < len(p.data) && p.data[p.pos] == '\n' {
+					p.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if c >= '0' && c <= '7' {
+					value := int(c - '0')
+					for i := 0; i < 2 && p.pos < len(p.data) && p.data[p.pos] >= '0' && p.data[p.pos] <= '7'; i++ {
+						value = value*8 + int(p.data[p.pos]-'0')
+						p.pos++
+					}
+					c = byte(value)
+				}
+			}
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// parseHexString 解析十六进制字符串
+func (p *pdfParser) parseHexString() pdfString {
+	p.pos++
+	end := bytes.IndexByte(p.data[p.pos:], '>')
+	if end < 0 {
+		end = len(p.data) - p.pos
+	}
+	out, _ := decodeAsciiHex(p.data[p.pos : p.pos+end])
+	p.pos += end + 1
+	return out
+}
+
+// parseDict 解析字典（<< 之后）
+func (p *pdfParser) parseDict(depth int) (pdfDict, error) {
+	dict := make(pdfDict)
+	for {
+		p.skipSpace()
+		if p.pos+1 < len(p.data) && p.data[p.pos] == '>' && p.data[p.pos+1] == '>' {
+			p.pos += 2
+			return dict, nil
+		}
+		key, err := p.parseObject(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		name, ok := key.(pdfName)
+		if !ok {
+			// 忽略非法的键
+			continue
+		}
+		value, err := p.parseObject(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		dict[name] = value
+	}
+}
+
+// parseArray 解析数组（[ 之后）
+func (p *pdfParser) parseArray(depth int) (pdfArray, error) {
+	var array pdfArray
+	for {
+		p.skipSpace()
+		if p.pos < len(p.data) && p.data[p.pos] == ']' {
+			p.pos++
+			return array, nil
+		}
+		value, err := p.parseObject(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		array = append(array, value)
+	}
+}
+
+// parseNumberOrRef 解析数字，整数之后跟着 "生成号 R" 时解析为引用
+func (p *pdfParser) parseNumberOrRef() any {
+	start := p.pos
+	p.pos++
+	for p.pos < len(p.data) && (p.data[p.pos] >= '0' && p.data[p.pos] <= '9' || p.data[p.pos] == '.') {
+		p.pos++
+	}
+	text := string(p.data[start:p.pos])
+	num, err := strconv.Atoi(text)
+	if err != nil {
+		value, _ := strconv.ParseFloat(text, 64)
+		return value
+	}
+
+	// 向前查看是否为引用
+	save := p.pos
+	p.skipSpace()
+	genStart := p.pos
+	for p.pos < len(p.data) && p.data[p.pos] >= '0' && p.data[p.pos] <= '9' {
+		p.pos++
+	}
+	if p.pos > genStart {
+		gen, _ := strconv.Atoi(string(p.data[genStart:p.pos]))
+		p.skipSpace()
+		if p.pos < len(p.data) && p.data[p.pos] == 'R' &&
+			(p.pos+1 == len(p.data) || isPdfSpace(p.data[p.pos+1]) || isPdfDelimiter(p.data[p.pos+1])) {
+			p.pos++
+			return pdfRef{num: num, gen: gen}
+		}
+	}
+	p.pos = save
+	return num
+}
@@ -0,0 +1,65 @@
+package rag
+
+import (
+	"go-ollama/rule"
+	"strings"
+)
+
+// retrievalQuery 检索使用的查询文本
+type retrievalQuery struct {
+	text       string // 查询文本
+	vectorOnly bool   // 只用于向量检索，HyDE 的假设性回答与文档的措辞不同，不参与关键词检索
+}
+
+// queryFromRule 获取规则配置的查询改写和扩展配置，规则为 nil 时都不开启
+func queryFromRule(rule *rule.Rule) (cfg rule.QueryConfig) {
+	if rule != nil {
+		cfg = rule.Query()
+	}
+	return cfg
+}
+
+// transformQuery 按规则配置在检索前改写和扩展问题
+// 依次进行：结合对话历史改写为独立的问题、生成多种表述、生成假设性回答（HyDE）
+// 每一步都需要调用 LLM，失败时跳过该步骤，仍然使用已有的查询进行检索
+// 参数 ragCtx: RAG 上下文
+// 参数 text: 用户问题
+// 参数 history: 最近的对话历史，为空时不改写
+// 参数 cfg: 查询改写和扩展配置
+// 返回: 改写后的问题（用于重排）、检索使用的查询列表
+func (r *ragManager) transformQuery(ragCtx *RagContext, text string, history string, cfg rule.QueryConfig) (string, []retrievalQuery) {
+	if r.transformer == nil {
+		return text, []retrievalQuery{{text: text}}
+	}
+	if cfg.Rewrite && strings.TrimSpace(history) != "" {
+		language := languageOf(ragCtx.options.Language, normalizeText(text))
+		if rewritten, err := r.transformer.RewriteQuery(text, history, language); err == nil && strings.TrimSpace(rewritten) != "" {
+			text = strings.TrimSpace(rewritten)
+		}
+	}
+
+	language := languageOf(ragCtx.options.Language, normalizeText(text))
+	queries := []retrievalQuery{{text: text}}
+	if cfg.Expand > 0 {
+		if paraphrases, err := r.transformer.ExpandQuery(text, cfg.Expand, language); err == nil {
+			seen := map[string]bool{text: true}
+			for _, paraphrase := range paraphrases {
+				if len(queries) > cfg.Expand {
+					break
+				}
+				paraphrase = strings.TrimSpace(paraphrase)
+				if paraphrase == "" || seen[paraphrase] {
+					continue
+				}
+				seen[paraphrase] = true
+				queries = append(queries, retrievalQuery{text: paraphrase})
+			}
+		}
+	}
+	if cfg.Hyde {
+		if answer, err := r.transformer.HypotheticalAnswer(text, language); err == nil && strings.TrimSpace(answer) != "" {
+			queries = append(queries, retrievalQuery{text: answer, vectorOnly: true})
+		}
+	}
+	return text, queries
+}
@@ -0,0 +1,51 @@
+package rag
+
+import (
+	"errors"
+	"go-ollama/rule"
+	"testing"
+)
+
+type fakeTransformer struct {
+	expandErr error
+}
+
+func (f *fakeTransformer) RewriteQuery(text string, history string, language string) (string, error) {
+	return "斯内普教什么课？", nil
+}
+
+func (f *fakeTransformer) ExpandQuery(text string, num int, language string) ([]string, error) {
+	return []string{text, "斯内普的课程", "", "斯内普是哪门课的教授", "多余的表述"}, f.expandErr
+}
+
+func (f *fakeTransformer) HypotheticalAnswer(text string, language string) (string, error) {
+	return "斯内普是魔药课教授。", nil
+}
+
+func TestTransformQuery(t *testing.T) {
+	ragCtx := &RagContext{}
+	{ // case disabled
+		r := &ragManager{transformer: &fakeTransformer{}}
+		text, queries := r.transformQuery(ragCtx, "那他呢？", "user: 斯内普是谁？\n", rule.QueryConfig{})
+		if text != "那他呢？" || len(queries) != 1 || queries[0].text != "那他呢？" {
+			t.Fatalf("unexpected queries: %q %+v", text, queries)
+		}
+	}
+	{ // case rewrite, expand and hyde
+		r := &ragManager{transformer: &fakeTransformer{}}
+		text, queries := r.transformQuery(ragCtx, "那他呢？", "user: 斯内普是谁？\n", rule.QueryConfig{Rewrite: true, Expand: 2, Hyde: true})
+		if text != "斯内普教什么课？" || len(queries) != 4 {
+			t.Fatalf("unexpected queries: %q %+v", text, queries)
+		}
+		if queries[1].text != "斯内普的课程" || queries[2].text != "斯内普是哪门课的教授" || !queries[3].vectorOnly {
+			t.Fatalf("unexpected queries: %+v", queries)
+		}
+	}
+	{ // case no history and failed expansion
+		r := &ragManager{transformer: &fakeTransformer{expandErr: errors.New("timeout")}}
+		text, queries := r.transformQuery(ragCtx, "那他呢？", "", rule.QueryConfig{Rewrite: true, Expand: 2})
+		if text != "那他呢？" || len(queries) != 1 {
+			t.Fatalf("unexpected queries: %q %+v", text, queries)
+		}
+	}
+}
@@ -1,33 +1,57 @@
 package rag
 
 import (
+	"fmt"
 	"go-ollama/rule"
-	"sort"
+	"os"
 	"strings"
 	"sync"
+	"time"
 )
 
 // RagManager RAG 管理器接口
 // 负责检索增强生成的完整流程
-// 包括文本预处理、向量检索和结果重排序
+// 包括知识库文档管理、文本预处理、向量检索和结果重排序
 type RagManager interface {
-	PreprocessFromFile(filepath string) (*RagContext, chan ProgressInfo, error)
-	Query(ragCtx *RagContext, text string, rule *rule.Rule) (chan string, error)
+	OpenCollection(name string, options CollectionOptions) (*RagContext, error)
+	AddDocuments(ragCtx *RagContext, sources []DocumentSource) (chan ProgressInfo, error)
+	DeleteDocument(ragCtx *RagContext, docId string) error
+	ListDocuments(ragCtx *RagContext) []DocumentInfo
+	Reindex(ragCtx *RagContext) (chan ProgressInfo, error)
+	Query(ragCtx *RagContext, text string, history string, rule *rule.Rule) (chan string, error)
 }
 
 // ragManager RAG 管理器实现（包私有）
 type ragManager struct {
-	chromem  *ChromemManager // 向量数据库管理器
-	gse      *GseManager     // 中文分词管理器
-	reranker Rerankable      // 重排序器接口
+	chromem        *ChromemManager    // 向量数据库管理器
+	normalizer     *textNormalizer    // 文本规范化流程（包含中文分词）
+	reranker       Rerankable         // 重排序器接口（LLM 重排）
+	rerankEmbedder Embeddable         // 嵌入重排和 MMR 使用的向量化接口
+	transformer    QueryTransformable // 查询改写接口，为 nil 时不改写和扩展问题
 
-	mu            sync.Mutex // 保护并发访问的互斥锁
-	autogenRagId  int        // 自动生成的 RAG 上下文 ID
+	mu           sync.Mutex // 保护并发访问的互斥锁
+	autogenRagId int        // 自动生成的 RAG 上下文 ID
 }
 
 // Rerankable 重排序器接口，用于对检索结果进行重排序
+// 只返回候选文档的序号和相关性评分，由 RAG 管理器按序号取回原文，避免模型复述时改写原文
+// 返回的结果可以多于 num 个或包含无效、重复的序号，由 RAG 管理器过滤后截取
 type Rerankable interface {
-	RankCandidate(candidates string, text string, num int) (string, error)
+	RankCandidates(candidates []string, text string, num int, language string) ([]RankedCandidate, error) // language 为问题的语言，用于选择提示词
+}
+
+// QueryTransformable 查询改写接口，用于在检索前改写和扩展问题
+// language 为问题的语言，用于选择提示词
+type QueryTransformable interface {
+	RewriteQuery(text string, history string, language string) (string, error) // 结合对话历史将追问改写为独立的问题
+	ExpandQuery(text string, num int, language string) ([]string, error)       // 生成 num 个不同表述的问题
+	HypotheticalAnswer(text string, language string) (string, error)           // 生成假设性回答（HyDE）
+}
+
+// Embeddable 向量化接口，用于将文档和查询文本转换为向量
+type Embeddable interface {
+	EmbedText(text string) ([]float32, error)
+	EmbedModelName() string // 嵌入模型名称，模型变化时需要重新向量化
 }
 
 // retrievalCount 向量检索返回的候选文档数量
@@ -43,29 +67,55 @@ var (
 
 // newRagManager 创建并初始化 RAG 管理器实例
 // 参数 reranker: 重排序器接口
-// 返回: ragManager 实例
-func newRagManager(reranker Rerankable) *ragManager {
-	return &ragManager{
-		chromem:  newChromemManager(),
-		gse:      newGseManager(),
-		reranker: reranker,
+// 参数 transformer: 查询改写接口
+// 参数 embedder: 向量化接口
+// 参数 rerankEmbedder: 嵌入重排和 MMR 使用的向量化接口，为 nil 时使用 embedder
+// 参数 vectorDir: 向量数据库的持久化目录，为空时只保存在内存中
+// 返回: ragManager 实例、error
+func newRagManager(reranker Rerankable, transformer QueryTransformable, embedder Embeddable, rerankEmbedder Embeddable, vectorDir string) (*ragManager, error) {
+	chromem, err := newChromemManager(embedder, vectorDir)
+	if err != nil {
+		return nil, err
+	}
+	if rerankEmbedder == nil {
+		rerankEmbedder = embedder
 	}
+	return &ragManager{
+		chromem:        chromem,
+		normalizer:     newTextNormalizer(newGseManager()),
+		reranker:       reranker,
+		rerankEmbedder: rerankEmbedder,
+		transformer:    transformer,
+	}, nil
 }
 
-func StartRagManager(reranker Rerankable) RagManager {
+// StartRagManager 获取 RAG 管理器单例
+// 参数 reranker: 重排序器接口
+// 参数 transformer: 查询改写接口
+// 参数 embedder: 向量化接口
+// 参数 rerankEmbedder: 嵌入重排和 MMR 使用的向量化接口，为 nil 时使用 embedder
+// 参数 vectorDir: 向量数据库的持久化目录，为空时只保存在内存中
+// 返回: RagManager 实例、error
+func StartRagManager(reranker Rerankable, transformer QueryTransformable, embedder Embeddable, rerankEmbedder Embeddable, vectorDir string) (RagManager, error) {
+	var err error
 	ragOnce.Do(func() {
-		ragInstance = newRagManager(reranker)
+		ragInstance, err = newRagManager(reranker, transformer, embedder, rerankEmbedder, vectorDir)
 	})
-	return ragInstance
+
+	if err != nil {
+		return nil, err
+	}
+	return ragInstance, nil
 }
 
 // RAG 流程说明
 // 预处理阶段：
-// 1. 文本分块（chunking）：将文档按段落分割成多个块
+// 1. 文本分块（chunking）：按文件类型选择文档加载器解析段落，再组合成多个块
 // 2. 向量化存储（embedding）：对每个文本块进行向量化并存储到向量数据库
 // 检索阶段：
-// 3.1 召回（retrieval）：通过向量相似度检索相关文档
-// 3.2 重排（reranking）：使用 LLM 对检索结果进行相关性重排序
+// 3.0 查询改写（query transformation）：按规则配置结合对话历史改写问题，生成多种表述和假设性回答
+// 3.1 召回（retrieval）：通过向量相似度和 BM25 关键词检索相关文档，再融合排名
+// 3.2 重排（reranking）：为检索结果的相关性打分（LLM、嵌入模型或 MMR），按分数选择文档并丢弃不相关的文档
 
 // ProgressInfo 预处理进度信息，通过 channel 实时返回
 type ProgressInfo struct {
@@ -73,93 +123,274 @@ type ProgressInfo struct {
 	Total      int     // 总项数
 	Percentage float32 // 完成百分比
 	Err        error   // 错误信息
+	DocumentId string  // 当前处理的文档 ID
 	Text       string  // 当前处理的文本内容
+	Reused     bool    // 是否复用了已持久化的向量
 }
 
-// PreprocessFromFile 从文件预处理知识库
-// 包括文本分块、中文分词、向量化和存储
-// 参数 filepath: 源文件路径
-// 返回: RagContext、ProgressInfo channel、error
-// 注意：ProgressInfo channel 需要调用者消费，否则会导致 goroutine 阻塞
-func (r *ragManager) PreprocessFromFile(filepath string) (*RagContext, chan ProgressInfo, error) {
+// OpenCollection 打开知识库，不存在时创建
+// 持久化时加载已有的文档清单，已向量化的文档可以直接检索
+// 参数 name: 知识库名称
+// 参数 options: 知识库的加载选项
+// 返回: RagContext、error
+func (r *ragManager) OpenCollection(name string, options CollectionOptions) (*RagContext, error) {
+	// 提前检查分块选项、语言和重排方式，避免每个文档都分块失败或每次检索都重排失败
+	if _, err := newChunker(options.Chunk); err != nil {
+		return nil, err
+	}
+	reranker, err := r.rerankerFromOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	language, err := checkLanguage(options.Language)
+	if err != nil {
+		return nil, err
+	}
+	options.Language = language
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	ragId := r.autogenRagId
 	r.autogenRagId++
 
-	chunks, err := chunksFromTextFile(filepath)
-	if err != nil {
-		return nil, nil, err
+	if err := r.chromem.newCollection(ragId, name); err != nil {
+		return nil, err
 	}
+	ragCtx := &RagContext{
+		ragId:    ragId,
+		name:     name,
+		manifest: r.chromem.manifestPath(name),
+		options:  options,
+		reranker: reranker,
+		keywords: newBm25Index(),
+	}
+	if err := ragCtx.loadManifest(); err != nil {
+		return nil, err
+	}
+	r.rebuildKeywords(ragCtx)
+	return ragCtx, nil
+}
 
-	err = r.chromem.newCollection(ragId)
-	if err != nil {
-		return nil, nil, err
+// documentChunks 读取并分块的待添加文档
+type documentChunks struct {
+	source DocumentSource
+	hash   string      // 源文件内容的哈希
+	chunks []textChunk // 文本块数组
+	err    error       // 读取失败的原因
+}
+
+// AddDocuments 添加或更新知识库中的文档
+// 包括文本分块、中文分词、向量化和存储，文档 ID 已存在时替换原有的文本块
+// 参数 ragCtx: RAG 上下文
+// 参数 sources: 待添加的文档，同一 ID 出现多次时以最后一个为准
+// 返回: ProgressInfo channel、error
+// 注意：ProgressInfo channel 需要调用者消费，否则会导致 goroutine 阻塞
+func (r *ragManager) AddDocuments(ragCtx *RagContext, sources []DocumentSource) (chan ProgressInfo, error) {
+	var ids []string
+	latest := make(map[string]DocumentSource)
+	for _, source := range sources {
+		if err := checkDocumentId(source.Id); err != nil {
+			return nil, err
+		}
+		if _, ok := latest[source.Id]; !ok {
+			ids = append(ids, source.Id)
+		}
+		latest[source.Id] = source
 	}
 
-	// 创建进度 channel，使用 goroutine 异步处理
 	chProg := make(chan ProgressInfo)
 	go func() {
 		defer close(chProg)
-		for i := 0; i < len(chunks); i++ {
-			// 对中文文本进行分词，提升向量化效果
-			words := r.gse.splitChineseWords(chunks[i])
-			// 将文档添加到向量数据库（自动进行向量化）
-			err = r.chromem.addDocuments(ragId, i, words)
-
-			percentage := float32(i+1) / float32(len(chunks)) * 100
-			// 发送进度信息
-			chProg <- ProgressInfo{
-				Current:    i + 1,
-				Total:      len(chunks),
-				Percentage: percentage,
-				Err:        err,
-				Text:       chunks[i],
+		ragCtx.writeMu.Lock()
+		defer ragCtx.writeMu.Unlock()
+
+		// 先读取所有文档，以便计算总进度
+		var docs []documentChunks
+		total := 0
+		for _, id := range ids {
+			doc := readDocument(latest[id], ragCtx.options)
+			total += len(doc.chunks)
+			docs = append(docs, doc)
+		}
+
+		current := 0
+		for _, doc := range docs {
+			if doc.err != nil {
+				// 读取失败时保留文档原有的文本块
+				chProg <- ProgressInfo{Current: current, Total: total, Percentage: percentage(current, total), Err: doc.err, DocumentId: doc.source.Id}
+				continue
+			}
+			for i, chunk := range doc.chunks {
+				// 对文本进行规范化和分词，提升向量化效果，检索时对问题进行相同的处理
+				words := r.normalizer.embeddingText(chunk.text, ragCtx.options.Language)
+				// 将文本块添加到向量数据库（内容和嵌入模型未变化时复用已有向量，否则进行向量化）
+				ref := chunkRef{
+					docId:     doc.source.Id,
+					index:     i,
+					source:    doc.source.Source,
+					offset:    chunk.offset,
+					page:      chunk.page,
+					endPage:   chunk.endPage,
+					paragraph: chunk.paragraph,
+					content:   chunk.text,
+				}
+				reused, err := r.chromem.addChunk(ragCtx.ragId, ref, words)
+				if err == nil {
+					ragCtx.keywords.add(doc.source.Id, i, r.normalizer.keywords(chunk.text, ragCtx.options.Language))
+				}
+				current++
+				// 发送进度信息
+				chProg <- ProgressInfo{
+					Current:    current,
+					Total:      total,
+					Percentage: percentage(current, total),
+					Err:        err,
+					DocumentId: doc.source.Id,
+					Text:       chunk.text,
+					Reused:     reused,
+				}
 			}
+			// 文档变短时，清理多余的旧文本块
+			ragCtx.keywords.removeFrom(doc.source.Id, len(doc.chunks))
+			if err := r.chromem.removeChunksFrom(ragCtx.ragId, doc.source.Id, len(doc.chunks)); err != nil {
+				chProg <- ProgressInfo{Current: current, Total: total, Percentage: percentage(current, total), Err: err, DocumentId: doc.source.Id}
+			}
+			ragCtx.setDocument(DocumentInfo{
+				Id:        doc.source.Id,
+				Source:    doc.source.Source,
+				Origin:    doc.source.Origin,
+				Chunks:    len(doc.chunks),
+				Hash:      doc.hash,
+				UpdatedAt: time.Now(),
+			})
+		}
+		if err := ragCtx.saveManifest(); err != nil {
+			chProg <- ProgressInfo{Current: current, Total: total, Percentage: 100, Err: err}
 		}
 	}()
+	return chProg, nil
+}
 
-	ragCtx := RagContext{ragId: ragId, chunks: chunks}
-	return &ragCtx, chProg, nil
+// readDocument 使用知识库的加载选项读取文档并分块
+func readDocument(source DocumentSource, options CollectionOptions) documentChunks {
+	doc := documentChunks{source: source}
+	data, err := os.ReadFile(source.Source)
+	if err != nil {
+		doc.err = err
+		return doc
+	}
+	doc.hash = hashText(string(data))
+	loader, err := newLoader(source.Source, options)
+	if err != nil {
+		doc.err = err
+		return doc
+	}
+	chunker, err := newChunker(options.Chunk)
+	if err != nil {
+		doc.err = err
+		return doc
+	}
+	doc.chunks, doc.err = chunksFromFile(source.Source, loader, chunker)
+	return doc
+}
+
+// percentage 计算完成百分比，总数为 0 时视为已完成
+func percentage(current int, total int) float32 {
+	if total == 0 {
+		return 100
+	}
+	return float32(current) / float32(total) * 100
+}
+
+// DeleteDocument 删除知识库中的文档及其所有文本块
+// 参数 ragCtx: RAG 上下文
+// 参数 docId: 文档 ID
+// 返回: error，文档不存在时返回 ErrDocumentNotFound，正在建立索引时不等待，返回 ErrIndexInProgress
+func (r *ragManager) DeleteDocument(ragCtx *RagContext, docId string) error {
+	if !ragCtx.writeMu.TryLock() {
+		return ErrIndexInProgress
+	}
+	defer ragCtx.writeMu.Unlock()
+	if _, ok := ragCtx.getDocument(docId); !ok {
+		return fmt.Errorf("%w: %s", ErrDocumentNotFound, docId)
+	}
+	if err := r.chromem.deleteDocument(ragCtx.ragId, docId); err != nil {
+		return err
+	}
+	ragCtx.keywords.removeDocument(docId)
+	ragCtx.removeDocument(docId)
+	return ragCtx.saveManifest()
+}
+
+// ListDocuments 获取知识库中的所有文档
+// 参数 ragCtx: RAG 上下文
+// 返回: 按 ID 排序的文档信息
+func (r *ragManager) ListDocuments(ragCtx *RagContext) []DocumentInfo {
+	return ragCtx.listDocuments()
+}
+
+// Reindex 重新读取知识库中所有文档的源文件并建立索引
+// 内容和嵌入模型未变化的文本块复用已有向量
+// 参数 ragCtx: RAG 上下文
+// 返回: ProgressInfo channel、error
+// 注意：ProgressInfo channel 需要调用者消费
+func (r *ragManager) Reindex(ragCtx *RagContext) (chan ProgressInfo, error) {
+	var sources []DocumentSource
+	for _, doc := range ragCtx.listDocuments() {
+		sources = append(sources, DocumentSource{Id: doc.Id, Source: doc.Source, Origin: doc.Origin})
+	}
+	return r.AddDocuments(ragCtx, sources)
 }
 
 // Query 检索与问题相关的文档
-// 流程：1. 向量相似度召回 2. 相邻块合并 3. LLM 重排序
+// 流程：0. 查询改写和扩展 1. 向量和关键词混合召回 2. 相邻块合并 3. 重排序
 // 参数 ragCtx: RAG 上下文，包含知识库信息
 // 参数 text: 用户问题
-// 参数 rule: 规则配置（当前未使用，保留用于扩展）
+// 参数 history: 最近的对话历史，用于将追问改写为独立的问题，为空时不改写
+// 参数 rule: 规则配置，用于获取查询改写配置和混合检索的权重
 // 返回: string channel、error
 // 注意：返回的 channel 需要调用者消费
-func (r *ragManager) Query(ragCtx *RagContext, text string, rule *rule.Rule) (chan string, error) {
-	// 1. 向量相似度召回：检索最相似的文档块索引
-	indexArr, err := r.chromem.query(ragCtx.ragId, text, retrievalCount)
+func (r *ragManager) Query(ragCtx *RagContext, text string, history string, rule *rule.Rule) (chan string, error) {
+	// 0. 查询改写和扩展：改写后的问题同时用于重排
+	text, queries := r.transformQuery(ragCtx, text, history, queryFromRule(rule))
+
+	// 1. 混合召回：向量相似度检索和 BM25 关键词检索的结果融合，按文档和序号排序
+	refs, err := r.retrieve(ragCtx, queries, weightsFromRule(rule))
 	if err != nil {
 		return nil, err
 	}
-	// 对索引排序，便于后续处理
-	sort.Ints(indexArr)
 
-	// 2. 合并相邻的文档块，保持上下文连贯性
+	// 2. 合并同一文档中相邻的文本块，保持上下文连贯性
 	var textArr []string
-	for i := 0; i < len(indexArr); i++ {
-		index := indexArr[i]
+	for i := 0; i < len(refs); i++ {
+		ref := refs[i]
 		// 如果当前块和前一个块只间隔一个块，将中间块也加入，保证上下文完整
-		if i > 0 && index-indexArr[i-1] == 2 {
-			textArr = append(textArr, ragCtx.chunks[index-1])
+		if i > 0 && refs[i-1].docId == ref.docId && ref.index-refs[i-1].index == 2 {
+			if content, ok := r.chromem.getChunk(ragCtx.ragId, ref.docId, ref.index-1); ok {
+				textArr = append(textArr, content)
+			}
 		}
-		textArr = append(textArr, ragCtx.chunks[index])
+		textArr = append(textArr, ref.content)
 	}
 
-	// 3. 使用 LLM 对候选文档进行重排，选择最相关的文档
+	// 3. 重排：为候选文档的相关性打分，按分数从高到低选择文档原文，丢弃低于阈值的文档
 	chRes := make(chan string)
+	if len(textArr) == 0 {
+		// 知识库为空时没有候选文档
+		close(chRes)
+		return chRes, nil
+	}
+	minScore := minScoreFromRule(rule)
 	go func() {
 		defer close(chRes)
-		result, err := r.reranker.RankCandidate(strings.Join(textArr, "\n"), text, rerankingCount)
+		language := languageOf(ragCtx.options.Language, normalizeText(text))
+		ranked, err := ragCtx.reranker.RankCandidates(textArr, text, rerankingCount, language)
 		if err != nil {
 			// 如果重排失败，返回空结果（调用者需要通过 channel 关闭来判断）
 			return
 		}
-		chRes <- result
+		if selected := selectCandidates(textArr, ranked, rerankingCount, minScore); len(selected) > 0 {
+			chRes <- strings.Join(selected, "\n")
+		}
 	}()
 	return chRes, nil
 }
@@ -0,0 +1,79 @@
+package rag
+
+import (
+	"fmt"
+	"go-ollama/rule"
+	"sort"
+)
+
+// defaultMinScore 重排相关性评分的默认阈值，默认不过滤
+// 不同重排方式的评分尺度不同（LLM 评分换算后的 0-1、余弦相似度），阈值需要按重排方式配置
+const defaultMinScore = 0
+
+// RankedCandidate 重排结果中的一个候选文档
+type RankedCandidate struct {
+	Index int     // 候选文档的序号
+	Score float64 // 相关性评分，范围 0-1，越大越相关
+}
+
+// minScoreFromRule 获取规则配置的重排相关性评分阈值
+// 未配置时使用默认值，负数视为 0（不过滤）
+func minScoreFromRule(rule *rule.Rule) float64 {
+	if rule == nil {
+		return defaultMinScore
+	}
+	if minScore := rule.Retrieval().MinScore; minScore != nil {
+		return max(*minScore, 0)
+	}
+	return defaultMinScore
+}
+
+// rerankerFromOptions 按知识库的重排方式创建重排器
+// 嵌入重排和 MMR 只需要向量化，不调用 LLM，适合对延迟敏感的专家
+// 嵌入重排使用建立索引的模型时只会重新计算一遍向量检索的相似度，所以要求配置不同的重排嵌入模型
+// 返回: 重排器、error（重排方式未知或缺少重排嵌入模型时）
+func (r *ragManager) rerankerFromOptions(options CollectionOptions) (Rerankable, error) {
+	switch options.Reranker {
+	case "", RerankerLlm:
+		return r.reranker, nil
+	case RerankerEmbedding:
+		if r.rerankEmbedder.EmbedModelName() == r.chromem.embedder.EmbedModelName() {
+			return nil, fmt.Errorf("reranker %s requires a rerank embed model other than %s", RerankerEmbedding, r.rerankEmbedder.EmbedModelName())
+		}
+		return &embeddingReranker{embedder: r.rerankEmbedder, normalizer: r.normalizer}, nil
+	case RerankerMmr:
+		lambda := defaultMmrLambda
+		if options.MmrLambda != nil {
+			lambda = min(max(*options.MmrLambda, 0), 1)
+		}
+		return &mmrReranker{embedder: r.rerankEmbedder, normalizer: r.normalizer, lambda: lambda}, nil
+	}
+	return nil, fmt.Errorf("unknown reranker: %s", options.Reranker)
+}
+
+// selectCandidates 按重排结果选择候选文档
+// 按评分从高到低排列，丢弃序号无效、重复和评分低于阈值的结果，返回的是候选文档的原文
+// 参数 candidates: 候选文档
+// 参数 ranked: 重排结果
+// 参数 num: 返回的文档数量上限
+// 参数 minScore: 相关性评分阈值
+// 返回: 选中的文档原文
+func selectCandidates(candidates []string, ranked []RankedCandidate, num int, minScore float64) []string {
+	ranked = append([]RankedCandidate(nil), ranked...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].Score > ranked[j].Score
+	})
+	var selected []string
+	seen := make(map[int]bool)
+	for _, candidate := range ranked {
+		if len(selected) >= num {
+			break
+		}
+		if candidate.Index < 0 || candidate.Index >= len(candidates) || seen[candidate.Index] || candidate.Score < minScore {
+			continue
+		}
+		seen[candidate.Index] = true
+		selected = append(selected, candidates[candidate.Index])
+	}
+	return selected
+}
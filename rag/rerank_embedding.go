@@ -0,0 +1,159 @@
+package rag
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// 重排方式
+const (
+	RerankerLlm       = "llm"       // 使用 LLM 为候选文档打分（默认）
+	RerankerEmbedding = "embedding" // 使用嵌入模型的余弦相似度重新打分，不调用 LLM
+	RerankerMmr       = "mmr"       // 最大边际相关性（MMR），在相关性和多样性之间取舍，减少内容重复的文本块
+)
+
+// defaultMmrLambda MMR 中相关性的默认权重，越大越偏重相关性，越小越偏重多样性
+const defaultMmrLambda = 0.7
+
+// batchEmbeddable 支持批量向量化的 Embeddable，一次请求完成所有文本的向量化
+type batchEmbeddable interface {
+	EmbedTexts(texts []string) ([][]float32, error)
+}
+
+// embedTexts 将多个文本转换为向量，嵌入模型支持批量向量化时只发送一次请求
+func embedTexts(embedder Embeddable, texts []string) ([][]float32, error) {
+	if batch, ok := embedder.(batchEmbeddable); ok {
+		vectors, err := batch.EmbedTexts(texts)
+		if err != nil {
+			return nil, err
+		}
+		if len(vectors) != len(texts) {
+			return nil, fmt.Errorf("embed %d texts, got %d vectors", len(texts), len(vectors))
+		}
+		return vectors, nil
+	}
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vector, err := embedder.EmbedText(text)
+		if err != nil {
+			return nil, err
+		}
+		vectors[i] = vector
+	}
+	return vectors, nil
+}
+
+// embeddingReranker 嵌入重排器，按问题和候选文档的余弦相似度重新打分
+// 可以使用与建立索引不同的嵌入模型，作为第二个模型修正向量检索的排序
+type embeddingReranker struct {
+	embedder   Embeddable      // 重排使用的嵌入模型
+	normalizer *textNormalizer // 文本规范化流程，与检索时保持一致
+}
+
+// RankCandidates 对候选文档进行重排序
+// 参数 candidates: 候选文档
+// 参数 text: 用户问题
+// 参数 num: 返回的文档数量
+// 参数 language: 问题的语言
+// 返回: 按余弦相似度从高到低排列的序号和评分、error
+func (e *embeddingReranker) RankCandidates(candidates []string, text string, num int, language string) ([]RankedCandidate, error) {
+	query, vectors, err := embedCandidates(e.embedder, e.normalizer, candidates, text, language)
+	if err != nil {
+		return nil, err
+	}
+	ranked := make([]RankedCandidate, len(vectors))
+	for i, vector := range vectors {
+		ranked[i] = RankedCandidate{Index: i, Score: relevanceScore(cosineSimilarity(query, vector))}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].Score > ranked[j].Score
+	})
+	if len(ranked) > num {
+		ranked = ranked[:num]
+	}
+	return ranked, nil
+}
+
+// mmrReranker 最大边际相关性（MMR）重排器
+// 每次选择 lambda * 与问题的相似度 - (1 - lambda) * 与已选文档的最大相似度 最高的文档，避免选出内容重复的文本块
+type mmrReranker struct {
+	embedder   Embeddable      // 重排使用的嵌入模型
+	normalizer *textNormalizer // 文本规范化流程，与检索时保持一致
+	lambda     float64         // 相关性的权重，范围 0-1
+}
+
+// RankCandidates 对候选文档进行重排序
+// 参数 candidates: 候选文档
+// 参数 text: 用户问题
+// 参数 num: 返回的文档数量
+// 参数 language: 问题的语言
+// 返回: 按选择顺序排列的序号和评分（评分为与问题的相关性，用于阈值过滤）、error
+func (m *mmrReranker) RankCandidates(candidates []string, text string, num int, language string) ([]RankedCandidate, error) {
+	query, vectors, err := embedCandidates(m.embedder, m.normalizer, candidates, text, language)
+	if err != nil {
+		return nil, err
+	}
+	relevance := make([]float64, len(vectors))
+	for i, vector := range vectors {
+		relevance[i] = cosineSimilarity(query, vector)
+	}
+
+	var ranked []RankedCandidate
+	selected := make([]bool, len(vectors))
+	for len(ranked) < min(num, len(vectors)) {
+		best, bestScore := -1, math.Inf(-1)
+		for i, vector := range vectors {
+			if selected[i] {
+				continue
+			}
+			redundancy := 0.0
+			for _, chosen := range ranked {
+				redundancy = max(redundancy, cosineSimilarity(vector, vectors[chosen.Index]))
+			}
+			if score := m.lambda*relevance[i] - (1-m.lambda)*redundancy; score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+		selected[best] = true
+		ranked = append(ranked, RankedCandidate{Index: best, Score: relevanceScore(relevance[best])})
+	}
+	return ranked, nil
+}
+
+// embedCandidates 对问题和候选文档进行向量化，使用与检索时相同的规范化流程
+// 返回: 问题的向量、候选文档的向量、error
+func embedCandidates(embedder Embeddable, normalizer *textNormalizer, candidates []string, text string, language string) ([]float32, [][]float32, error) {
+	texts := make([]string, 0, len(candidates)+1)
+	texts = append(texts, normalizer.embeddingText(text, language))
+	for _, candidate := range candidates {
+		texts = append(texts, normalizer.embeddingText(candidate, language))
+	}
+	vectors, err := embedTexts(embedder, texts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("embed rerank candidates: %w", err)
+	}
+	return vectors[0], vectors[1:], nil
+}
+
+// cosineSimilarity 计算两个向量的余弦相似度，维度不同或为零向量时返回 0
+func cosineSimilarity(a []float32, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(normA*normB)
+}
+
+// relevanceScore 将余弦相似度换算为 0-1 的相关性评分，负相关视为 0
+func relevanceScore(similarity float64) float64 {
+	return min(max(similarity, 0), 1)
+}
@@ -0,0 +1,144 @@
+package rag
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+type keywordEmbedder struct {
+	vectors map[string][]float32
+}
+
+func (k *keywordEmbedder) EmbedText(text string) ([]float32, error) {
+	for keyword, vector := range k.vectors {
+		if strings.Contains(text, keyword) {
+			return vector, nil
+		}
+	}
+	return []float32{0, 0}, nil
+}
+
+func (k *keywordEmbedder) EmbedModelName() string {
+	return "keyword"
+}
+
+// batchKeywordEmbedder 支持批量向量化的 keywordEmbedder，drop 不为 0 时少返回 drop 个向量
+type batchKeywordEmbedder struct {
+	keywordEmbedder
+	batches int
+	drop    int
+}
+
+func (b *batchKeywordEmbedder) EmbedText(text string) ([]float32, error) {
+	return nil, errors.New("embed one by one")
+}
+
+func (b *batchKeywordEmbedder) EmbedTexts(texts []string) ([][]float32, error) {
+	b.batches++
+	vectors := make([][]float32, 0, len(texts))
+	for _, text := range texts[:len(texts)-b.drop] {
+		vector, _ := b.keywordEmbedder.EmbedText(text)
+		vectors = append(vectors, vector)
+	}
+	return vectors, nil
+}
+
+func TestSelectCandidates(t *testing.T) {
+	candidates := []string{"哈利是一个巫师。", "罗恩是他的朋友。", "斯内普是魔药课教授。"}
+	{ // case order by score and drop below threshold
+		ranked := []RankedCandidate{{Index: 0, Score: 0.2}, {Index: 2, Score: 0.9}, {Index: 1, Score: 0.5}}
+		selected := selectCandidates(candidates, ranked, 5, 0.3)
+		if !slices.Equal(selected, []string{"斯内普是魔药课教授。", "罗恩是他的朋友。"}) {
+			t.Fatalf("unexpected selected: %q", selected)
+		}
+	}
+	{ // case invalid, duplicated and limited
+		ranked := []RankedCandidate{{Index: 5, Score: 1}, {Index: 1, Score: 0.8}, {Index: 1, Score: 0.7}, {Index: 0, Score: 0.6}, {Index: 2, Score: 0.5}}
+		selected := selectCandidates(candidates, ranked, 2, 0)
+		if !slices.Equal(selected, []string{"罗恩是他的朋友。", "哈利是一个巫师。"}) {
+			t.Fatalf("unexpected selected: %q", selected)
+		}
+	}
+	{ // case default threshold
+		if minScoreFromRule(nil) != defaultMinScore {
+			t.Fatal("expected default min score")
+		}
+	}
+}
+
+func TestEmbeddingRerankers(t *testing.T) {
+	normalizer := newTextNormalizer(newGseManager())
+	embedder := &keywordEmbedder{vectors: map[string][]float32{"哈利": {1, 0.1}, "斯内普": {0.6, 0.8}}}
+	candidates := []string{"斯内普是教授。", "哈利是巫师。", "哈利是巫师！"}
+
+	{ // case cosine
+		reranker := &embeddingReranker{embedder: embedder, normalizer: normalizer}
+		ranked, err := reranker.RankCandidates(candidates, "哈利", 2, "")
+		if err != nil || len(ranked) != 2 || ranked[0].Index != 1 || ranked[1].Index != 2 {
+			t.Fatalf("unexpected ranked: %+v %v", ranked, err)
+		}
+		if ranked[0].Score <= 0.9 || ranked[0].Score > 1 {
+			t.Fatalf("unexpected score: %+v", ranked)
+		}
+	}
+	{ // case mmr skips near-duplicate
+		reranker := &mmrReranker{embedder: embedder, normalizer: normalizer, lambda: 0.3}
+		ranked, err := reranker.RankCandidates(candidates, "哈利", 2, "")
+		if err != nil || len(ranked) != 2 || ranked[0].Index != 1 || ranked[1].Index != 0 {
+			t.Fatalf("unexpected ranked: %+v %v", ranked, err)
+		}
+	}
+	{ // case batch embedding in one request
+		batch := &batchKeywordEmbedder{keywordEmbedder: *embedder}
+		reranker := &embeddingReranker{embedder: batch, normalizer: normalizer}
+		ranked, err := reranker.RankCandidates(candidates, "哈利", 2, "")
+		if err != nil || len(ranked) != 2 || ranked[0].Index != 1 || batch.batches != 1 {
+			t.Fatalf("unexpected ranked: %+v %v %d", ranked, err, batch.batches)
+		}
+	}
+	{ // case batch returns fewer vectors
+		batch := &batchKeywordEmbedder{keywordEmbedder: *embedder, drop: 1}
+		reranker := &embeddingReranker{embedder: batch, normalizer: normalizer}
+		if _, err := reranker.RankCandidates(candidates, "哈利", 2, ""); err == nil || !strings.Contains(err.Error(), "got 3 vectors") {
+			t.Fatal("expected vector count error", err)
+		}
+	}
+}
+
+func TestRerankerFromOptions(t *testing.T) {
+	r, err := newRagManager(nil, nil, &fakeEmbedder{model: "m1"}, &keywordEmbedder{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	{ // case mmr lambda clamped
+		reranker, err := r.rerankerFromOptions(CollectionOptions{Reranker: RerankerMmr, MmrLambda: new(float64)})
+		if mmr, ok := reranker.(*mmrReranker); err != nil || !ok || mmr.lambda != 0 {
+			t.Fatal("unexpected reranker", reranker, err)
+		}
+	}
+	{ // case embedding with a separate model
+		if _, err := r.rerankerFromOptions(CollectionOptions{Reranker: RerankerEmbedding}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	{ // case embedding with the index model
+		same, err := newRagManager(nil, nil, &fakeEmbedder{model: "m1"}, nil, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := same.rerankerFromOptions(CollectionOptions{Reranker: RerankerEmbedding}); err == nil {
+			t.Fatal("expected rerank embed model error")
+		}
+		if _, err := same.OpenCollection("hp", CollectionOptions{Reranker: RerankerEmbedding}); err == nil {
+			t.Fatal("expected open collection error")
+		}
+	}
+	{ // case unknown reranker fails when opening the collection
+		if _, err := r.OpenCollection("hp", CollectionOptions{Reranker: "bm25"}); err == nil || !strings.Contains(err.Error(), "unknown reranker") {
+			t.Fatal("expected unknown reranker", err)
+		}
+	}
+}
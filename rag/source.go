@@ -0,0 +1,143 @@
+package rag
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// 文档来源
+const (
+	// OriginConfig 规则配置的源文件，配置中移除后重建索引时删除
+	OriginConfig = "config"
+	// OriginUpload 通过接口上传的文档，只能通过接口删除
+	OriginUpload = "upload"
+)
+
+// SourceDocuments 展开规则配置的源文件路径和通配符，生成待添加的文档
+// 文档 ID 使用文件名，文件名已被其他文档（包括排在前面的同名文件）占用时追加路径哈希以区分
+// 已在知识库中的源文件沿用原有的 ID，新增同名文件不会改变已有文档的 ID，避免重新向量化
+// 参数 patterns: 源文件路径或通配符，** 匹配任意层目录，如 ./docs/**/*.md
+// 参数 existing: 知识库中已有的文档
+// 返回: 按路径排序的文档、error
+func SourceDocuments(patterns []string, existing []DocumentInfo) ([]DocumentSource, error) {
+	seen := make(map[string]bool)
+	var files []string
+	for _, pattern := range patterns {
+		matches, err := globFiles(pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range matches {
+			if !seen[file] {
+				seen[file] = true
+				files = append(files, file)
+			}
+		}
+	}
+	sort.Strings(files)
+
+	// 仍存在的配置文档沿用原有 ID，上传的文档 ID 不能被占用
+	ids := make(map[string]string)
+	used := make(map[string]bool)
+	for _, doc := range existing {
+		switch {
+		case doc.Origin != OriginConfig:
+			used[doc.Id] = true
+		case seen[doc.Source]:
+			ids[doc.Source] = doc.Id
+			used[doc.Id] = true
+		}
+	}
+	var sources []DocumentSource
+	for _, file := range files {
+		id, ok := ids[file]
+		if !ok {
+			id = filepath.Base(file)
+			if used[id] {
+				id += "~" + hashText(filepath.ToSlash(file))[:8]
+			}
+			used[id] = true
+		}
+		sources = append(sources, DocumentSource{Id: id, Source: file, Origin: OriginConfig})
+	}
+	return sources, nil
+}
+
+// globFiles 展开路径中的通配符，只返回文件
+// 不含通配符的路径原样返回，文件不存在时在读取时报错
+// 参数 pattern: 文件路径或通配符
+// 返回: 匹配的文件路径、error
+func globFiles(pattern string) ([]string, error) {
+	if !strings.ContainsAny(pattern, "*?[") {
+		return []string{filepath.Clean(pattern)}, nil
+	}
+	pattern = filepath.ToSlash(filepath.Clean(pattern))
+	if !strings.Contains(pattern, "**") {
+		matches, err := filepath.Glob(filepath.FromSlash(pattern))
+		if err != nil {
+			return nil, err
+		}
+		return onlyFiles(matches), nil
+	}
+
+	// ** 之前的部分作为遍历的根目录，之后的部分逐级匹配
+	index := strings.Index(pattern, "**")
+	root := strings.TrimSuffix(pattern[:index], "/")
+	if root == "" {
+		root = "."
+	}
+	segments := strings.Split(pattern[index:], "/")
+	if _, err := filepath.Match(pattern[index:], ""); err != nil {
+		return nil, err
+	}
+
+	var matches []string
+	err := filepath.WalkDir(filepath.FromSlash(root), func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(filepath.FromSlash(root), path)
+		if err != nil {
+			return err
+		}
+		if matchSegments(segments, strings.Split(filepath.ToSlash(rel), "/")) {
+			matches = append(matches, path)
+		}
+		return nil
+	})
+	return matches, err
+}
+
+// matchSegments 逐级匹配路径，** 匹配零或多级目录
+func matchSegments(pattern []string, path []string) bool {
+	if len(pattern) == 0 {
+		return len(path) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(path); i++ {
+			if matchSegments(pattern[1:], path[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(path) == 0 {
+		return false
+	}
+	ok, _ := filepath.Match(pattern[0], path[0])
+	return ok && matchSegments(pattern[1:], path[1:])
+}
+
+// onlyFiles 过滤掉目录
+func onlyFiles(paths []string) []string {
+	var files []string
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			files = append(files, path)
+		}
+	}
+	return files
+}
@@ -0,0 +1,113 @@
+package rag
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 递归切分时依次使用的切分方法，从粗到细
+var recursiveSplitters = []func(text string) []string{
+	func(text string) []string { return splitAfterAny(text, "\n") },
+	splitSentences,
+	func(text string) []string { return splitAfterAny(text, "，,、：:") },
+	func(text string) []string { return splitAfterAny(text, " \t") },
+}
+
+// sentenceEnds 中文句末标点和英文的 !?;
+const sentenceEnds = "。！？；…!?;"
+
+// sentenceClosers 句末标点之后属于同一句的引号和括号
+const sentenceClosers = "”’\"')）」』】》"
+
+// splitRecursive 递归切分文本，使每一部分不超过最大字符数
+// 依次尝试换行、句子、分句、空格，仍然过长时按字符数切分
+// 参数 text: 文本
+// 参数 maxSize: 最大字符数
+// 参数 level: 当前使用的切分方法
+// 返回: 切分结果，按顺序连接后等于原文本
+func splitRecursive(text string, maxSize int, level int) []string {
+	if utf8.RuneCountInString(text) <= maxSize {
+		return []string{text}
+	}
+	if level >= len(recursiveSplitters) {
+		return splitRunes(text, maxSize)
+	}
+	parts := recursiveSplitters[level](text)
+	if len(parts) <= 1 {
+		return splitRecursive(text, maxSize, level+1)
+	}
+	var out []string
+	for _, part := range parts {
+		out = append(out, splitRecursive(part, maxSize, level+1)...)
+	}
+	return out
+}
+
+// splitSentences 按中英文句末标点切分句子，标点、其后的引号和空白属于前一句
+// 英文句号只有后面是空白或文本结尾时才作为句末，避免切分小数和缩写
+// 返回: 句子数组，按顺序连接后等于原文本
+func splitSentences(text string) []string {
+	var sentences []string
+	start := 0
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		i += size
+		if r == '.' {
+			next, _ := utf8.DecodeRuneInString(text[i:])
+			if i < len(text) && !unicode.IsSpace(next) {
+				continue
+			}
+		} else if r != '\n' && !strings.ContainsRune(sentenceEnds, r) {
+			continue
+		}
+		// 连续的标点、引号和空白属于同一句
+		for i < len(text) {
+			next, size := utf8.DecodeRuneInString(text[i:])
+			if next != '.' && !unicode.IsSpace(next) && !strings.ContainsRune(sentenceEnds+sentenceClosers, next) {
+				break
+			}
+			i += size
+		}
+		sentences = append(sentences, text[start:i])
+		start = i
+	}
+	if start < len(text) {
+		sentences = append(sentences, text[start:])
+	}
+	return sentences
+}
+
+// splitAfterAny 在任意一个分隔字符之后切分文本，分隔字符属于前一部分
+func splitAfterAny(text string, separators string) []string {
+	var parts []string
+	start := 0
+	for i, r := range text {
+		if strings.ContainsRune(separators, r) {
+			end := i + utf8.RuneLen(r)
+			parts = append(parts, text[start:end])
+			start = end
+		}
+	}
+	if start < len(text) {
+		parts = append(parts, text[start:])
+	}
+	return parts
+}
+
+// splitRunes 按字符数切分文本
+func splitRunes(text string, size int) []string {
+	var parts []string
+	start, count := 0, 0
+	for i := range text {
+		if count == size {
+			parts = append(parts, text[start:i])
+			start, count = i, 0
+		}
+		count++
+	}
+	if start < len(text) {
+		parts = append(parts, text[start:])
+	}
+	return parts
+}
@@ -0,0 +1,48 @@
+package rag
+
+import "strings"
+
+// traditionalChars 常用繁体字到简体字的映射，只包含一对一转换的字，不处理一简对多繁的词语级转换
+var traditionalChars = func() map[rune]rune {
+	chars := make(map[rune]rune)
+	for _, pair := range strings.Fields(traditionalPairs) {
+		runes := []rune(pair)
+		if len(runes) == 2 {
+			chars[runes[0]] = runes[1]
+		}
+	}
+	return chars
+}()
+
+// traditionalPairs 繁简对照表，每项为 "繁简"
+const traditionalPairs = `
+萬万 與与 專专 業业 叢丛 東东 絲丝 丟丢 兩两 嚴严 喪丧 個个 豐丰 臨临 為为 麗丽 舉举 麼么 義义 烏乌
+樂乐 喬乔 習习 鄉乡 書书 買买 亂乱 爭争 虧亏 雲云 亞亚 產产 親亲 億亿 僅仅 從从 倉仓 儀仪 們们 價价
+眾众 優优 會会 傘伞 偉伟 傳传 傷伤 倫伦 偽伪 體体 傭佣 俠侠 侶侣 偵侦 側侧 僑侨 儲储 兒儿 黨党 蘭兰
+關关 興兴 養养 獸兽 內内 冊册 寫写 軍军 農农 馮冯 決决 況况 凍冻 淨净 涼凉 減减 幾几 鳳凤 憑凭 凱凯
+擊击 劉刘 則则 剛刚 創创 刪删 別别 劇剧 勸劝 辦办 務务 動动 勵励 勞劳 勢势 匯汇 區区 醫医 華华 協协
+單单 賣卖 衛卫 卻却 廠厂 廳厅 歷历 曆历 厲厉 壓压 縣县 參参 雙双 變变 號号 嚇吓 嗎吗 啟启 吳吴 員员
+聽听 團团 園园 圍围 國国 圖图 圓圆 聖圣 場场 壞坏 塊块 堅坚 壇坛 墳坟 處处 備备 夠够 頭头 奪夺 奮奋
+婦妇 媽妈 孫孙 學学 寧宁 寶宝 實实 審审 憲宪 寬宽 賓宾 對对 尋寻 導导 將将 爾尔 塵尘 嘗尝 層层 屬属
+島岛 峽峡 嶺岭 幣币 師师 帳帐 帶带 幫帮 廣广 莊庄 慶庆 庫库 應应 廟庙 廢废 開开 棄弃 張张 彎弯 歸归
+當当 錄录 徹彻 恥耻 悅悦 懸悬 戀恋 惡恶 惱恼 愛爱 態态 憤愤 憶忆 懷怀 懶懒 戰战 戲戏 戶户 執执 擴扩
+掃扫 揚扬 擾扰 撫抚 搶抢 護护 報报 擔担 擬拟 擁拥 擇择 掛挂 擠挤 揮挥 損损 換换 據据 攜携 擺摆 擋挡
+數数 斷断 時时 晉晋 曬晒 暫暂 曉晓 術术 條条 來来 楊杨 極极 構构 槍枪 標标 樓楼 樣样 樹树 橋桥 機机
+權权 檢检 檔档 歐欧 歡欢 歲岁 殘残 殺杀 殼壳 毀毁 氣气 漢汉 湯汤 溝沟 沒没 滅灭 溫温 測测 濕湿 灣湾
+滬沪 漲涨 潔洁 濟济 濤涛 濃浓 澤泽 灑洒 災灾 燈灯 爐炉 點点 煉炼 爛烂 熱热 營营 燒烧 爺爷 牆墙 牽牵
+狀状 猶犹 獨独 獄狱 獵猎 獲获 獻献 現现 環环 畫画 暢畅 電电 畢毕 療疗 發发 盜盗 監监 盤盘 盡尽 礦矿
+碼码 確确 禮礼 禍祸 離离 種种 稱称 積积 穩稳 窮穷 競竞 筆笔 節节 築筑 簡简 簽签 類类 糧粮 紀纪 約约
+紅红 納纳 純纯 紙纸 級级 細细 終终 組组 結结 絕绝 給给 統统 經经 綠绿 維维 網网 緊紧 線线 練练 續续
+總总 績绩 織织 縮缩 繩绳 繪绘 罰罚 羅罗 聲声 聯联 聰聪 職职 腦脑 臉脸 臟脏 膽胆 藝艺 蘇苏 葉叶 薦荐
+藥药 蓋盖 虛虚 蟲虫 補补 裝装 襲袭 見见 規规 視视 覽览 覺觉 觀观 觸触 計计 訂订 認认 討讨 讓让 訓训
+議议 記记 講讲 許许 論论 設设 訪访 證证 評评 識识 詞词 試试 詩诗 話话 誠诚 該该 詳详 語语 誤误 說说
+請请 讀读 課课 誰谁 調调 談谈 謝谢 謹谨 譯译 讚赞 貝贝 負负 財财 貢贡 貧贫 貨货 販贩 貪贪 責责 貴贵
+費费 貼贴 賀贺 資资 賊贼 賞赏 賠赔 賢贤 賴赖 贈赠 贊赞 趕赶 趙赵 跡迹 蹤踪 躍跃 車车 軌轨 軟软 較较
+載载 輔辅 輕轻 輛辆 輝辉 輩辈 輪轮 輸输 轉转 辭辞 這这 連连 進进 遊游 運运 過过 達达 違违 遙遥 遞递
+適适 遲迟 遷迁 選选 遺遗 邊边 鄭郑 鄰邻 鄧邓 醜丑 釋释 針针 銀银 銅铜 鋼钢 錢钱 錯错 鍋锅 鍵键 鎖锁
+鏡镜 鐘钟 鐵铁 長长 門门 閃闪 閉闭 問问 間间 悶闷 閱阅 闊阔 陽阳 陰阴 陣阵 階阶 際际 陸陆 陳陈 險险
+隨随 隱隐 雖虽 雜杂 難难 雞鸡 霧雾 靈灵 靜静 響响 頁页 頂顶 項项 順顺 須须 預预 頓顿 領领 頻频 題题
+額额 顏颜 願愿 顧顾 顯显 風风 飛飞 飯饭 飲饮 飽饱 餓饿 館馆 馬马 駕驾 驗验 騎骑 驚惊 騙骗 驅驱 鬥斗
+鬧闹 魚鱼 魯鲁 鮮鲜 鳥鸟 鳴鸣 鴨鸭 鹽盐 麥麦 黃黄 齊齐 齒齿 龍龙 龜龟 後后 裏里 衝冲 麵面 無无 盧卢
+夢梦 於于 隻只 週周 製制 髮发 鬆松 彙汇 臺台 颱台 僕仆 係系 繫系 還还 榮荣 萊莱
+`
@@ -10,13 +10,81 @@ import (
 // RuleConfig 单个规则的配置结构
 // 对应 YAML 配置文件中 rules 下的单个规则
 type RuleConfig struct {
-	Introduction          string `yaml:"introduction"`           // 专家介绍，用于协调者匹配
-	SystemMessage         string `yaml:"system_message"`         // 专家系统提示词
-	SourceFile            string `yaml:"source_file"`            // RAG 源文件路径
-	SourceMessage         string `yaml:"source_message"`         // RAG 检索文档的提示词模板
-	ReviewerSystemMessage string `yaml:"reviewer_system_message"`// 评审者系统提示词
-	ReviewMessage         string `yaml:"review_message"`         // 评审提示词模板
-	RewriteMessage        string `yaml:"rewrite_message"`        // 重写提示词模板
+	Introduction          string   `yaml:"introduction"`            // 专家介绍，用于协调者匹配
+	Model                 string   `yaml:"model"`                   // 专家使用的模型，覆盖全局配置
+	Language              string   `yaml:"language"`                // 专家的语言：zh、en 或 auto（默认），决定知识库的预处理方式和使用的提示词模板
+	ReviewerModel         string   `yaml:"reviewer_model"`          // 评审者使用的模型，覆盖全局配置
+	SystemMessage         string   `yaml:"system_message"`          // 专家系统提示词
+	SourceFile            string   `yaml:"source_file"`             // RAG 源文件路径
+	SourceFiles           []string `yaml:"source_files"`            // RAG 源文件路径列表，支持通配符，** 匹配任意层目录
+	SourceMessage         string   `yaml:"source_message"`          // RAG 检索文档的提示词模板
+	ReviewerSystemMessage string   `yaml:"reviewer_system_message"` // 评审者系统提示词
+	ReviewMessage         string   `yaml:"review_message"`          // 评审提示词模板
+	RewriteMessage        string   `yaml:"rewrite_message"`         // 重写提示词模板
+	Tools                 []string `yaml:"tools"`                   // 专家可以调用的工具名称
+	McpServers            []string `yaml:"mcp_servers"`             // 专家可以使用的 MCP 服务名称
+
+	ContextWindow ContextWindowConfig `yaml:"context_window"` // 上下文窗口配置
+	Options       OptionsConfig       `yaml:"options"`        // 模型生成参数，覆盖全局配置
+	Loader        LoaderConfig        `yaml:"loader"`         // 知识库文档加载器配置
+	Chunker       ChunkerConfig       `yaml:"chunker"`        // 知识库分块策略配置
+	Retrieval     RetrievalConfig     `yaml:"retrieval"`      // 知识库检索配置
+	Query         QueryConfig         `yaml:"query"`          // 检索前的查询改写和扩展配置
+}
+
+// OptionsConfig 模型生成参数配置
+// 未配置的项使用全局配置，全局也未配置时使用模型自身的默认值
+type OptionsConfig struct {
+	Temperature *float64 `yaml:"temperature"` // 采样温度，越高回答越发散
+	NumCtx      *int     `yaml:"num_ctx"`     // 上下文长度（token 数）
+	TopP        *float64 `yaml:"top_p"`       // 核采样概率阈值
+	Seed        *int     `yaml:"seed"`        // 随机种子，固定后相同输入得到相同输出
+	Stop        []string `yaml:"stop"`        // 停止词
+	KeepAlive   string   `yaml:"keep_alive"`  // 模型驻留时间，如 "10m"，负数表示常驻
+}
+
+// ContextWindowConfig 上下文窗口配置
+// 控制发送给 LLM 的对话历史长度，未配置时使用默认值
+type ContextWindowConfig struct {
+	MaxTokens int    `yaml:"max_tokens"` // token 预算，0 表示使用默认值，负数表示不限制
+	Strategy  string `yaml:"strategy"`   // 裁剪策略：drop_oldest（丢弃最早轮次）或 keep_last_n（保留最近 N 轮）
+	KeepTurns int    `yaml:"keep_turns"` // keep_last_n 策略保留的对话轮数
+	Summarize bool   `yaml:"summarize"`  // 是否将被裁剪的历史压缩为滚动摘要
+}
+
+// LoaderConfig 知识库文档加载器配置
+// 未配置时按文件扩展名选择加载器
+type LoaderConfig struct {
+	Type       string   `yaml:"type"`        // 加载器类型：text、markdown、html、csv、json、pdf 或 docx
+	TextFields []string `yaml:"text_fields"` // JSON 记录中用于检索的字段，支持 a.b 形式的嵌套字段，为空时使用所有字段
+}
+
+// ChunkerConfig 知识库分块策略配置
+// 字符数按 Unicode 字符计算，未配置的项使用默认值
+type ChunkerConfig struct {
+	Type    string `yaml:"type"`     // 分块策略：paragraph（默认）、fixed、sentence 或 recursive
+	MinSize int    `yaml:"min_size"` // 文本块的最小字符数
+	MaxSize int    `yaml:"max_size"` // 文本块的最大字符数
+	Overlap int    `yaml:"overlap"`  // 相邻文本块重叠的字符数，必须小于最大字符数
+}
+
+// RetrievalConfig 知识库混合检索配置
+// 向量检索和 BM25 关键词检索的结果按权重进行倒数排名融合，未配置的权重为 1
+type RetrievalConfig struct {
+	VectorWeight  *float64 `yaml:"vector_weight"`  // 向量检索的权重，0 表示只使用关键词检索
+	KeywordWeight *float64 `yaml:"keyword_weight"` // 关键词检索的权重，0 表示只使用向量检索
+	MinScore      *float64 `yaml:"min_score"`      // 重排相关性评分（0-1）的阈值，低于阈值的文本块被丢弃，未配置或为 0 时不过滤
+	Reranker      string   `yaml:"reranker"`       // 重排方式：llm（默认）、embedding（嵌入模型的余弦相似度）或 mmr（最大边际相关性）
+	MmrLambda     *float64 `yaml:"mmr_lambda"`     // MMR 中相关性的权重（0-1），越小越偏重多样性，未配置时为 0.7
+}
+
+// QueryConfig 检索前的查询改写和扩展配置
+// 每一项都需要额外调用一次 LLM，默认都不开启
+type QueryConfig struct {
+	Rewrite      bool `yaml:"rewrite"`       // 是否结合对话历史将追问改写为独立的问题
+	HistoryTurns int  `yaml:"history_turns"` // 改写时参考的对话轮数，0 表示使用默认值
+	Expand       int  `yaml:"expand"`        // 多查询扩展生成的不同表述数量，0 表示不扩展
+	Hyde         bool `yaml:"hyde"`          // 是否生成假设性回答，用回答的向量进行检索（HyDE）
 }
 
 // ChatConfig 完整的配置结构
@@ -28,6 +96,52 @@ type ChatConfig struct {
 	RerankMessage                string `yaml:"rerank_message"`                 // 重排提示词模板
 	CoordinatorMessage           string `yaml:"coordinator_message"`            // 协调者提示词模板
 	CoordinatorSpecialistMessage string `yaml:"coordinator_specialist_message"` // 协调者专家信息提示词模板
+	SummaryMessage               string `yaml:"summary_message"`                // 历史摘要提示词模板
+	SummaryContextMessage        string `yaml:"summary_context_message"`        // 注入上下文的摘要消息模板
+	QueryRewriteMessage          string `yaml:"query_rewrite_message"`          // 查询改写提示词模板
+	QueryExpandMessage           string `yaml:"query_expand_message"`           // 多查询扩展提示词模板
+	HydeMessage                  string `yaml:"hyde_message"`                   // 假设性回答提示词模板
+
+	Languages  map[string]MessagesConfig  `yaml:"languages"`   // 按语言覆盖的提示词模板，key 是语言（如 en）
+	McpServers map[string]McpServerConfig `yaml:"mcp_servers"` // MCP 服务字典，key 是服务名称
+	Options    OptionsConfig              `yaml:"options"`     // 全局默认的模型生成参数
+	Models     ModelsConfig               `yaml:"models"`      // 各角色使用的模型
+}
+
+// MessagesConfig 按语言覆盖的提示词模板
+// 未配置的模板使用全局配置
+type MessagesConfig struct {
+	RerankMessage         string `yaml:"rerank_message"`          // 重排提示词模板
+	SummaryMessage        string `yaml:"summary_message"`         // 历史摘要提示词模板
+	SummaryContextMessage string `yaml:"summary_context_message"` // 注入上下文的摘要消息模板
+	SourceMessage         string `yaml:"source_message"`          // RAG 检索文档的提示词模板，规则未配置时使用
+	QueryRewriteMessage   string `yaml:"query_rewrite_message"`   // 查询改写提示词模板
+	QueryExpandMessage    string `yaml:"query_expand_message"`    // 多查询扩展提示词模板
+	HydeMessage           string `yaml:"hyde_message"`            // 假设性回答提示词模板
+}
+
+// ModelsConfig 各角色使用的模型配置
+// 模型名称可以是完整名称（如 "qwen3:8b"）或关键词（如 "qwen"），启动时按可用模型解析
+// 专家和评审者的回退顺序：规则配置 -> 角色配置 -> default
+type ModelsConfig struct {
+	Default     string `yaml:"default"`      // 默认模型，角色未配置或不可用时使用
+	Specialist  string `yaml:"specialist"`   // 专家模型
+	Reviewer    string `yaml:"reviewer"`     // 评审者模型
+	Coordinator string `yaml:"coordinator"`  // 协调者模型
+	Reranker    string `yaml:"reranker"`     // 重排器模型
+	Rewriter    string `yaml:"rewriter"`     // 查询改写模型，未配置时使用重排器模型
+	Embed       string `yaml:"embed"`        // 嵌入模型，用于知识库向量化，未配置时使用名称包含 embed 的可用模型
+	RerankEmbed string `yaml:"rerank_embed"` // 嵌入重排和 MMR 使用的嵌入模型，嵌入重排必须配置与 embed 不同的模型，MMR 未配置时使用 embed
+}
+
+// McpServerConfig MCP 服务配置
+// 配置 command 时以 stdio 方式启动本地服务，配置 url 时以 Streamable HTTP 方式连接远程服务
+type McpServerConfig struct {
+	Command string            `yaml:"command"` // 本地服务可执行文件
+	Args    []string          `yaml:"args"`    // 本地服务命令行参数
+	Env     []string          `yaml:"env"`     // 本地服务额外的环境变量（KEY=VALUE）
+	Url     string            `yaml:"url"`     // 远程服务地址
+	Headers map[string]string `yaml:"headers"` // 远程服务额外的请求头
 }
 
 // readConfig 从 YAML 文件读取配置
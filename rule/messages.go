@@ -0,0 +1,88 @@
+package rule
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Messages 指定语言的提示词模板
+// 语言中配置的模板覆盖全局配置，未配置的模板使用全局配置
+type Messages struct {
+	config MessagesConfig // 合并后的提示词模板
+}
+
+// Messages 获取指定语言的提示词模板
+// 参数 language: 语言，为空或未配置该语言时使用全局配置
+// 返回: Messages
+func (r *ruleManager) Messages(language string) Messages {
+	override := r.config.Languages[language]
+	config := MessagesConfig{
+		RerankMessage:         firstNonEmpty(override.RerankMessage, r.config.RerankMessage),
+		SummaryMessage:        firstNonEmpty(override.SummaryMessage, r.config.SummaryMessage),
+		SummaryContextMessage: firstNonEmpty(override.SummaryContextMessage, r.config.SummaryContextMessage),
+		SourceMessage:         override.SourceMessage,
+		QueryRewriteMessage:   firstNonEmpty(override.QueryRewriteMessage, r.config.QueryRewriteMessage),
+		QueryExpandMessage:    firstNonEmpty(override.QueryExpandMessage, r.config.QueryExpandMessage),
+		HydeMessage:           firstNonEmpty(override.HydeMessage, r.config.HydeMessage),
+	}
+	return Messages{config: config}
+}
+
+// RerankMessage 构建重排序提示词
+// 替换模板中的占位符（{question}, {number}, {candidates}）
+func (m Messages) RerankMessage(candidates string, question string, number int) string {
+	replacer := strings.NewReplacer(
+		"{question}", question,
+		"{number}", strconv.Itoa(number),
+		"{candidates}", candidates,
+	)
+	return replacer.Replace(m.config.RerankMessage)
+}
+
+// SummaryMessage 构建历史摘要提示词
+// 替换模板中的占位符（{summary}, {history}）
+func (m Messages) SummaryMessage(summary string, history string) string {
+	replacer := strings.NewReplacer(
+		"{summary}", summary,
+		"{history}", history,
+	)
+	return replacer.Replace(m.config.SummaryMessage)
+}
+
+// SummaryContextMessage 构建注入上下文的摘要消息
+// 替换模板中的占位符（{summary}）
+func (m Messages) SummaryContextMessage(summary string) string {
+	replacer := strings.NewReplacer(
+		"{summary}", summary,
+	)
+	return replacer.Replace(m.config.SummaryContextMessage)
+}
+
+// QueryRewriteMessage 构建查询改写提示词
+// 替换模板中的占位符（{history}, {question}）
+func (m Messages) QueryRewriteMessage(history string, question string) string {
+	replacer := strings.NewReplacer(
+		"{history}", history,
+		"{question}", question,
+	)
+	return replacer.Replace(m.config.QueryRewriteMessage)
+}
+
+// QueryExpandMessage 构建多查询扩展提示词
+// 替换模板中的占位符（{question}, {number}）
+func (m Messages) QueryExpandMessage(question string, number int) string {
+	replacer := strings.NewReplacer(
+		"{question}", question,
+		"{number}", strconv.Itoa(number),
+	)
+	return replacer.Replace(m.config.QueryExpandMessage)
+}
+
+// HydeMessage 构建假设性回答提示词
+// 替换模板中的占位符（{question}）
+func (m Messages) HydeMessage(question string) string {
+	replacer := strings.NewReplacer(
+		"{question}", question,
+	)
+	return replacer.Replace(m.config.HydeMessage)
+}
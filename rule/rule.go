@@ -12,9 +12,12 @@ import (
 type RuleManager interface {
 	GetGeneralRule() *Rule
 	GetAllRules() []*Rule
-	RerankMessage(candidates string, question string, number int) string
 	CoordinatorMessage(question string) string
 	CoordinatorSpecialistMessage(name string, introduction string) string
+	Messages(language string) Messages
+	McpServers() map[string]McpServerConfig
+	Options() OptionsConfig
+	Models() ModelsConfig
 }
 
 // ruleManager 规则管理器实现（包私有）
@@ -49,11 +52,11 @@ func newRuleManager() (*ruleManager, error) {
 		return nil, err
 	}
 
-	ruleMap := make(map[string]*Rule)
+	manager := &ruleManager{ruleMap: make(map[string]*Rule), config: config}
 	for name, ruleCfg := range config.Rules {
-		ruleMap[name] = &Rule{name: name, config: &ruleCfg}
+		manager.ruleMap[name] = &Rule{name: name, config: &ruleCfg, messages: manager.Messages(ruleCfg.Language)}
 	}
-	return &ruleManager{ruleMap: ruleMap, config: config}, nil
+	return manager, nil
 }
 
 // StartRuleManager 获取规则管理器单例
@@ -64,7 +67,7 @@ func StartRuleManager() (RuleManager, error) {
 	ruleOnce.Do(func() {
 		ruleInstance, err = newRuleManager()
 	})
-	
+
 	if err != nil {
 		return nil, err
 	}
@@ -87,17 +90,6 @@ func (r *ruleManager) GetAllRules() []*Rule {
 	return rules
 }
 
-// RerankMessage 构建重排序提示词
-// 替换模板中的占位符（{question}, {number}, {candidates}）
-func (r *ruleManager) RerankMessage(candidates string, question string, number int) string {
-	replacer := strings.NewReplacer(
-		"{question}", question,
-		"{number}", strconv.Itoa(number),
-		"{candidates}", candidates,
-	)
-	return replacer.Replace(r.config.RerankMessage)
-}
-
 // CoordinatorMessage 构建协调者提示词
 // 替换模板中的占位符（{question}）
 func (r *ruleManager) CoordinatorMessage(question string) string {
@@ -117,11 +109,27 @@ func (r *ruleManager) CoordinatorSpecialistMessage(name string, introduction str
 	return replacer.Replace(r.config.CoordinatorSpecialistMessage)
 }
 
+// McpServers 获取所有 MCP 服务配置
+func (r *ruleManager) McpServers() map[string]McpServerConfig {
+	return r.config.McpServers
+}
+
+// Options 获取全局默认的模型生成参数
+func (r *ruleManager) Options() OptionsConfig {
+	return r.config.Options
+}
+
+// Models 获取各角色使用的模型配置
+func (r *ruleManager) Models() ModelsConfig {
+	return r.config.Models
+}
+
 // Rule 单个规则配置
 // 包含一个专家 Agent 或评审者的所有配置信息
 type Rule struct {
-	name   string      // 规则名称（也是专家名称）
-	config *RuleConfig // 规则配置对象
+	name     string      // 规则名称（也是专家名称）
+	config   *RuleConfig // 规则配置对象
+	messages Messages    // 规则语言的提示词模板
 }
 
 // Name 获取规则名称
@@ -129,6 +137,14 @@ func (r *Rule) Name() string {
 	return r.name
 }
 
+// Language 获取专家的语言，未配置时返回空字符串，表示自动检测
+func (r *Rule) Language() string {
+	if r.config == nil {
+		return ""
+	}
+	return r.config.Language
+}
+
 // Introduction 获取专家介绍
 // 用于协调者匹配问题
 func (r *Rule) Introduction() string {
@@ -153,7 +169,7 @@ func (r *Rule) NeedRag() bool {
 	if r.config == nil {
 		return false
 	}
-	return r.SourceFile() != ""
+	return len(r.SourceFiles()) > 0
 }
 
 // SourceFile 获取 RAG 源文件路径
@@ -164,17 +180,72 @@ func (r *Rule) SourceFile() string {
 	return r.config.SourceFile
 }
 
+// SourceFiles 获取 RAG 源文件路径列表
+// 包括 source_file 和 source_files 中配置的路径和通配符，通配符在建立索引时展开
+func (r *Rule) SourceFiles() []string {
+	if r.config == nil {
+		return nil
+	}
+	var files []string
+	if r.config.SourceFile != "" {
+		files = append(files, r.config.SourceFile)
+	}
+	for _, file := range r.config.SourceFiles {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
 // SourceMessage 构建包含检索文档的提示词
 // 将检索到的文档和问题组合，替换模板中的占位符（{source}, {question}）
+// 规则未配置模板时使用规则语言的模板
 func (r *Rule) SourceMessage(source string, question string) string {
 	if r.config == nil {
 		return ""
 	}
+	template := r.config.SourceMessage
+	if template == "" {
+		template = r.messages.config.SourceMessage
+	}
 	replacer := strings.NewReplacer(
 		"{source}", source,
 		"{question}", question,
 	)
-	return replacer.Replace(r.config.SourceMessage)
+	return replacer.Replace(template)
+}
+
+// Loader 获取知识库文档加载器配置
+func (r *Rule) Loader() LoaderConfig {
+	if r.config == nil {
+		return LoaderConfig{}
+	}
+	return r.config.Loader
+}
+
+// Chunker 获取专家知识库的分块策略配置
+func (r *Rule) Chunker() ChunkerConfig {
+	if r.config == nil {
+		return ChunkerConfig{}
+	}
+	return r.config.Chunker
+}
+
+// Retrieval 获取专家知识库的检索配置
+func (r *Rule) Retrieval() RetrievalConfig {
+	if r.config == nil {
+		return RetrievalConfig{}
+	}
+	return r.config.Retrieval
+}
+
+// Query 获取专家知识库检索前的查询改写和扩展配置
+func (r *Rule) Query() QueryConfig {
+	if r.config == nil {
+		return QueryConfig{}
+	}
+	return r.config.Query
 }
 
 // NeedReviewer 判断是否需要评审者
@@ -219,6 +290,54 @@ func (r *Rule) RewriteMessage(review string) string {
 	return replacer.Replace(r.config.RewriteMessage)
 }
 
+// Tools 获取专家可以调用的工具名称
+func (r *Rule) Tools() []string {
+	if r.config == nil {
+		return nil
+	}
+	return r.config.Tools
+}
+
+// McpServers 获取专家可以使用的 MCP 服务名称
+func (r *Rule) McpServers() []string {
+	if r.config == nil {
+		return nil
+	}
+	return r.config.McpServers
+}
+
+// ContextWindow 获取上下文窗口配置
+func (r *Rule) ContextWindow() ContextWindowConfig {
+	if r.config == nil {
+		return ContextWindowConfig{}
+	}
+	return r.config.ContextWindow
+}
+
+// Model 获取专家使用的模型，未配置时返回空字符串
+func (r *Rule) Model() string {
+	if r.config == nil {
+		return ""
+	}
+	return r.config.Model
+}
+
+// ReviewerModel 获取评审者使用的模型，未配置时返回空字符串
+func (r *Rule) ReviewerModel() string {
+	if r.config == nil {
+		return ""
+	}
+	return r.config.ReviewerModel
+}
+
+// Options 获取专家的模型生成参数
+func (r *Rule) Options() OptionsConfig {
+	if r.config == nil {
+		return OptionsConfig{}
+	}
+	return r.config.Options
+}
+
 // ParseReview 解析评审结果
 // 从 LLM 返回的文本中提取分数和评价
 // 期望格式：score: 分数\nreview: 评价
@@ -236,6 +355,6 @@ func (r *Rule) ParseReview(text string) ReviewResult {
 
 // ReviewResult 评审结果
 type ReviewResult struct {
-	Score  int    // 评分（0-100）
-	Review string // 评价文本
+	Score  int    `json:"score"`  // 评分（0-100）
+	Review string `json:"review"` // 评价文本
 }
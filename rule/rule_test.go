@@ -26,3 +26,106 @@ func TestRules(t *testing.T) {
 		}
 	}
 }
+
+func TestParseReview(t *testing.T) {
+	r := &Rule{}
+	{ // case inline
+		result := r.ParseReview("score: 75 review: 这是一段评价")
+		if result.Score != 75 || result.Review != "这是一段评价" {
+			t.Fatalf("unexpected result: %+v", result)
+		}
+	}
+	{ // case key inside prose
+		result := r.ParseReview("score: 60\nreview: 诗中写道 score: 满分，但意境不足")
+		if result.Score != 60 || result.Review != "诗中写道 score: 满分，但意境不足" {
+			t.Fatalf("unexpected result: %+v", result)
+		}
+	}
+	{ // case markdown
+		result := r.ParseReview("**score**: 90\n**review**: 很好")
+		if result.Score != 90 || result.Review != "很好" {
+			t.Fatalf("unexpected result: %+v", result)
+		}
+	}
+	{ // case not formatted
+		result := r.ParseReview("写得很好")
+		if result.Score != 0 || result.Review != "" {
+			t.Fatalf("unexpected result: %+v", result)
+		}
+	}
+}
+
+func TestOptions(t *testing.T) {
+	config, err := readConfig("./config.yml")
+	if err != nil {
+		t.Fatal("read config file error")
+	}
+	{ // case math low temperature
+		temperature := config.Rules["math"].Options.Temperature
+		if temperature == nil || *temperature >= 0.5 {
+			t.Fatal("expected low temperature for math")
+		}
+	}
+	{ // case poet high temperature
+		temperature := config.Rules["poet"].Options.Temperature
+		if temperature == nil || *temperature <= 0.5 {
+			t.Fatal("expected high temperature for poet")
+		}
+	}
+	{ // case hp unset
+		if config.Rules["hp"].Options.Temperature != nil {
+			t.Fatal("expected unset temperature for hp")
+		}
+	}
+}
+
+func TestModels(t *testing.T) {
+	config, err := readConfig("./config.yml")
+	if err != nil {
+		t.Fatal("read config file error")
+	}
+	{ // case default
+		if config.Models.Default == "" {
+			t.Fatal("expected default model")
+		}
+	}
+}
+
+func TestSourceFiles(t *testing.T) {
+	{ // case source_file and source_files
+		rule := Rule{name: "docs", config: &RuleConfig{SourceFile: "a.txt", SourceFiles: []string{"./docs/**/*.md", ""}}}
+		files := rule.SourceFiles()
+		if len(files) != 2 || files[0] != "a.txt" || files[1] != "./docs/**/*.md" || !rule.NeedRag() {
+			t.Fatalf("unexpected source files: %v", files)
+		}
+	}
+	{ // case none
+		rule := Rule{name: "math", config: &RuleConfig{}}
+		if rule.NeedRag() {
+			t.Fatal("expected no rag")
+		}
+	}
+}
+
+func TestMessages(t *testing.T) {
+	config, err := readConfig("./config.yml")
+	if err != nil {
+		t.Fatal("read config file error")
+	}
+	manager := &ruleManager{config: config}
+	{ // case english overrides
+		messages := manager.Messages("en")
+		if message := messages.SummaryContextMessage("s"); message != "Here is a summary of the earlier conversation for reference:\ns" {
+			t.Fatalf("unexpected summary context message: %q", message)
+		}
+		rule := Rule{name: "docs", config: &RuleConfig{Language: "en"}, messages: messages}
+		if message := rule.SourceMessage("doc", "q"); message != "Read the following text and answer the question primarily based on it:\ndoc\nQuestion: q" {
+			t.Fatalf("unexpected source message: %q", message)
+		}
+	}
+	{ // case unknown language falls back to global
+		if manager.Messages("fr").RerankMessage("c", "q", 3) != manager.Messages("").RerankMessage("c", "q", 3) {
+			t.Fatal("expected global rerank message")
+		}
+	}
+}
@@ -8,27 +8,46 @@ import (
 // parseKeyValueText 解析键值对格式的文本
 // 从文本中提取两个键对应的值
 // 格式示例："score: 75 review: 这是一段评价"
+// 优先匹配位于行首的键，避免正文中出现的 "score:" 被误认为键
 // 参数 input: 输入文本
 // 参数 key0: 第一个键名
 // 参数 key1: 第二个键名
 // 返回: key0 的值、key1 的值、是否解析成功
 func parseKeyValueText(input, key0, key1 string) (string, string, bool) {
-	pKey0 := strings.Index(input, key0+":")
-	if pKey0 == -1 {
+	pKey1, end1 := indexKey(input, key1, len(input))
+	if pKey1 == -1 {
 		return "", "", false
 	}
 
-	pKey1 := strings.Index(input, key1+":")
-	if pKey1 == -1 {
+	pKey0, end0 := indexKey(input, key0, pKey1)
+	if pKey0 == -1 {
 		return "", "", false
 	}
 
-	var text0 = strings.TrimSpace(input[pKey0+len(key0)+1 : pKey1])
-	var text1 = strings.TrimSpace(input[pKey1+len(key1)+1:])
+	var text0 = strings.TrimSpace(input[end0:pKey1])
+	var text1 = strings.TrimSpace(input[end1:])
 
 	return text0, text1, true
 }
 
+// indexKey 在 input[:limit] 中查找键的位置
+// 优先返回第一个位于行首（允许前导空白和 Markdown 的 * # 符号）的键，找不到时退化为普通查找
+// 参数 input: 输入文本
+// 参数 key: 键名
+// 参数 limit: 查找范围的结束位置
+// 返回: 键（含前导符号）的起始位置、键（含冒号）之后的位置，未找到时返回 -1
+func indexKey(input string, key string, limit int) (int, int) {
+	re := regexp.MustCompile(`(?m)^[\s*#]*(` + regexp.QuoteMeta(key) + `)\**\s*[:：]\**`)
+	if loc := re.FindStringSubmatchIndex(input[:limit]); loc != nil {
+		return loc[0], loc[1]
+	}
+	p := strings.Index(input[:limit], key+":")
+	if p == -1 {
+		return -1, -1
+	}
+	return p, p + len(key) + 1
+}
+
 // compactEmptyLines 压缩文本中的连续空行
 // 将多个连续的空行压缩为一个，并去除首尾的换行符
 // 参数 input: 输入文本
@@ -43,3 +62,13 @@ func compactEmptyLines(input string) string {
 
 	return output
 }
+
+// firstNonEmpty 返回第一个非空字符串
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
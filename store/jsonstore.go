@@ -0,0 +1,126 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// jsonStore 基于目录的会话存储，每个会话保存为一个 JSON 文件
+type jsonStore struct {
+	mu  sync.Mutex // 保护文件读写
+	dir string     // 存储目录
+}
+
+// NewJsonStore 创建基于 JSON 文件的会话存储
+// 参数 dir: 存储目录，不存在时自动创建
+// 返回: ConversationStore 实例、error
+func NewJsonStore(dir string) (ConversationStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("create store dir: %w", err)
+	}
+	return &jsonStore{dir: dir}, nil
+}
+
+// path 获取会话文件路径
+func (s *jsonStore) path(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
+
+// Save 保存会话，先写临时文件再重命名，避免写入中断导致文件损坏
+func (s *jsonStore) Save(conv *Conversation) error {
+	if !validId(conv.Id) {
+		return fmt.Errorf("invalid conversation id: %q", conv.Id)
+	}
+	data, err := json.MarshalIndent(conv, "", "  ")
+	if err != nil {
+		return fmt.Errorf("json error: %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tmpPath := s.path(conv.Id) + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, s.path(conv.Id))
+}
+
+// Load 读取会话
+func (s *jsonStore) Load(id string) (*Conversation, error) {
+	if !validId(id) {
+		return nil, ErrNotFound
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.load(id)
+}
+
+// load 读取会话（调用者需要持有锁）
+func (s *jsonStore) load(id string) (*Conversation, error) {
+	data, err := os.ReadFile(s.path(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	var conv Conversation
+	if err := json.Unmarshal(data, &conv); err != nil {
+		return nil, fmt.Errorf("json error: %v", err)
+	}
+	return &conv, nil
+}
+
+// List 列出所有会话，按最后更新时间倒序
+func (s *jsonStore) List() ([]ConversationInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	infos := []ConversationInfo{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		conv, err := s.load(strings.TrimSuffix(name, ".json"))
+		if err != nil {
+			// 跳过损坏的文件
+			continue
+		}
+		infos = append(infos, conv.info())
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].UpdatedAt.After(infos[j].UpdatedAt)
+	})
+	return infos, nil
+}
+
+// Delete 删除会话
+func (s *jsonStore) Delete(id string) error {
+	if !validId(id) {
+		return ErrNotFound
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := os.Remove(s.path(id))
+	if os.IsNotExist(err) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// Close 关闭存储（无需释放资源）
+func (s *jsonStore) Close() error {
+	return nil
+}
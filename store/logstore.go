@@ -0,0 +1,205 @@
+package store
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"sync"
+)
+
+// logRecord 追加日志中的单条记录
+type logRecord struct {
+	Op           string        `json:"op"`                     // 操作类型：save/delete
+	Id           string        `json:"id"`                     // 会话 ID
+	Conversation *Conversation `json:"conversation,omitempty"` // 保存的会话（op 为 save 时）
+}
+
+// logStore 基于追加日志的会话存储
+// 每次保存或删除都以一行 JSON 追加到单个文件，启动时回放日志重建内存索引
+// 无效记录过多时在启动时压缩日志
+type logStore struct {
+	mu            sync.Mutex               // 保护文件和索引
+	path          string                   // 日志文件路径
+	file          *os.File                 // 以追加模式打开的日志文件
+	conversations map[string]*Conversation // 会话 ID 到最新会话的映射
+}
+
+// NewLogStore 创建基于追加日志的会话存储
+// 参数 path: 日志文件路径，不存在时自动创建
+// 返回: ConversationStore 实例、error
+func NewLogStore(path string) (ConversationStore, error) {
+	conversations, records, skipped, complete, err := replayLog(path)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &logStore{path: path, conversations: conversations}
+	// 有无法解析的记录、最后一行不完整或无效记录过多时压缩日志
+	// 不完整的最后一行必须清除，否则之后追加的记录会接在它后面而无法解析
+	// 有无法解析的记录时先将原日志保留为 .bak，避免压缩后无法找回
+	if skipped > 0 {
+		backup := path + ".bak"
+		if err := os.Rename(path, backup); err != nil {
+			return nil, fmt.Errorf("backup store log: %w", err)
+		}
+		log.Printf("store log %s: skipped %d invalid records, original kept at %s", path, skipped, backup)
+	}
+	if skipped > 0 || !complete || records > 2*len(conversations) {
+		if err := s.compact(); err != nil {
+			return nil, err
+		}
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("open store log: %w", err)
+	}
+	s.file = file
+	return s, nil
+}
+
+// replayLog 回放日志，重建会话索引
+// 跳过无法解析的记录（如写入中断的行）并计数，继续回放之后的记录
+// 返回: 会话索引、有效记录条数、跳过的记录条数、最后一行是否完整、error
+func replayLog(path string) (map[string]*Conversation, int, int, bool, error) {
+	conversations := make(map[string]*Conversation)
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return conversations, 0, 0, true, nil
+		}
+		return nil, 0, 0, false, err
+	}
+	defer file.Close()
+
+	records, skipped, complete := 0, 0, true
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, 0, 0, false, fmt.Errorf("read store log: %w", err)
+		}
+		if len(line) > 0 {
+			complete = line[len(line)-1] == '\n'
+			var record logRecord
+			if jsonErr := json.Unmarshal(line, &record); jsonErr != nil {
+				skipped++
+			} else {
+				records++
+				switch record.Op {
+				case "save":
+					if record.Conversation != nil {
+						conversations[record.Id] = record.Conversation
+					}
+				case "delete":
+					delete(conversations, record.Id)
+				}
+			}
+		}
+		if err == io.EOF {
+			return conversations, records, skipped, complete, nil
+		}
+	}
+}
+
+// compact 将当前索引重写为新的日志文件，替换旧日志
+func (s *logStore) compact() error {
+	tmpPath := s.path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+	for id, conv := range s.conversations {
+		if err := writeRecord(writer, logRecord{Op: "save", Id: id, Conversation: conv}); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, s.path)
+}
+
+// writeRecord 以一行 JSON 写入记录
+func writeRecord(writer io.Writer, record logRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("json error: %v", err)
+	}
+	_, err = writer.Write(append(data, '\n'))
+	return err
+}
+
+// Save 保存会话，追加一条 save 记录
+func (s *logStore) Save(conv *Conversation) error {
+	if !validId(conv.Id) {
+		return fmt.Errorf("invalid conversation id: %q", conv.Id)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := writeRecord(s.file, logRecord{Op: "save", Id: conv.Id, Conversation: conv}); err != nil {
+		return err
+	}
+	s.conversations[conv.Id] = conv
+	return s.file.Sync()
+}
+
+// Load 读取会话
+func (s *logStore) Load(id string) (*Conversation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	conv, ok := s.conversations[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return conv, nil
+}
+
+// List 列出所有会话，按最后更新时间倒序
+func (s *logStore) List() ([]ConversationInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	infos := []ConversationInfo{}
+	for _, conv := range s.conversations {
+		infos = append(infos, conv.info())
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].UpdatedAt.After(infos[j].UpdatedAt)
+	})
+	return infos, nil
+}
+
+// Delete 删除会话，追加一条 delete 记录
+func (s *logStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.conversations[id]; !ok {
+		return ErrNotFound
+	}
+	if err := writeRecord(s.file, logRecord{Op: "delete", Id: id}); err != nil {
+		return err
+	}
+	delete(s.conversations, id)
+	return s.file.Sync()
+}
+
+// Close 关闭日志文件
+func (s *logStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file != nil {
+		return s.file.Close()
+	}
+	return nil
+}
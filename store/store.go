@@ -0,0 +1,58 @@
+package store
+
+import (
+	"errors"
+	"time"
+
+	"go-ollama/ollama"
+)
+
+// ConversationStore 会话存储接口
+// 负责会话的持久化，服务重启后可以恢复之前的对话
+type ConversationStore interface {
+	Save(conv *Conversation) error
+	Load(id string) (*Conversation, error)
+	List() ([]ConversationInfo, error)
+	Delete(id string) error
+	Close() error
+}
+
+// ErrNotFound 会话不存在
+var ErrNotFound = errors.New("conversation not found")
+
+// Conversation 会话，包含会话中所有专家和评审者的对话上下文
+type Conversation struct {
+	Id          string                         `json:"id"`          // 会话 ID
+	Title       string                         `json:"title"`       // 标题，取会话的第一个问题
+	CreatedAt   time.Time                      `json:"created_at"`  // 创建时间
+	UpdatedAt   time.Time                      `json:"updated_at"`  // 最后更新时间
+	Specialists map[string]ollama.ChatSnapshot `json:"specialists"` // 专家名称到对话上下文快照的映射
+	Reviewers   map[string]ollama.ChatSnapshot `json:"reviewers"`   // 评审者名称到对话上下文快照的映射
+}
+
+// ConversationInfo 会话摘要信息，用于列表展示
+type ConversationInfo struct {
+	Id        string    `json:"id"`
+	Title     string    `json:"title"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// info 获取会话摘要信息
+func (c *Conversation) info() ConversationInfo {
+	return ConversationInfo{Id: c.Id, Title: c.Title, CreatedAt: c.CreatedAt, UpdatedAt: c.UpdatedAt}
+}
+
+// validId 检查会话 ID 是否合法
+// 会话 ID 会用作文件名，只允许字母、数字、下划线和连字符
+func validId(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
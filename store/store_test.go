@@ -0,0 +1,101 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go-ollama/ollama"
+)
+
+func TestStores(t *testing.T) {
+	dir := t.TempDir()
+	conv := &Conversation{
+		Id:        "abc123",
+		Title:     "问题",
+		UpdatedAt: time.Now(),
+		Specialists: map[string]ollama.ChatSnapshot{
+			"hp": {ModelName: "model", SystemMessage: "system", History: []ollama.ChatMessage{{Role: "user", Content: "hi"}}},
+		},
+	}
+	open := map[string]func() (ConversationStore, error){
+		"json": func() (ConversationStore, error) { return NewJsonStore(filepath.Join(dir, "json")) },
+		"log":  func() (ConversationStore, error) { return NewLogStore(filepath.Join(dir, "conversations.log")) },
+	}
+	for name, openStore := range open {
+		s, err := openStore()
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		if err := s.Save(conv); err != nil {
+			t.Fatal(name, err)
+		}
+		s.Close()
+
+		// 重新打开后恢复
+		s, err = openStore()
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		loaded, err := s.Load("abc123")
+		if err != nil || loaded.Specialists["hp"].History[0].Content != "hi" {
+			t.Fatal(name, "expected restore conversation")
+		}
+		infos, _ := s.List()
+		if len(infos) != 1 || infos[0].Title != "问题" {
+			t.Fatal(name, "expected list conversation")
+		}
+		if err := s.Delete("abc123"); err != nil {
+			t.Fatal(name, err)
+		}
+		if _, err := s.Load("abc123"); err != ErrNotFound {
+			t.Fatal(name, "expected conversation deleted")
+		}
+		if err := s.Save(&Conversation{Id: "../x"}); err == nil {
+			t.Fatal(name, "expected invalid id rejected")
+		}
+		s.Close()
+	}
+}
+
+func TestLogStoreRecovery(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "conversations.log")
+	original := `{"op":"save","id":"a","conversation":{"id":"a","title":"A"}}
+not json
+{"op":"save","id":"b","conversation":{"id":"b","title":"B"}}
+{"op":"sa`
+	if err := os.WriteFile(path, []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	{ // case skip invalid records and keep the original log
+		s, err := NewLogStore(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, id := range []string{"a", "b"} {
+			if _, err := s.Load(id); err != nil {
+				t.Fatal("record after an invalid line lost:", id, err)
+			}
+		}
+		if backup, err := os.ReadFile(path + ".bak"); err != nil || string(backup) != original {
+			t.Fatal("original log not kept", err)
+		}
+		if err := s.Save(&Conversation{Id: "c", Title: "C"}); err != nil {
+			t.Fatal(err)
+		}
+		s.Close()
+	}
+	{ // case compacted log replays cleanly
+		s, err := NewLogStore(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer s.Close()
+		if infos, _ := s.List(); len(infos) != 3 {
+			t.Fatalf("unexpected conversations: %+v", infos)
+		}
+	}
+}
@@ -152,6 +152,7 @@ const IndexHTML = `<!DOCTYPE html>
             <h1>🤖 Ollama AI 问答系统</h1>
             <p>智能多Agent协作问答平台</p>
             <div class="stats" id="stats"></div>
+            <div class="stats" id="knowledge"></div>
         </div>
         <div class="chat-area" id="chatArea">
             <div class="message bot">
@@ -176,6 +177,13 @@ const IndexHTML = `<!DOCTYPE html>
         const messageInput = document.getElementById('messageInput');
         const sendButton = document.getElementById('sendButton');
 
+        // 会话 ID，每个浏览器标签页独立维护对话历史
+        let sessionId = sessionStorage.getItem('sessionId');
+        if (!sessionId) {
+            sessionId = Date.now().toString(16) + Math.random().toString(16).slice(2);
+            sessionStorage.setItem('sessionId', sessionId);
+        }
+
         function handleKeyPress(event) {
             if (event.key === 'Enter' && !event.shiftKey) {
                 event.preventDefault();
@@ -192,6 +200,7 @@ const IndexHTML = `<!DOCTYPE html>
             messageDiv.appendChild(bubble);
             chatArea.appendChild(messageDiv);
             chatArea.scrollTop = chatArea.scrollHeight;
+            return bubble;
         }
 
         function showLoading() {
@@ -213,7 +222,16 @@ const IndexHTML = `<!DOCTYPE html>
             }
         }
 
-        async function sendMessage() {
+        function finishMessage() {
+            // 恢复输入和按钮
+            messageInput.disabled = false;
+            sendButton.disabled = false;
+            messageInput.focus();
+            // 更新统计信息
+            updateStats();
+        }
+
+        function sendMessage() {
             const message = messageInput.value.trim();
             if (!message) return;
 
@@ -225,38 +243,53 @@ const IndexHTML = `<!DOCTYPE html>
             addMessage(message, true);
             messageInput.value = '';
 
-            // 显示加载中
+            // 显示加载中，收到第一个 token 后替换为回答气泡
             showLoading();
+            let bubble = null;
+            function getBubble() {
+                if (!bubble) {
+                    removeLoading();
+                    bubble = addMessage('', false);
+                }
+                return bubble;
+            }
 
-            try {
-                const response = await fetch('/api/chat', {
-                    method: 'POST',
-                    headers: {
-                        'Content-Type': 'application/json',
-                    },
-                    body: JSON.stringify({ message: message }),
-                });
-
-                const data = await response.json();
-                removeLoading();
-
-                if (data.error) {
-                    addMessage('错误: ' + data.error, false);
+            // 使用 Server-Sent Events 逐个接收 token
+            const source = new EventSource('/api/chat/stream?session_id=' + encodeURIComponent(sessionId) +
+                '&message=' + encodeURIComponent(message));
+            source.addEventListener('token', function(e) {
+                const data = JSON.parse(e.data);
+                getBubble().textContent += data.token;
+                chatArea.scrollTop = chatArea.scrollHeight;
+            });
+            source.addEventListener('reset', function() {
+                // 评审后重写，清空已输出的内容
+                getBubble().textContent = '';
+            });
+            source.addEventListener('done', function(e) {
+                const data = JSON.parse(e.data);
+                getBubble().textContent = data.answer;
+                chatArea.scrollTop = chatArea.scrollHeight;
+                source.close();
+                finishMessage();
+            });
+            source.addEventListener('error', function(e) {
+                source.close();
+                if (e.data) {
+                    const data = JSON.parse(e.data);
+                    if (data.status === 'indexing') {
+                        // 知识库尚未就绪，显示索引进度提示
+                        getBubble().textContent = data.error;
+                        updateKnowledge();
+                    } else {
+                        getBubble().textContent = '错误: ' + data.error;
+                    }
                 } else {
-                    addMessage(data.answer, false);
+                    removeLoading();
+                    addMessage('网络错误: 连接已断开', false);
                 }
-
-                // 更新统计信息
-                updateStats();
-            } catch (error) {
-                removeLoading();
-                addMessage('网络错误: ' + error.message, false);
-            } finally {
-                // 恢复输入和按钮
-                messageInput.disabled = false;
-                sendButton.disabled = false;
-                messageInput.focus();
-            }
+                finishMessage();
+            });
         }
 
         async function updateStats() {
@@ -264,14 +297,42 @@ const IndexHTML = `<!DOCTYPE html>
                 const response = await fetch('/api/stats');
                 const stats = await response.json();
                 document.getElementById('stats').textContent = 
-                    '问题: ' + stats.question_count + ' | 回答: ' + stats.answer_count + ' | Token: ' + stats.total_token;
+                    '问题: ' + stats.question_count + ' | 回答: ' + stats.answer_count + ' | Token: ' + stats.total_token + ' | 向量化: ' + stats.embed_count;
             } catch (error) {
                 console.error('Failed to update stats:', error);
             }
         }
 
-        // 初始化时加载统计信息
+        const knowledgeStates = {pending: '等待索引', indexing: '索引中', ready: '就绪', failed: '失败'};
+        let knowledgeTimer = null;
+
+        async function updateKnowledge() {
+            try {
+                const response = await fetch('/api/knowledge/status');
+                const statuses = await response.json();
+                document.getElementById('knowledge').textContent = statuses.map(function(s) {
+                    let text = '知识库 ' + s.name + '（' + s.documents + '篇）: ' + (knowledgeStates[s.state] || s.state);
+                    if (s.state === 'indexing' && s.total > 0) {
+                        text += ' ' + s.percentage.toFixed(1) + '%';
+                    }
+                    return text;
+                }).join(' | ');
+                // 有知识库未就绪时每秒刷新进度，全部完成后停止
+                const pending = statuses.some(function(s) { return s.state === 'pending' || s.state === 'indexing'; });
+                if (pending && !knowledgeTimer) {
+                    knowledgeTimer = setInterval(updateKnowledge, 1000);
+                } else if (!pending && knowledgeTimer) {
+                    clearInterval(knowledgeTimer);
+                    knowledgeTimer = null;
+                }
+            } catch (error) {
+                console.error('Failed to update knowledge status:', error);
+            }
+        }
+
+        // 初始化时加载统计信息和知识库索引进度
         updateStats();
+        updateKnowledge();
         // 每5秒更新一次统计信息
         setInterval(updateStats, 5000);
         
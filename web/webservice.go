@@ -1,23 +1,42 @@
 package web
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"path/filepath"
+	"strings"
 
 	"go-ollama/agent"
 	"go-ollama/ollama"
+	"go-ollama/rag"
+	"go-ollama/store"
 )
 
 // ChatRequest 聊天请求结构
 type ChatRequest struct {
-	Message string `json:"message"`
+	SessionId string `json:"session_id,omitempty"` // 会话 ID，为空时由服务端生成
+	Message   string `json:"message"`
 }
 
 // ChatResponse 聊天响应结构
 type ChatResponse struct {
-	Answer string `json:"answer"`
-	Error  string `json:"error,omitempty"`
+	SessionId string `json:"session_id,omitempty"`
+	Answer    string `json:"answer"`
+	Error     string `json:"error,omitempty"`
+}
+
+// StreamEvent 流式聊天 SSE 事件的数据结构
+type StreamEvent struct {
+	SessionId string `json:"session_id,omitempty"`
+	Token     string `json:"token,omitempty"`
+	Answer    string `json:"answer,omitempty"`
+	Error     string `json:"error,omitempty"`
+	Status    string `json:"status,omitempty"` // 错误状态，知识库尚未就绪时为 indexing
 }
 
 // StatsResponse 统计信息响应结构
@@ -26,6 +45,8 @@ type StatsResponse struct {
 	AnswerCount   int     `json:"answer_count"`
 	TotalDuration float64 `json:"total_duration"`
 	TotalToken    int     `json:"total_token"`
+	EmbedCount    int     `json:"embed_count"`
+	EmbedToken    int     `json:"embed_token"`
 }
 
 // WebService Web服务，包含所有需要的依赖
@@ -76,14 +97,142 @@ func (ws *WebService) HandleChat(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	sessionId := req.SessionId
+	if sessionId == "" {
+		sessionId = newSessionId()
+	}
+
 	// 调用Agent处理问题
-	answer := ws.agentMgr.Chat(req.Message)
+	answer := ws.agentMgr.Chat(sessionId, req.Message)
 
-	response := ChatResponse{Answer: answer}
+	response := ChatResponse{SessionId: sessionId, Answer: answer}
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	json.NewEncoder(w).Encode(response)
 }
 
+// HandleChatStream 处理流式聊天API请求，以 Server-Sent Events 返回增量 token
+// 请求格式：GET /api/chat/stream?session_id=会话ID&message=问题
+// 事件类型：token（增量内容）、reset（清空已输出内容）、done（完整回答）、error（错误信息）
+func (ws *WebService) HandleChatStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	message := r.URL.Query().Get("message")
+	sessionId := r.URL.Query().Get("session_id")
+	if sessionId == "" {
+		sessionId = newSessionId()
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	if message == "" {
+		writeEvent(w, flusher, "error", StreamEvent{Error: "消息不能为空"})
+		return
+	}
+
+	// 调用Agent处理问题，逐个转发事件
+	// 客户端断开后请求的 context 被取消，模型停止生成；仍要消费完 channel，避免 goroutine 阻塞
+	for event := range ws.agentMgr.ChatStream(r.Context(), sessionId, message) {
+		if r.Context().Err() != nil {
+			continue
+		}
+		switch {
+		case errors.Is(event.Err, agent.ErrKnowledgeIndexing):
+			writeEvent(w, flusher, "error", StreamEvent{Error: event.Err.Error(), Status: string(agent.KnowledgeIndexing)})
+		case event.Err != nil:
+			writeEvent(w, flusher, "error", StreamEvent{Error: event.Err.Error()})
+		case event.Reset:
+			writeEvent(w, flusher, "reset", StreamEvent{})
+		case event.Done:
+			writeEvent(w, flusher, "done", StreamEvent{SessionId: sessionId, Answer: event.Token})
+		default:
+			writeEvent(w, flusher, "token", StreamEvent{Token: event.Token})
+		}
+	}
+}
+
+// writeEvent 写入一个 SSE 事件并立即刷新
+// 数据使用 JSON 编码，避免换行符破坏 SSE 格式
+func writeEvent(w http.ResponseWriter, flusher http.Flusher, event string, data StreamEvent) {
+	jsonData, _ := json.Marshal(data)
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, jsonData)
+	flusher.Flush()
+}
+
+// newSessionId 生成随机的会话 ID
+func newSessionId() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// HandleConversations 处理会话列表API请求
+// GET /api/conversations 返回已保存的会话列表
+func (ws *WebService) HandleConversations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	infos, err := ws.agentMgr.ListConversations()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(infos)
+}
+
+// HandleConversation 处理单个会话API请求
+// GET /api/conversations/{id} 返回会话内容
+// DELETE /api/conversations/{id} 删除会话
+func (ws *WebService) HandleConversation(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/api/conversations/")
+	if id == "" {
+		ws.HandleConversations(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		conv, err := ws.agentMgr.GetConversation(id)
+		if err != nil {
+			writeStoreError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(conv)
+	case http.MethodDelete:
+		if err := ws.agentMgr.DeleteConversation(id); err != nil {
+			writeStoreError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// writeStoreError 根据存储错误类型返回对应的状态码
+func writeStoreError(w http.ResponseWriter, err error) {
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
 // HandleStats 处理统计信息API请求
 func (ws *WebService) HandleStats(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -96,21 +245,131 @@ func (ws *WebService) HandleStats(w http.ResponseWriter, r *http.Request) {
 		AnswerCount:   ws.ollamaMgr.GetTotalACount(),
 		TotalDuration: ws.ollamaMgr.GetTotalDuration().Seconds(),
 		TotalToken:    ws.ollamaMgr.GetTotalToken(),
+		EmbedCount:    ws.ollamaMgr.GetTotalEmbedCount(),
+		EmbedToken:    ws.ollamaMgr.GetTotalEmbedToken(),
 	}
 
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	json.NewEncoder(w).Encode(stats)
 }
 
+// HandleKnowledgeStatus 处理知识库索引进度API请求
+// GET /api/knowledge/status 返回所有需要知识库的专家的索引进度
+func (ws *WebService) HandleKnowledgeStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	statuses := ws.agentMgr.KnowledgeStatus()
+	if statuses == nil {
+		statuses = []agent.KnowledgeStatus{}
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(statuses)
+}
+
+// maxUploadSize 上传到知识库的文档的最大字节数
+const maxUploadSize = 32 << 20
+
+// HandleKnowledge 处理知识库文档管理API请求
+// GET /api/knowledge/{name}/documents 返回知识库中的文档列表
+// POST /api/knowledge/{name}/documents 上传文档（multipart 表单，file 为文件，id 为可选的文档 ID，默认使用文件名），ID 与配置的源文件冲突时返回 409
+// DELETE /api/knowledge/{name}/documents/{id} 删除文档，正在建立索引时返回 409
+// POST /api/knowledge/{name}/reindex 重新索引知识库中的所有文档
+// 上传和重新索引在后台执行，进度通过 /api/knowledge/status 查询
+func (ws *WebService) HandleKnowledge(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/knowledge/"), "/")
+	name := parts[0]
+	switch {
+	case len(parts) == 2 && parts[1] == "documents" && r.Method == http.MethodGet:
+		docs, err := ws.agentMgr.ListKnowledgeDocuments(name)
+		if err != nil {
+			writeKnowledgeError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(docs)
+	case len(parts) == 2 && parts[1] == "documents" && r.Method == http.MethodPost:
+		ws.uploadKnowledgeDocument(w, r, name)
+	case len(parts) == 3 && parts[1] == "documents" && r.Method == http.MethodDelete:
+		if err := ws.agentMgr.DeleteKnowledgeDocument(name, parts[2]); err != nil {
+			writeKnowledgeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case len(parts) == 2 && parts[1] == "reindex" && r.Method == http.MethodPost:
+		if err := ws.agentMgr.ReindexKnowledge(name); err != nil {
+			writeKnowledgeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	case len(parts) == 2 && (parts[1] == "documents" || parts[1] == "reindex"),
+		len(parts) == 3 && parts[1] == "documents":
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// uploadKnowledgeDocument 保存上传的文档并在后台建立索引
+func (ws *WebService) uploadKnowledgeDocument(w http.ResponseWriter, r *http.Request, name string) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "无效的上传文件: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		http.Error(w, "无效的上传文件: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	docId := r.FormValue("id")
+	if docId == "" {
+		docId = filepath.Base(header.Filename)
+	}
+	if err := ws.agentMgr.UploadKnowledgeDocument(name, docId, data); err != nil {
+		writeKnowledgeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"id": docId})
+}
+
+// writeKnowledgeError 根据知识库错误类型返回对应的状态码
+func writeKnowledgeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, agent.ErrKnowledgeNotFound), errors.Is(err, rag.ErrDocumentNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, rag.ErrInvalidDocumentId):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, agent.ErrDocumentConflict), errors.Is(err, rag.ErrIndexInProgress):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
 // RegisterRoutes 注册所有HTTP路由
 // 参数 mux: HTTP多路复用器，如果为nil则使用默认的http.DefaultServeMux
 func (ws *WebService) RegisterRoutes(mux *http.ServeMux) {
 	if mux == nil {
 		mux = http.DefaultServeMux
 	}
-	
+
 	mux.HandleFunc("/", ws.HandleIndex)
 	mux.HandleFunc("/api/chat", ws.HandleChat)
+	mux.HandleFunc("/api/chat/stream", ws.HandleChatStream)
 	mux.HandleFunc("/api/stats", ws.HandleStats)
+	mux.HandleFunc("/api/knowledge/status", ws.HandleKnowledgeStatus)
+	mux.HandleFunc("/api/knowledge/", ws.HandleKnowledge)
+	mux.HandleFunc("/api/conversations", ws.HandleConversations)
+	mux.HandleFunc("/api/conversations/", ws.HandleConversation)
+	// MCP 服务（Streamable HTTP）
+	mux.Handle("/mcp", agent.NewMcpServer(ws.agentMgr))
 }
-
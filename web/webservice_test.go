@@ -0,0 +1,56 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-ollama/agent"
+)
+
+// fakeAgent 测试用的 Agent 管理器，只实现用到的方法
+type fakeAgent struct {
+	agent.AgentManager
+	statuses []agent.KnowledgeStatus
+}
+
+func (f *fakeAgent) KnowledgeStatus() []agent.KnowledgeStatus {
+	return f.statuses
+}
+
+func TestHandleKnowledgeStatus(t *testing.T) {
+	fake := &fakeAgent{}
+	ws := NewWebService(fake, nil)
+
+	{ // case no knowledge base
+		recorder := httptest.NewRecorder()
+		ws.HandleKnowledgeStatus(recorder, httptest.NewRequest(http.MethodGet, "/api/knowledge/status", nil))
+		if recorder.Code != http.StatusOK || recorder.Body.String() != "[]\n" {
+			t.Fatal("unexpected response", recorder.Code, recorder.Body.String())
+		}
+	}
+	{ // case indexing and failed
+		fake.statuses = []agent.KnowledgeStatus{
+			{Name: "hp", State: agent.KnowledgeIndexing, Current: 1, Total: 4, Percentage: 25},
+			{Name: "math", State: agent.KnowledgeFailed, Error: "embed model unavailable"},
+		}
+		recorder := httptest.NewRecorder()
+		ws.HandleKnowledgeStatus(recorder, httptest.NewRequest(http.MethodGet, "/api/knowledge/status", nil))
+		var statuses []agent.KnowledgeStatus
+		if err := json.NewDecoder(recorder.Body).Decode(&statuses); err != nil {
+			t.Fatal(err)
+		}
+		if len(statuses) != 2 || statuses[0].State != agent.KnowledgeIndexing || statuses[0].Total != 4 ||
+			statuses[1].State != agent.KnowledgeFailed || statuses[1].Error != "embed model unavailable" {
+			t.Fatalf("unexpected statuses: %+v", statuses)
+		}
+	}
+	{ // case method not allowed
+		recorder := httptest.NewRecorder()
+		ws.HandleKnowledgeStatus(recorder, httptest.NewRequest(http.MethodPost, "/api/knowledge/status", nil))
+		if recorder.Code != http.StatusMethodNotAllowed {
+			t.Fatal("unexpected status code", recorder.Code)
+		}
+	}
+}